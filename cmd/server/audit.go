@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"minisnap/internal/audit"
+)
+
+// runAudit 实现 audit 子命令：按条件输出内容目录中的审计日志，最新的在前。
+func runAudit(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	contentDir := fs.String("content-dir", getEnvDefault("CONTENT_DIR", "content"), "content directory holding the audit log")
+	action := fs.String("action", "", "filter by action or action prefix, e.g. entry or login.failure")
+	actor := fs.String("actor", "", "filter by actor")
+	ip := fs.String("ip", "", "filter by client IP")
+	slug := fs.String("slug", "", "filter by entry slug")
+	since := fs.String("since", "", "only records newer than a duration (24h) or date (2024-05-01)")
+	limit := fs.Int("limit", 50, "maximum number of records, 0 for all")
+	asJSON := fs.Bool("json", false, "print raw JSON lines")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	filter := audit.Filter{Action: *action, Actor: *actor, IP: *ip, Slug: *slug, Limit: *limit}
+	if *since != "" {
+		t, err := parseSince(*since, time.Now())
+		if err != nil {
+			return err
+		}
+		filter.Since = t
+	}
+
+	events, err := audit.New(audit.Path(*contentDir)).Query(filter)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		for _, e := range events {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tACTION\tACTOR\tIP\tSLUG\tHASH\tDETAIL")
+	for _, e := range events {
+		hash := strings.TrimPrefix(e.Hash, "sha256:")
+		if len(hash) > 12 {
+			hash = hash[:12]
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			e.Time.Local().Format("2006-01-02 15:04:05"), e.Action, dash(e.Actor), dash(e.IP), dash(e.Slug), dash(hash), e.Detail)
+	}
+	return tw.Flush()
+}
+
+// parseSince 接受相对时长（如 24h、90m）或本地日期（2006-01-02）。
+func parseSince(v string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(v); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", v, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid -since %q: want a duration like 24h or a date like 2024-05-01", v)
+}
+
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func getEnvDefault(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
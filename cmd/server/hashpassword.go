@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+
+	"minisnap/internal/password"
+)
+
+// runHashPassword 实现 hash-password 子命令：读取密码并输出可用于 ADMIN_PASSWORD_HASH 的 argon2id 哈希。
+// 终端中交互输入（不回显，需确认一次），否则读取标准输入的第一行，便于脚本调用。
+func runHashPassword() error {
+	var pw string
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		first, err := promptPassword(fd, "Password: ")
+		if err != nil {
+			return err
+		}
+		again, err := promptPassword(fd, "Confirm password: ")
+		if err != nil {
+			return err
+		}
+		if first != again {
+			return errors.New("passwords do not match")
+		}
+		pw = first
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("read password: %w", err)
+		}
+		pw = strings.TrimRight(line, "\r\n")
+	}
+	if pw == "" {
+		return errors.New("password must not be empty")
+	}
+
+	hash, err := password.Hash(pw)
+	if err != nil {
+		return err
+	}
+	fmt.Println(hash)
+	return nil
+}
+
+func promptPassword(fd int, prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	raw, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return string(raw), nil
+}
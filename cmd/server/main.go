@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"flag"
+	"fmt"
 	"log"
 	"log/slog"
 	"net/http"
@@ -14,14 +16,36 @@ import (
 
 	"github.com/joho/godotenv"
 
+	"minisnap/internal/certreload"
 	"minisnap/internal/config"
 	"minisnap/internal/content"
+	"minisnap/internal/logging"
 	"minisnap/internal/server"
 )
 
 func main() {
 	_ = godotenv.Load()
 
+	// 子命令在解析服务参数之前处理，执行完即退出。
+	if len(os.Args) > 1 {
+		var run func() error
+		switch os.Args[1] {
+		case "hash-password":
+			run = runHashPassword
+		case "audit":
+			run = func() error { return runAudit(os.Args[2:]) }
+		case "totp-reset":
+			run = func() error { return runTOTPReset(os.Args[2:]) }
+		}
+		if run != nil {
+			if err := run(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
 	bindFlag := flag.String("bind", "", "override bind address, e.g. :9090")
 	contentFlag := flag.String("content-dir", "", "override content directory path")
 	passwordFlag := flag.String("admin-password", "", "override admin password (for development only)")
@@ -42,6 +66,12 @@ func main() {
 		log.Fatalf("load config: %v", err)
 	}
 
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("init logger: %v", err)
+	}
+	slog.SetDefault(logger)
+
 	store, err := content.NewStore(cfg.ContentDir)
 	if err != nil {
 		log.Fatalf("init store: %v", err)
@@ -72,15 +102,98 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// 配置了证书时直接提供 HTTPS；证书按文件 mtime 轮询或收到 SIGHUP 时热加载，无需重启。
+	var reloader *certreload.Reloader
+	if cfg.TLSEnabled() {
+		reloader, err = certreload.New(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			log.Fatalf("load tls certificate: %v", err)
+		}
+		httpServer.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+		if cfg.TLSReloadInterval > 0 {
+			go reloader.Watch(ctx, cfg.TLSReloadInterval)
+		}
+
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		go func() {
+			for range hup {
+				if err := reloader.Reload(); err != nil {
+					slog.Error("reload tls certificate", "error", err)
+				}
+			}
+		}()
+	}
+
+	// 定期永久删除超过保留期的回收站条目。
+	go s.RunTrashPurge(ctx)
+	// 定期删除不再被任何条目引用的上传附件。
+	go s.RunFileCleanup(ctx)
+
 	go func() {
-		slog.Info("starting server", "addr", cfg.BindAddr)
-		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Info("starting server", "addr", cfg.BindAddr, "tls", reloader != nil)
+		var err error
+		if reloader != nil {
+			err = httpServer.ListenAndServeTLS("", "")
+		} else {
+			err = httpServer.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("server exited: %v", err)
 		}
 	}()
 
+	// 可选的明文监听，仅把请求重定向到 HTTPS。
+	var redirectServer *http.Server
+	if cfg.HTTPRedirectAddr != "" {
+		redirectServer = &http.Server{
+			Addr:              cfg.HTTPRedirectAddr,
+			Handler:           server.HTTPSRedirectHandler(cfg.BindAddr),
+			ReadHeaderTimeout: 10 * time.Second,
+			IdleTimeout:       30 * time.Second,
+		}
+		go func() {
+			slog.Info("starting https redirect server", "addr", cfg.HTTPRedirectAddr)
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("redirect server exited: %v", err)
+			}
+		}()
+	}
+
+	// 指标可单独监听在内网/管理地址上，避免暴露到公网入口。
+	var metricsServer *http.Server
+	if cfg.MetricsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", s.MetricsHandler())
+		metricsServer = &http.Server{
+			Addr:              cfg.MetricsAddr,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		go func() {
+			slog.Info("starting metrics server", "addr", cfg.MetricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("metrics server exited: %v", err)
+			}
+		}()
+	} else if cfg.MetricsToken == "" {
+		slog.Info("metrics endpoint disabled; set METRICS_ADDR or METRICS_TOKEN to enable it")
+	}
+
 	<-ctx.Done()
-	slog.Info("shutdown signal received, draining connections...")
+	// 恢复默认信号处理：再次 Ctrl+C 可立即退出。
+	stop()
+
+	// 先让 /readyz 返回 503，等待负载均衡器摘除流量后再关闭监听。
+	s.SetDraining()
+	if cfg.DrainDelay > 0 {
+		slog.Info("shutdown signal received, failing readiness", "drain_delay", cfg.DrainDelay)
+		time.Sleep(cfg.DrainDelay)
+	}
+	slog.Info("draining connections...")
 
 	// 给在途请求最多 30 秒完成。
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -88,5 +201,11 @@ func main() {
 	if err := httpServer.Shutdown(shutdownCtx); err != nil {
 		log.Printf("graceful shutdown failed: %v", err)
 	}
+	if metricsServer != nil {
+		_ = metricsServer.Shutdown(shutdownCtx)
+	}
+	if redirectServer != nil {
+		_ = redirectServer.Shutdown(shutdownCtx)
+	}
 	slog.Info("server stopped")
 }
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"minisnap/internal/server"
+)
+
+// runTOTPReset 实现 totp-reset 子命令：关闭二次验证，用于加密密钥丢失或状态无法解密时恢复密码登录。
+// 需在服务器上执行，重置后可在 /admin/security 重新启用。
+func runTOTPReset(args []string) error {
+	fs := flag.NewFlagSet("totp-reset", flag.ContinueOnError)
+	contentDir := fs.String("content-dir", getEnvDefault("CONTENT_DIR", "content"), "content directory holding the two-factor state")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	removed, err := server.ResetTwoFactor(*contentDir)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		fmt.Println("Two-factor authentication is not enabled.")
+		return nil
+	}
+	fmt.Println("Two-factor authentication disabled. Sign in with the password and enable it again from /admin/security.")
+	return nil
+}
@@ -0,0 +1,475 @@
+// Package attach 以内容寻址方式保存编辑器上传的附件：文件名即内容的 SHA-256，
+// 相同内容只存一份，元数据写在同名 .json 旁文件中。
+package attach
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DirName 为附件目录在内容目录下的名称；条目列表只读取顶层 .json 文件，不会进入该目录。
+const DirName = ".files"
+
+// URLPrefix 为附件的访问路径前缀，条目内容通过它引用附件。
+const URLPrefix = "/-/files/"
+
+var (
+	// ErrTooLarge 表示上传内容超过大小上限。
+	ErrTooLarge = errors.New("file too large")
+	// ErrEmpty 表示上传内容为空。
+	ErrEmpty = errors.New("file is empty")
+	// ErrNotFound 表示附件不存在或哈希格式不正确。
+	ErrNotFound = errors.New("file not found")
+)
+
+// File 描述一个已保存的附件。
+type File struct {
+	Hash       string    `json:"hash"`
+	Name       string    `json:"name"`
+	MIME       string    `json:"mime"`
+	Size       int64     `json:"size"`
+	UploadedAt time.Time `json:"uploaded_at"`
+
+	// Width / Height 为图片尺寸，Variants 为生成的缩略图与缩放版本；非图片为零值。
+	Width    int       `json:"width,omitempty"`
+	Height   int       `json:"height,omitempty"`
+	Variants []Variant `json:"variants,omitempty"`
+}
+
+// Variant 是图片附件的一个派生版本，与原图存放在同一目录（<hash>.<name>）。
+type Variant struct {
+	Name   string `json:"name"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	MIME   string `json:"mime"`
+	Size   int64  `json:"size"`
+}
+
+// Rendition 是待保存的变体及其内容。
+type Rendition struct {
+	Variant
+	Data []byte
+}
+
+// URL 返回附件的访问路径。
+func (f File) URL() string {
+	return URLPrefix + f.Hash
+}
+
+// VariantURL 返回变体的访问路径。
+func (f File) VariantURL(name string) string {
+	return URLPrefix + f.Hash + "/" + name
+}
+
+// Variant 按名称查找变体。
+func (f File) Variant(name string) (Variant, bool) {
+	for _, v := range f.Variants {
+		if v.Name == name {
+			return v, true
+		}
+	}
+	return Variant{}, false
+}
+
+// IsImage 报告附件是否为可在页面内联显示的位图。
+func (f File) IsImage() bool {
+	return InlineImage(f.MIME)
+}
+
+// inlineImages 是允许内联显示的图片类型。SVG 可携带脚本，不在其列，按下载处理。
+var inlineImages = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// InlineImage 报告 MIME 类型是否属于可内联显示的位图。
+func InlineImage(mime string) bool {
+	return inlineImages[mime]
+}
+
+// Store 管理附件目录，并发安全。
+type Store struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewStore 在内容目录下创建（如不存在）附件目录。
+func NewStore(contentDir string) (*Store, error) {
+	dir := filepath.Join(contentDir, DirName)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create files dir: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Dir 返回附件目录。
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// Save 流式读取 r 并按内容哈希保存，超过 maxSize 字节时返回 ErrTooLarge（maxSize 为 0 不限制）。
+// MIME 类型按内容嗅探，不信任客户端声明。内容已存在时沿用原记录，只刷新上传时间，
+// 避免刚被重新引用的旧附件被当作孤儿清理。
+func (s *Store) Save(name string, r io.Reader, maxSize int64) (File, error) {
+	tmp, err := os.CreateTemp(s.dir, ".upload-*")
+	if err != nil {
+		return File{}, fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	src := r
+	if maxSize > 0 {
+		src = io.LimitReader(r, maxSize+1)
+	}
+	h := sha256.New()
+	sniff := &headBuffer{limit: 512}
+	n, err := io.Copy(io.MultiWriter(tmp, h, sniff), src)
+	if err != nil {
+		return File{}, fmt.Errorf("write upload: %w", err)
+	}
+	if maxSize > 0 && n > maxSize {
+		return File{}, ErrTooLarge
+	}
+	if n == 0 {
+		return File{}, ErrEmpty
+	}
+	if err := tmp.Close(); err != nil {
+		return File{}, fmt.Errorf("write upload: %w", err)
+	}
+
+	f := File{
+		Hash:       hex.EncodeToString(h.Sum(nil)),
+		Name:       cleanName(name),
+		MIME:       DetectMIME(sniff.buf),
+		Size:       n,
+		UploadedAt: time.Now().UTC(),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, err := s.stat(f.Hash); err == nil {
+		existing.UploadedAt = f.UploadedAt
+		if err := s.writeMeta(existing); err != nil {
+			return File{}, err
+		}
+		return existing, nil
+	}
+	if err := os.Rename(tmp.Name(), s.blobPath(f.Hash)); err != nil {
+		return File{}, fmt.Errorf("store upload: %w", err)
+	}
+	if err := s.writeMeta(f); err != nil {
+		_ = os.Remove(s.blobPath(f.Hash))
+		return File{}, err
+	}
+	return f, nil
+}
+
+// Stat 返回附件元数据。
+func (s *Store) Stat(hash string) (File, error) {
+	if !ValidHash(hash) {
+		return File{}, ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stat(hash)
+}
+
+// Open 打开附件内容，调用方负责关闭。
+func (s *Store) Open(hash string) (*os.File, File, error) {
+	f, err := s.Stat(hash)
+	if err != nil {
+		return nil, File{}, err
+	}
+	blob, err := os.Open(s.blobPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, File{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, File{}, err
+	}
+	return blob, f, nil
+}
+
+// SetImage 记录图片尺寸并保存其变体，已有的变体会被替换。
+func (s *Store) SetImage(hash string, width, height int, renditions []Rendition) (File, error) {
+	if !ValidHash(hash) {
+		return File{}, ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.stat(hash)
+	if err != nil {
+		return File{}, err
+	}
+	f.Width, f.Height, f.Variants = width, height, nil
+	for _, r := range renditions {
+		if !validVariantName(r.Name) {
+			return File{}, fmt.Errorf("invalid variant name %q", r.Name)
+		}
+		if err := writeFileAtomic(s.variantPath(hash, r.Name), r.Data); err != nil {
+			return File{}, fmt.Errorf("write variant: %w", err)
+		}
+		v := r.Variant
+		v.Size = int64(len(r.Data))
+		f.Variants = append(f.Variants, v)
+	}
+	if err := s.writeMeta(f); err != nil {
+		return File{}, err
+	}
+	return f, nil
+}
+
+// OpenVariant 打开图片变体，调用方负责关闭。
+func (s *Store) OpenVariant(hash, name string) (*os.File, Variant, error) {
+	f, err := s.Stat(hash)
+	if err != nil {
+		return nil, Variant{}, err
+	}
+	v, ok := f.Variant(name)
+	if !ok {
+		return nil, Variant{}, ErrNotFound
+	}
+	blob, err := os.Open(s.variantPath(hash, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, Variant{}, ErrNotFound
+	}
+	if err != nil {
+		return nil, Variant{}, err
+	}
+	return blob, v, nil
+}
+
+// List 返回全部附件，按上传时间倒序。
+func (s *Store) List() ([]File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+// Remove 删除附件及其元数据；不存在时返回 ErrNotFound。
+func (s *Store) Remove(hash string) error {
+	if !ValidHash(hash) {
+		return ErrNotFound
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remove(hash)
+}
+
+// RemoveUnreferenced 删除不在 referenced 中、且上传时间早于 cutoff 的附件，返回被删除的附件。
+// cutoff 给刚上传、尚未随条目保存的附件留出宽限期。
+func (s *Store) RemoveUnreferenced(referenced map[string]bool, cutoff time.Time) ([]File, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	var removed []File
+	for _, f := range files {
+		if referenced[f.Hash] || !f.UploadedAt.Before(cutoff) {
+			continue
+		}
+		if err := s.remove(f.Hash); err != nil {
+			return removed, err
+		}
+		removed = append(removed, f)
+	}
+	return removed, nil
+}
+
+func (s *Store) list() ([]File, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("read files dir: %w", err)
+	}
+	var files []File
+	for _, de := range dirEntries {
+		hash, ok := strings.CutSuffix(de.Name(), ".json")
+		if !ok || !ValidHash(hash) {
+			continue
+		}
+		f, err := s.stat(hash)
+		if err != nil {
+			continue
+		}
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].UploadedAt.After(files[j].UploadedAt)
+	})
+	return files, nil
+}
+
+func (s *Store) stat(hash string) (File, error) {
+	data, err := os.ReadFile(s.metaPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return File{}, ErrNotFound
+	}
+	if err != nil {
+		return File{}, err
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return File{}, fmt.Errorf("decode file meta %s: %w", hash, err)
+	}
+	f.Hash = hash
+	return f, nil
+}
+
+func (s *Store) remove(hash string) error {
+	f, err := s.stat(hash)
+	if err != nil {
+		return err
+	}
+	for _, v := range f.Variants {
+		if err := os.Remove(s.variantPath(hash, v.Name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	err = os.Remove(s.metaPath(hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(s.blobPath(hash)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *Store) writeMeta(f File) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.metaPath(f.Hash), data); err != nil {
+		return fmt.Errorf("write file meta: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic 先写临时文件再改名，避免读到写了一半的内容。
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *Store) blobPath(hash string) string {
+	return filepath.Join(s.dir, hash)
+}
+
+func (s *Store) metaPath(hash string) string {
+	return filepath.Join(s.dir, hash+".json")
+}
+
+func (s *Store) variantPath(hash, name string) string {
+	return filepath.Join(s.dir, hash+"."+name)
+}
+
+// validVariantName 只允许小写字母与数字，变体名会成为文件名的一部分，且不能与 .json 冲突。
+func validVariantName(name string) bool {
+	if name == "" || name == "json" || len(name) > 16 {
+		return false
+	}
+	for _, c := range name {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'z') {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidHash 报告 s 是否为小写十六进制的 SHA-256。
+func ValidHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+var referencePattern = regexp.MustCompile(`/-/files/([0-9a-f]{64})`)
+
+// References 返回内容中引用的附件哈希（去重，按出现顺序）；引用变体也算引用原附件。
+func References(raw string) []string {
+	var hashes []string
+	seen := make(map[string]bool)
+	for _, m := range referencePattern.FindAllStringSubmatch(raw, -1) {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			hashes = append(hashes, m[1])
+		}
+	}
+	return hashes
+}
+
+// DetectMIME 按内容（前 512 字节）判断类型，去掉 charset 等参数。
+func DetectMIME(head []byte) string {
+	mime := http.DetectContentType(head)
+	if i := strings.IndexByte(mime, ';'); i >= 0 {
+		mime = mime[:i]
+	}
+	return mime
+}
+
+// maxNameLen 为保存的原始文件名的最大字符数。
+const maxNameLen = 120
+
+// cleanName 只保留文件名的最后一段并去掉控制字符，用于下载时的建议文件名。
+func cleanName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "." || name == "/" || name == "" {
+		return "file"
+	}
+	if r := []rune(name); len(r) > maxNameLen {
+		name = string(r[:maxNameLen])
+	}
+	return name
+}
+
+// headBuffer 记录写入内容的前 limit 字节，供 MIME 嗅探。
+type headBuffer struct {
+	buf   []byte
+	limit int
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - len(b.buf); room > 0 {
+		if len(p) < room {
+			room = len(p)
+		}
+		b.buf = append(b.buf, p[:room]...)
+	}
+	return len(p), nil
+}
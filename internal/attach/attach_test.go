@@ -0,0 +1,124 @@
+package attach
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// pngHeader 足以让 http.DetectContentType 识别为 PNG。
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestSaveIsContentAddressed(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	f, err := store.Save("../../shot.png", bytes.NewReader(pngHeader), 1024)
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if !ValidHash(f.Hash) || f.MIME != "image/png" || f.Name != "shot.png" || f.Size != int64(len(pngHeader)) {
+		t.Fatalf("unexpected file: %+v", f)
+	}
+	if !f.IsImage() || f.URL() != URLPrefix+f.Hash {
+		t.Fatalf("expected inline image at %s, got %+v", URLPrefix+f.Hash, f)
+	}
+
+	// 相同内容只存一份，沿用首次上传的文件名。
+	again, err := store.Save("other.png", bytes.NewReader(pngHeader), 1024)
+	if err != nil || again.Hash != f.Hash || again.Name != "shot.png" {
+		t.Fatalf("dedupe: %+v %v", again, err)
+	}
+	files, err := store.List()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("list: %+v %v", files, err)
+	}
+
+	blob, meta, err := store.Open(f.Hash)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer blob.Close()
+	if meta.Hash != f.Hash {
+		t.Fatalf("open meta: %+v", meta)
+	}
+
+	// 客户端声明的扩展名不影响嗅探结果。
+	html, err := store.Save("page.png", strings.NewReader("<html><script>alert(1)</script>"), 1024)
+	if err != nil || html.MIME != "text/html" || html.IsImage() {
+		t.Fatalf("sniffed html: %+v %v", html, err)
+	}
+}
+
+func TestSaveRejectsOversizeAndEmpty(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	if _, err := store.Save("big.bin", bytes.NewReader(make([]byte, 11)), 10); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversize: got %v, want ErrTooLarge", err)
+	}
+	if _, err := store.Save("exact.bin", bytes.NewReader(make([]byte, 10)), 10); err != nil {
+		t.Fatalf("at limit: %v", err)
+	}
+	if _, err := store.Save("empty.bin", strings.NewReader(""), 10); !errors.Is(err, ErrEmpty) {
+		t.Fatalf("empty: got %v, want ErrEmpty", err)
+	}
+	// 失败的上传不留下临时文件。
+	names, _ := os.ReadDir(filepath.Join(dir, DirName))
+	if len(names) != 2 {
+		t.Fatalf("expected one blob and its meta, got %d entries", len(names))
+	}
+}
+
+func TestRemoveUnreferenced(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	kept, _ := store.Save("kept.txt", strings.NewReader("kept"), 0)
+	orphan, _ := store.Save("orphan.txt", strings.NewReader("orphan"), 0)
+	fresh, _ := store.Save("fresh.txt", strings.NewReader("fresh"), 0)
+
+	// 把前两个附件的上传时间改到宽限期之前。
+	old := time.Now().Add(-48 * time.Hour)
+	for _, f := range []File{kept, orphan} {
+		f.UploadedAt = old
+		if err := store.writeMeta(f); err != nil {
+			t.Fatalf("backdate: %v", err)
+		}
+	}
+
+	removed, err := store.RemoveUnreferenced(map[string]bool{kept.Hash: true}, time.Now().Add(-24*time.Hour))
+	if err != nil || len(removed) != 1 || removed[0].Hash != orphan.Hash {
+		t.Fatalf("remove: %+v %v", removed, err)
+	}
+	if _, err := store.Stat(orphan.Hash); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("orphan should be gone: %v", err)
+	}
+	for _, f := range []File{kept, fresh} {
+		if _, err := store.Stat(f.Hash); err != nil {
+			t.Fatalf("%s should survive: %v", f.Name, err)
+		}
+	}
+}
+
+func TestReferences(t *testing.T) {
+	a := strings.Repeat("a", 64)
+	b := strings.Repeat("b", 64)
+	raw := "![x](/-/files/" + a + ") [y](/-/files/" + b + ") <img src=\"/-/files/" + a + "\"> /-/files/short"
+	got := References(raw)
+	if len(got) != 2 || got[0] != a || got[1] != b {
+		t.Fatalf("References = %v", got)
+	}
+	if ValidHash(strings.Repeat("A", 64)) || ValidHash("abc") {
+		t.Fatalf("ValidHash accepted an invalid hash")
+	}
+}
@@ -0,0 +1,187 @@
+// Package audit 以追加方式把后台操作记录写入 JSON Lines 文件，并支持按条件回查。
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FileName 为审计日志在内容目录下的文件名。扩展名不是 .json，不会被当作条目读取。
+const FileName = ".audit.jsonl"
+
+// 记录的操作类型。
+const (
+	LoginSuccess = "login.success"
+	LoginFailure = "login.failure"
+	LoginLockout = "login.lockout"
+	Logout       = "logout"
+
+	EntryCreate = "entry.create"
+	EntryUpdate = "entry.update"
+	EntryDelete = "entry.delete"
+	// EntryPublish 为发布草稿。
+	EntryPublish = "entry.publish"
+	// EntryRestore / EntryPurge 为从回收站恢复与永久删除；自动清理的操作者为 system。
+	EntryRestore = "entry.restore"
+	EntryPurge   = "entry.purge"
+
+	// FileUpload / FilePurge 为上传附件与清理不再被引用的附件。
+	FileUpload = "file.upload"
+	FilePurge  = "file.purge"
+
+	PasswordChange  = "settings.password"
+	TOTPEnable      = "settings.totp_enable"
+	TOTPDisable     = "settings.totp_disable"
+	TOTPRecovery    = "settings.totp_recovery"
+	PasskeyAdd      = "settings.passkey_add"
+	PasskeyDelete   = "settings.passkey_delete"
+	SessionRevoke   = "settings.session_revoke"
+	SessionsRevoked = "settings.session_revoke_all"
+	LockoutRelease  = "settings.lockout_release"
+)
+
+// Event 是一条审计记录。
+type Event struct {
+	Time   time.Time `json:"time"`
+	Action string    `json:"action"`
+	Actor  string    `json:"actor,omitempty"`
+	IP     string    `json:"ip,omitempty"`
+	Slug   string    `json:"slug,omitempty"`
+	// Hash 为操作涉及内容的 SHA-256，删除时是删除前的内容。
+	Hash   string `json:"hash,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// Log 是追加写入的审计日志文件，并发安全。
+type Log struct {
+	mu   sync.Mutex
+	path string
+}
+
+// Path 返回内容目录下的审计日志路径。
+func Path(contentDir string) string {
+	return filepath.Join(contentDir, FileName)
+}
+
+// New 返回写入 path 的审计日志；文件在首次写入时创建。
+func New(path string) *Log {
+	return &Log{path: path}
+}
+
+// Append 追加一条记录，Time 为空时取当前时间。
+func (l *Log) Append(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	e.Time = e.Time.UTC()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode audit event: %w", err)
+	}
+	line = append(line, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("open audit log: %w", err)
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write audit log: %w", err)
+	}
+	return f.Close()
+}
+
+// Filter 为查询条件，零值字段不参与过滤。
+type Filter struct {
+	// Action 匹配完整的操作名，或以 "." 分隔的前缀（如 "login" 匹配 login.failure）。
+	Action string
+	Actor  string
+	IP     string
+	Slug   string
+	Since  time.Time
+	Until  time.Time
+	// Limit 限制返回条数，<= 0 表示不限。
+	Limit int
+}
+
+// Match 报告记录是否满足条件。
+func (f Filter) Match(e Event) bool {
+	if f.Action != "" && e.Action != f.Action && !strings.HasPrefix(e.Action, f.Action+".") {
+		return false
+	}
+	if f.Actor != "" && !strings.EqualFold(e.Actor, f.Actor) {
+		return false
+	}
+	if f.IP != "" && e.IP != f.IP {
+		return false
+	}
+	if f.Slug != "" && e.Slug != f.Slug {
+		return false
+	}
+	if !f.Since.IsZero() && e.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Time.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Query 读取满足条件的记录，最新的在前。无法解析的行会被跳过，
+// 文件不存在时返回空结果。
+func (l *Log) Query(f Filter) ([]Event, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	file, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer file.Close()
+
+	var out []Event
+	sc := bufio.NewScanner(file)
+	sc.Buffer(make([]byte, 0, 64*1024), 1<<20)
+	for sc.Scan() {
+		var e Event
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		if f.Match(e) {
+			out = append(out, e)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("read audit log: %w", err)
+	}
+
+	// 文件按写入顺序追加，倒序即最新在前；稳定排序兼顾时钟回拨的情况。
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Time.After(out[j].Time) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
+
+// ContentHash 返回内容的 SHA-256 摘要，形如 "sha256:<hex>"。
+func ContentHash(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,83 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAppendAndQuery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	log := New(path)
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	events := []Event{
+		{Time: base, Action: LoginFailure, IP: "192.0.2.1"},
+		{Time: base.Add(time.Minute), Action: LoginSuccess, Actor: "admin", IP: "192.0.2.1"},
+		{Time: base.Add(2 * time.Minute), Action: EntryCreate, Actor: "admin", Slug: "abc", Hash: ContentHash("# Hi")},
+		{Time: base.Add(3 * time.Minute), Action: EntryDelete, Actor: "admin", Slug: "abc", Hash: ContentHash("# Hi")},
+	}
+	for _, e := range events {
+		if err := log.Append(e); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("stat: %v %v", info, err)
+	}
+
+	all, err := log.Query(Filter{})
+	if err != nil || len(all) != 4 || all[0].Action != EntryDelete {
+		t.Fatalf("query all: %v %+v", err, all)
+	}
+
+	cases := []struct {
+		name string
+		f    Filter
+		want int
+	}{
+		{"action prefix", Filter{Action: "login"}, 2},
+		{"exact action", Filter{Action: EntryDelete}, 1},
+		{"partial prefix does not match", Filter{Action: "log"}, 0},
+		{"slug", Filter{Slug: "abc"}, 2},
+		{"actor case-insensitive", Filter{Actor: "ADMIN"}, 3},
+		{"ip", Filter{IP: "192.0.2.1"}, 2},
+		{"since", Filter{Since: base.Add(90 * time.Second)}, 2},
+		{"until", Filter{Until: base.Add(time.Minute)}, 1},
+		{"limit", Filter{Limit: 3}, 3},
+	}
+	for _, c := range cases {
+		got, err := log.Query(c.f)
+		if err != nil || len(got) != c.want {
+			t.Errorf("%s: got %d events (%v), want %d", c.name, len(got), err, c.want)
+		}
+	}
+}
+
+func TestQuerySkipsMalformedLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	log := New(path)
+	if err := log.Append(Event{Action: Logout, Actor: "admin"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, _ = f.WriteString("{not json\n")
+	_ = f.Close()
+	if err := log.Append(Event{Action: Logout, Actor: "admin"}); err != nil {
+		t.Fatalf("append: %v", err)
+	}
+
+	got, err := log.Query(Filter{})
+	if err != nil || len(got) != 2 {
+		t.Fatalf("query: %v %+v", err, got)
+	}
+
+	missing, err := New(filepath.Join(t.TempDir(), "none.jsonl")).Query(Filter{})
+	if err != nil || len(missing) != 0 {
+		t.Fatalf("missing file: %v %+v", err, missing)
+	}
+}
@@ -0,0 +1,108 @@
+// Package certreload 提供可热更新的 TLS 证书：证书文件变化或收到 SIGHUP 时
+// 重新加载，无需重启进程即可完成续期。
+package certreload
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader 持有当前生效的证书，并可从磁盘重新加载。
+type Reloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	certMod time.Time
+	keyMod  time.Time
+}
+
+// New 加载证书与私钥并返回 Reloader。首次加载失败直接返回错误。
+func New(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload 从磁盘重新读取证书与私钥。失败时保留旧证书继续服务。
+func (r *Reloader) Reload() error {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load key pair: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.certMod = certMod
+	r.keyMod = keyMod
+	r.mu.Unlock()
+	return nil
+}
+
+// GetCertificate 实现 tls.Config.GetCertificate。
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Watch 按 interval 轮询证书与私钥的修改时间，变化时自动重新加载，直到 ctx 结束。
+// 轮询而非 inotify，兼容 Kubernetes Secret 的符号链接替换与各类网络文件系统。
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.changed()
+			if err != nil {
+				slog.Warn("stat tls certificate", "error", err)
+				continue
+			}
+			if !changed {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				slog.Error("reload tls certificate", "error", err)
+				continue
+			}
+			slog.Info("tls certificate reloaded", "cert", r.certFile)
+		}
+	}
+}
+
+func (r *Reloader) changed() (bool, error) {
+	certMod, keyMod, err := r.modTimes()
+	if err != nil {
+		return false, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !certMod.Equal(r.certMod) || !keyMod.Equal(r.keyMod), nil
+}
+
+func (r *Reloader) modTimes() (time.Time, time.Time, error) {
+	ci, err := os.Stat(r.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("stat cert file: %w", err)
+	}
+	ki, err := os.Stat(r.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("stat key file: %w", err)
+	}
+	return ci.ModTime(), ki.ModTime(), nil
+}
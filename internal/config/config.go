@@ -2,7 +2,14 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net/netip"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"minisnap/internal/password"
 )
 
 // Config 描述服务器运行时所需的关键配置。
@@ -10,6 +17,109 @@ type Config struct {
 	BindAddr      string
 	AdminPassword string
 	ContentDir    string
+
+	// AdminPasswordHash 为后台密码的 argon2id 或 bcrypt 哈希，设置后优先于明文
+	// AdminPassword。在后台修改过密码后，以内容目录中保存的新哈希为准。
+	AdminPasswordHash string
+
+	// SecretKey 用于加密保存在内容目录中的二次验证密钥等敏感状态；为空时使用
+	// 内容目录 .auth/secret.key 中随机生成的密钥。更换密钥会使已加密的状态无法解密。
+	SecretKey string
+
+	// PageCacheMaxAge 为匿名访客阅读页与源码端点的缓存时长；零值表示每次回源校验。
+	PageCacheMaxAge time.Duration
+	// AssetCacheMaxAge 为未带内容哈希的静态资源 URL 的缓存时长。
+	// 带哈希的资源 URL 始终按 immutable 长期缓存。
+	AssetCacheMaxAge time.Duration
+	// RenderCacheEntries 为已渲染 HTML 内存缓存的条目上限；零值关闭缓存。
+	RenderCacheEntries int
+
+	// MetricsToken 非空时，访问 /metrics 需携带 Authorization: Bearer <token>。
+	MetricsToken string
+	// MetricsAddr 非空时，/metrics 只在该独立地址上提供，不挂载到主监听地址。
+	// 两者都为空时不提供 /metrics。
+	MetricsAddr string
+
+	// DrainDelay 为收到关停信号后 /readyz 返回 503、但仍继续服务的时长，
+	// 留给负载均衡器摘除本实例。
+	DrainDelay time.Duration
+
+	// LogFormat 为日志格式（text 或 json），LogLevel 为最低输出级别。
+	LogFormat string
+	LogLevel  string
+
+	// ContentSecurityPolicy 覆盖后台等页面的默认 CSP，ViewContentSecurityPolicy
+	// 覆盖渲染用户内容的阅读页与预览的 CSP。{nonce} 会被替换为每请求随机值。
+	ContentSecurityPolicy     string
+	ViewContentSecurityPolicy string
+	// HSTSMaxAge 为 HTTPS 请求下发的 Strict-Transport-Security 时长；零值不发送。
+	HSTSMaxAge time.Duration
+
+	// SandboxHTML 为 true 时，原始 HTML 条目在阅读页中以沙箱 iframe 承载。
+	SandboxHTML bool
+	// ContentOrigin 为承载原始 HTML 条目的独立源（如 https://usercontent.example.com），
+	// 设置后隐含 SandboxHTML，且该域名上拒绝后台路由。
+	ContentOrigin string
+
+	// TLSCertFile / TLSKeyFile 同时设置时直接以 HTTPS 提供服务，证书文件变化
+	// （按 TLSReloadInterval 轮询）或收到 SIGHUP 时热加载。
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSReloadInterval time.Duration
+	// HTTPRedirectAddr 非空时额外监听该地址，把所有 HTTP 请求重定向到 HTTPS。
+	HTTPRedirectAddr string
+
+	// LoginMaxFails 次失败（LoginWindow 内）后锁定该 IP LoginLockout；再次被锁定时
+	// 时长翻倍，直至 LoginMaxLockout。LoginGlobalMaxFails 为窗口内全部 IP 的密码失败上限，
+	// 达到后所有密码登录（含管理员本人）都会被拒绝，任何人都能借此持续阻止登录，因此默认关闭。其余字段为零值时使用默认策略（5 次 / 1 分钟 / 1 分钟 / 24 小时）。
+	LoginMaxFails       int
+	LoginWindow         time.Duration
+	LoginLockout        time.Duration
+	LoginMaxLockout     time.Duration
+	LoginGlobalMaxFails int
+
+	// SessionTTL 为普通会话的闲置超时，SessionRememberTTL 为登录时勾选“记住我”的
+	// 闲置超时；每次访问都会顺延。零值分别使用 12 小时与 30 天。
+	SessionTTL         time.Duration
+	SessionRememberTTL time.Duration
+
+	// TrashRetention 为已删除条目在回收站中的保留时长，到期后自动永久删除；零值表示不自动清理。
+	TrashRetention time.Duration
+
+	// UploadMaxSize 为编辑器单个上传附件的字节上限；零值使用 10 MiB。
+	UploadMaxSize int64
+	// MaxEntrySize 为单篇条目内容的字节上限，同时决定编辑表单请求体的上限；零值使用 1 MiB。
+	MaxEntrySize int64
+	// StorageQuota 为内容目录（条目、回收站、附件与状态文件）的总字节配额；零值表示不限制。
+	StorageQuota int64
+
+	// WebAuthnOrigin 固定通行密钥校验使用的来源（如 https://snap.example.com），
+	// 其主机名即 RP ID。为空时按请求的 Host 与协议推断。
+	WebAuthnOrigin string
+
+	// OIDCIssuer 非空时启用 OpenID Connect 单点登录（授权码 + PKCE）。只有邮箱在
+	// OIDCAllowedEmails 中、或属于 OIDCAllowedGroups 任一分组的用户可以登录。
+	// OIDCRedirectURL 为空时按请求推断 /login/oidc/callback。
+	OIDCIssuer        string
+	OIDCClientID      string
+	OIDCClientSecret  string
+	OIDCRedirectURL   string
+	OIDCAllowedEmails []string
+	OIDCAllowedGroups []string
+
+	// TrustedProxies 为可信反向代理的网段。只有直连对端落在其中时，才采信
+	// X-Forwarded-For / X-Real-IP / Forwarded / X-Forwarded-Proto 等转发头。
+	TrustedProxies []netip.Prefix
+}
+
+// TLSEnabled 报告是否配置了原生 TLS。
+func (c Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" && c.TLSKeyFile != ""
+}
+
+// OIDCEnabled 报告是否配置了单点登录。
+func (c Config) OIDCEnabled() bool {
+	return c.OIDCIssuer != ""
 }
 
 // Load 从环境变量读取配置，并提供合理的默认值。
@@ -17,11 +127,116 @@ func Load() (Config, error) {
 	cfg := Config{
 		BindAddr:   getEnvDefault("BIND_ADDR", ":8080"),
 		ContentDir: getEnvDefault("CONTENT_DIR", "content"),
+		SecretKey:  os.Getenv("SECRET_KEY"),
+
+		MetricsToken: os.Getenv("METRICS_TOKEN"),
+		MetricsAddr:  os.Getenv("METRICS_ADDR"),
+
+		LogFormat: getEnvDefault("LOG_FORMAT", "text"),
+		LogLevel:  getEnvDefault("LOG_LEVEL", "info"),
+
+		ContentSecurityPolicy:     os.Getenv("CSP"),
+		ViewContentSecurityPolicy: os.Getenv("CSP_VIEW"),
+
+		ContentOrigin: os.Getenv("CONTENT_ORIGIN"),
+
+		TLSCertFile:      os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:       os.Getenv("TLS_KEY_FILE"),
+		HTTPRedirectAddr: os.Getenv("HTTP_REDIRECT_ADDR"),
+
+		WebAuthnOrigin: os.Getenv("WEBAUTHN_ORIGIN"),
+
+		OIDCIssuer:        os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:      os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:  os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCRedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+		OIDCAllowedEmails: getEnvList("OIDC_ALLOWED_EMAILS"),
+		OIDCAllowedGroups: getEnvList("OIDC_ALLOWED_GROUPS"),
 	}
 
 	cfg.AdminPassword = os.Getenv("ADMIN_PASSWORD")
-	if cfg.AdminPassword == "" {
-		return Config{}, errors.New("ADMIN_PASSWORD is required")
+	cfg.AdminPasswordHash = os.Getenv("ADMIN_PASSWORD_HASH")
+	if cfg.AdminPassword == "" && cfg.AdminPasswordHash == "" {
+		return Config{}, errors.New("ADMIN_PASSWORD_HASH or ADMIN_PASSWORD is required")
+	}
+	if cfg.AdminPasswordHash != "" {
+		if err := password.Check(cfg.AdminPasswordHash); err != nil {
+			return Config{}, fmt.Errorf("invalid ADMIN_PASSWORD_HASH: %w", err)
+		}
+	}
+
+	var err error
+	if cfg.PageCacheMaxAge, err = getEnvDuration("PAGE_CACHE_MAX_AGE", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.AssetCacheMaxAge, err = getEnvDuration("ASSET_CACHE_MAX_AGE", time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.RenderCacheEntries, err = getEnvInt("RENDER_CACHE_ENTRIES", 256); err != nil {
+		return Config{}, err
+	}
+	if cfg.DrainDelay, err = getEnvDuration("DRAIN_DELAY", 5*time.Second); err != nil {
+		return Config{}, err
+	}
+	if cfg.HSTSMaxAge, err = getEnvDuration("HSTS_MAX_AGE", 180*24*time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.SandboxHTML, err = getEnvBool("SANDBOX_HTML", false); err != nil {
+		return Config{}, err
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		return Config{}, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if cfg.HTTPRedirectAddr != "" && !cfg.TLSEnabled() {
+		return Config{}, errors.New("HTTP_REDIRECT_ADDR requires TLS_CERT_FILE and TLS_KEY_FILE")
+	}
+	if cfg.TLSReloadInterval, err = getEnvDuration("TLS_RELOAD_INTERVAL", time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginMaxFails, err = getEnvInt("LOGIN_MAX_FAILS", 5); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginWindow, err = getEnvDuration("LOGIN_WINDOW", time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginLockout, err = getEnvDuration("LOGIN_LOCKOUT", time.Minute); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginMaxLockout, err = getEnvDuration("LOGIN_MAX_LOCKOUT", 24*time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.LoginGlobalMaxFails, err = getEnvInt("LOGIN_GLOBAL_MAX_FAILS", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.SessionTTL, err = getEnvDuration("SESSION_TTL", 12*time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.SessionRememberTTL, err = getEnvDuration("SESSION_REMEMBER_TTL", 30*24*time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.TrashRetention, err = getEnvDuration("TRASH_RETENTION", 30*24*time.Hour); err != nil {
+		return Config{}, err
+	}
+	if cfg.UploadMaxSize, err = getEnvSize("UPLOAD_MAX_SIZE", 10<<20); err != nil {
+		return Config{}, err
+	}
+	if cfg.MaxEntrySize, err = getEnvSize("MAX_ENTRY_SIZE", 1<<20); err != nil {
+		return Config{}, err
+	}
+	if cfg.StorageQuota, err = getEnvSize("STORAGE_QUOTA", 0); err != nil {
+		return Config{}, err
+	}
+	if cfg.TrustedProxies, err = getEnvPrefixes("TRUSTED_PROXIES"); err != nil {
+		return Config{}, err
+	}
+	if cfg.OIDCEnabled() {
+		if cfg.OIDCClientID == "" {
+			return Config{}, errors.New("OIDC_CLIENT_ID is required when OIDC_ISSUER is set")
+		}
+		// 不设白名单等于允许身份提供方中的任何人登录，直接拒绝这种配置。
+		if len(cfg.OIDCAllowedEmails) == 0 && len(cfg.OIDCAllowedGroups) == 0 {
+			return Config{}, errors.New("OIDC_ALLOWED_EMAILS or OIDC_ALLOWED_GROUPS is required when OIDC_ISSUER is set")
+		}
 	}
 
 	return cfg, nil
@@ -33,3 +248,109 @@ func getEnvDefault(key, fallback string) string {
 	}
 	return fallback
 }
+
+func getEnvDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, v)
+	}
+	return d, nil
+}
+
+func getEnvInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s: %q", key, v)
+	}
+	return n, nil
+}
+
+func getEnvBool(key string, fallback bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %q", key, v)
+	}
+	return b, nil
+}
+
+// sizeUnits 为 getEnvSize 支持的单位后缀，按 1024 进制换算；较长的后缀排在前面以优先匹配。
+var sizeUnits = []struct {
+	suffix string
+	scale  int64
+}{
+	{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10},
+	{"G", 1 << 30}, {"M", 1 << 20}, {"K", 1 << 10}, {"B", 1},
+}
+
+// getEnvSize 解析字节数，支持 B/KB/MB/GB（或 K/M/G）后缀，不带后缀时按字节计。
+func getEnvSize(key string, fallback int64) (int64, error) {
+	v := strings.TrimSpace(os.Getenv(key))
+	if v == "" {
+		return fallback, nil
+	}
+	num, scale := strings.ToUpper(v), int64(1)
+	for _, u := range sizeUnits {
+		if trimmed, ok := strings.CutSuffix(num, u.suffix); ok {
+			num, scale = strings.TrimSpace(trimmed), u.scale
+			break
+		}
+	}
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 || n > (1<<62)/scale {
+		return 0, fmt.Errorf("invalid %s: %q", key, v)
+	}
+	return n * scale, nil
+}
+
+// getEnvList 解析逗号分隔的列表，忽略空项。
+func getEnvList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvPrefixes 解析逗号分隔的 CIDR 列表；单个 IP 视为 /32 或 /128。
+func getEnvPrefixes(key string) ([]netip.Prefix, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return nil, nil
+	}
+	var prefixes []netip.Prefix
+	for _, item := range strings.Split(v, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s entry: %q", key, item)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s entry: %q", key, item)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
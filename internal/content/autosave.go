@@ -0,0 +1,130 @@
+package content
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// autosaveDirName 为编辑器自动保存的目录名，位于内容目录下；List 跳过子目录，不会读到其中的文件。
+const autosaveDirName = ".autosave"
+
+// NewEntryAutosave 为新建条目编辑器的自动保存键。生成的 slug 为 8 位，不会与之冲突。
+const NewEntryAutosave = "new"
+
+// ErrAutosaveNotFound 表示没有对应的自动保存。
+var ErrAutosaveNotFound = errors.New("autosave not found")
+
+// Autosave 是编辑器中尚未保存的内容，与已保存的条目分开存放，保存条目后即被丢弃。
+type Autosave struct {
+	// Key 为条目 slug，新建条目为 NewEntryAutosave。
+	Key         string       `json:"key"`
+	Renderer    RendererType `json:"renderer"`
+	Raw         string       `json:"raw"`
+	Description string       `json:"description,omitempty"`
+	SavedAt     time.Time    `json:"saved_at"`
+}
+
+// SaveAutosave 覆盖写入一份自动保存。Key 为 slug 时条目必须存在。
+func (s *Store) SaveAutosave(a Autosave) (_ Autosave, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.observe("autosave", err) }()
+
+	if !validSlug(a.Key) {
+		return Autosave{}, ErrEntryNotFound
+	}
+	if a.Key != NewEntryAutosave && !exists(s.entryPath(a.Key)) {
+		return Autosave{}, ErrEntryNotFound
+	}
+	if err := validateRenderer(a.Renderer); err != nil {
+		return Autosave{}, err
+	}
+	if err := os.MkdirAll(filepath.Join(s.root, autosaveDirName), 0o755); err != nil {
+		return Autosave{}, fmt.Errorf("create autosave dir: %w", err)
+	}
+	a.Description = strings.TrimSpace(a.Description)
+	a.SavedAt = time.Now().UTC()
+	if err := writeJSON(s.autosavePath(a.Key), &a); err != nil {
+		return Autosave{}, err
+	}
+	return a, nil
+}
+
+// Autosave 读取指定键的自动保存，不存在时返回 ErrAutosaveNotFound。
+func (s *Store) Autosave(key string) (Autosave, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if !validSlug(key) {
+		return Autosave{}, ErrAutosaveNotFound
+	}
+	raw, err := os.ReadFile(s.autosavePath(key))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Autosave{}, ErrAutosaveNotFound
+		}
+		return Autosave{}, fmt.Errorf("read autosave: %w", err)
+	}
+	var a Autosave
+	if err := json.Unmarshal(raw, &a); err != nil {
+		return Autosave{}, fmt.Errorf("decode autosave: %w", err)
+	}
+	return a, nil
+}
+
+// Autosaves 返回全部自动保存，目录不存在时返回空列表。
+func (s *Store) Autosaves() ([]Autosave, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files, err := os.ReadDir(filepath.Join(s.root, autosaveDirName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read autosave dir: %w", err)
+	}
+	items := make([]Autosave, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		raw, err := os.ReadFile(filepath.Join(s.root, autosaveDirName, f.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read autosave: %w", err)
+		}
+		var a Autosave
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return nil, fmt.Errorf("decode autosave %s: %w", f.Name(), err)
+		}
+		items = append(items, a)
+	}
+	return items, nil
+}
+
+// DiscardAutosave 删除指定键的自动保存，不存在时视为成功。
+func (s *Store) DiscardAutosave(key string) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.observe("autosave", err) }()
+	return s.removeAutosave(key)
+}
+
+func (s *Store) removeAutosave(key string) error {
+	if !validSlug(key) {
+		return nil
+	}
+	if err := os.Remove(s.autosavePath(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove autosave: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) autosavePath(key string) string {
+	return filepath.Join(s.root, autosaveDirName, key+".json")
+}
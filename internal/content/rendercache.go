@@ -0,0 +1,143 @@
+package content
+
+import (
+	"container/list"
+	"html/template"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// RenderCache 是已消毒 HTML 的有界 LRU 缓存，避免热门条目每次访问都重新
+// 执行 goldmark 渲染与 bluemonday 消毒。
+//
+// 每个 slug 至多保留一份产物；缓存键包含 UpdatedAt、渲染器与 RendererVersion，
+// 条目更新或渲染策略升级后旧产物自然失效。
+type RenderCache struct {
+	mu       sync.Mutex
+	capacity int
+	render   RenderFunc
+	order    *list.List               // 前端为最近使用
+	items    map[string]*list.Element // slug -> *cacheItem
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+type cacheItem struct {
+	slug    string
+	version string
+	html    template.HTML
+}
+
+// RenderCacheStats 是缓存命中情况的快照。
+type RenderCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Entries   int
+}
+
+// RenderFunc 将 Entry 渲染为已消毒 HTML，签名与 RenderHTML 一致。
+type RenderFunc func(Entry) (template.HTML, error)
+
+// NewRenderCache 创建最多容纳 capacity 个条目的缓存；capacity 必须为正数。
+// render 为未命中时使用的渲染函数，nil 表示直接使用 RenderHTML。
+func NewRenderCache(capacity int, render RenderFunc) *RenderCache {
+	if capacity < 1 {
+		capacity = 1
+	}
+	if render == nil {
+		render = RenderHTML
+	}
+	return &RenderCache{
+		capacity: capacity,
+		render:   render,
+		order:    list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+// Render 返回条目的已消毒 HTML，未命中时调用渲染函数并写入缓存。
+// 没有 slug 的条目（如预览）不缓存。
+func (c *RenderCache) Render(entry Entry) (template.HTML, error) {
+	if entry.Slug == "" {
+		return c.render(entry)
+	}
+
+	version := cacheVersion(entry)
+	if html, ok := c.get(entry.Slug, version); ok {
+		c.hits.Add(1)
+		return html, nil
+	}
+	c.misses.Add(1)
+
+	html, err := c.render(entry)
+	if err != nil {
+		return "", err
+	}
+	c.put(entry.Slug, version, html)
+	return html, nil
+}
+
+// Invalidate 丢弃指定 slug 的缓存产物。
+func (c *RenderCache) Invalidate(slugID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[slugID]; ok {
+		c.order.Remove(el)
+		delete(c.items, slugID)
+	}
+}
+
+// Stats 返回当前的命中统计。
+func (c *RenderCache) Stats() RenderCacheStats {
+	c.mu.Lock()
+	n := c.order.Len()
+	c.mu.Unlock()
+	return RenderCacheStats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+		Entries:   n,
+	}
+}
+
+func (c *RenderCache) get(slugID, version string) (template.HTML, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[slugID]
+	if !ok {
+		return "", false
+	}
+	item := el.Value.(*cacheItem)
+	if item.version != version {
+		return "", false
+	}
+	c.order.MoveToFront(el)
+	return item.html, true
+}
+
+func (c *RenderCache) put(slugID, version string, html template.HTML) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[slugID]; ok {
+		item := el.Value.(*cacheItem)
+		item.version = version
+		item.html = html
+		c.order.MoveToFront(el)
+		return
+	}
+	c.items[slugID] = c.order.PushFront(&cacheItem{slug: slugID, version: version, html: html})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem).slug)
+		c.evictions.Add(1)
+	}
+}
+
+func cacheVersion(entry Entry) string {
+	return RendererVersion + "|" + string(entry.Renderer) + "|" + strconv.FormatInt(entry.UpdatedAt.UnixNano(), 10)
+}
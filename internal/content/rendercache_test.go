@@ -0,0 +1,96 @@
+package content
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderCacheHitAndMiss(t *testing.T) {
+	c := NewRenderCache(4, nil)
+	entry := Entry{Slug: "abc", Renderer: RendererMarkdown, Raw: "# One", UpdatedAt: time.Now()}
+
+	if _, err := c.Render(entry); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	html, err := c.Render(entry)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(string(html), "One") {
+		t.Fatalf("unexpected html: %s", html)
+	}
+	st := c.Stats()
+	if st.Hits != 1 || st.Misses != 1 || st.Entries != 1 {
+		t.Fatalf("stats = %+v, want 1 hit, 1 miss, 1 entry", st)
+	}
+}
+
+func TestRenderCacheUpdatedAtChangesKey(t *testing.T) {
+	c := NewRenderCache(4, nil)
+	entry := Entry{Slug: "abc", Renderer: RendererMarkdown, Raw: "# One", UpdatedAt: time.Now()}
+	if _, err := c.Render(entry); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+
+	entry.Raw = "# Two"
+	entry.UpdatedAt = entry.UpdatedAt.Add(time.Second)
+	html, err := c.Render(entry)
+	if err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if !strings.Contains(string(html), "Two") {
+		t.Fatalf("expected fresh render after UpdatedAt change, got: %s", html)
+	}
+	if st := c.Stats(); st.Entries != 1 || st.Misses != 2 {
+		t.Fatalf("stats = %+v, want one entry per slug and 2 misses", st)
+	}
+}
+
+func TestRenderCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := NewRenderCache(2, nil)
+	now := time.Now()
+	a := Entry{Slug: "a", Renderer: RendererMarkdown, Raw: "a", UpdatedAt: now}
+	b := Entry{Slug: "b", Renderer: RendererMarkdown, Raw: "b", UpdatedAt: now}
+	d := Entry{Slug: "d", Renderer: RendererMarkdown, Raw: "d", UpdatedAt: now}
+
+	for _, e := range []Entry{a, b, a, d} {
+		if _, err := c.Render(e); err != nil {
+			t.Fatalf("render %s: %v", e.Slug, err)
+		}
+	}
+	// b 最久未使用，应被淘汰；a 仍命中
+	if _, err := c.Render(a); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	st := c.Stats()
+	if st.Evictions != 1 || st.Entries != 2 {
+		t.Fatalf("stats = %+v, want 1 eviction and 2 entries", st)
+	}
+	if st.Hits != 2 {
+		t.Fatalf("expected a to stay cached, stats = %+v", st)
+	}
+}
+
+func TestRenderCacheInvalidatedByStore(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	c := NewRenderCache(4, nil)
+	store.OnChange(c.Invalidate)
+
+	entry, err := store.Create(RendererMarkdown, "# Hi", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := c.Render(entry); err != nil {
+		t.Fatalf("render: %v", err)
+	}
+	if err := store.Delete(entry.Slug); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if st := c.Stats(); st.Entries != 0 {
+		t.Fatalf("expected delete to invalidate cache, stats = %+v", st)
+	}
+}
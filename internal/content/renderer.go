@@ -11,6 +11,10 @@ import (
 
 var md = goldmark.New()
 
+// RendererVersion 标识渲染管线（goldmark 配置与消毒策略）的版本。
+// 修改下方任一策略后须递增，使 RenderCache 中的旧产物失效。
+const RendererVersion = "1"
+
 // markdownPolicy 用于消毒 Markdown 渲染产物。
 // 保留富文本格式（标题、段落、列表、表格、图片、链接、强调等），
 // 剥离 <script>、内联事件处理器、javascript: 链接等危险内容。
@@ -17,6 +17,12 @@ import (
 
 var ErrEntryNotFound = errors.New("entry not found")
 
+// ErrEntryExists 表示恢复回收站条目时同名 slug 已被占用。
+var ErrEntryExists = errors.New("entry already exists")
+
+// trashDirName 为回收站目录名，位于内容目录下；List 跳过子目录，不会读到其中的条目。
+const trashDirName = ".trash"
+
 // RendererType 表示内容渲染器。
 type RendererType string
 
@@ -33,12 +39,25 @@ type Entry struct {
 	Description string       `json:"description,omitempty"`
 	CreatedAt   time.Time    `json:"created_at"`
 	UpdatedAt   time.Time    `json:"updated_at"`
+	// Draft 为 true 时条目尚未发布，只能在后台查看与编辑。
+	Draft bool `json:"draft,omitempty"`
+}
+
+// TrashedEntry 是回收站中的条目，DeletedAt 为移入回收站的时间。
+type TrashedEntry struct {
+	Entry
+	DeletedAt time.Time `json:"deleted_at"`
 }
 
 // Store 负责将 Entry 持久化到文件系统。
 type Store struct {
 	root string
 	mu   sync.RWMutex
+
+	// listeners 在条目被修改或删除后调用，用于失效渲染缓存等派生数据。
+	listeners []func(slugID string)
+	// observers 在每次公开操作结束后调用，用于指标统计。
+	observers []func(op string, err error)
 }
 
 // NewStore 创建一个指向指定目录的 Store，目录不存在会自动创建。
@@ -52,20 +71,64 @@ func NewStore(root string) (*Store, error) {
 	return &Store{root: root}, nil
 }
 
-// Create 新建一篇内容并返回持久化后的 Entry。
+// OnChange 注册一个回调，在条目更新或删除成功后以 slug 调用。
+// 回调在持有存储写锁时执行，不得再调用 Store 的方法。
+func (s *Store) OnChange(fn func(slugID string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *Store) notify(slugID string) {
+	for _, fn := range s.listeners {
+		fn(slugID)
+	}
+}
+
+// OnOperation 注册一个回调，在每次 Create/Update/Get/List/Delete 结束后
+// 以操作名与结果错误调用。回调同样在持有存储锁时执行。
+func (s *Store) OnOperation(fn func(op string, err error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.observers = append(s.observers, fn)
+}
+
+func (s *Store) observe(op string, err error) {
+	for _, fn := range s.observers {
+		fn(op, err)
+	}
+}
+
+// Dir 返回内容目录路径。
+func (s *Store) Dir() string {
+	return s.root
+}
+
+// Create 新建一篇已发布的内容并返回持久化后的 Entry。
 func (s *Store) Create(renderer RendererType, raw string, description string) (Entry, error) {
+	return s.create(renderer, raw, description, false)
+}
+
+// CreateDraft 新建一篇草稿，发布前不会出现在公开路由中。
+func (s *Store) CreateDraft(renderer RendererType, raw string, description string) (Entry, error) {
+	return s.create(renderer, raw, description, true)
+}
+
+func (s *Store) create(renderer RendererType, raw string, description string, draft bool) (_ Entry, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer func() { s.observe("create", err) }()
 
 	if err := validateRenderer(renderer); err != nil {
 		return Entry{}, err
 	}
 	description = strings.TrimSpace(description)
 
+	// 回收站中的 slug 同样视为占用，保证恢复时不会与新条目冲突。
 	var slugID string
 	for i := 0; i < 5; i++ {
 		candidate := slug.New()
-		if _, err := os.Stat(s.entryPath(candidate)); errors.Is(err, os.ErrNotExist) {
+		if !exists(s.entryPath(candidate)) && !exists(s.trashPath(candidate)) {
 			slugID = candidate
 			break
 		}
@@ -81,6 +144,7 @@ func (s *Store) Create(renderer RendererType, raw string, description string) (E
 		Description: description,
 		CreatedAt:   time.Now().UTC(),
 		UpdatedAt:   time.Now().UTC(),
+		Draft:       draft,
 	}
 
 	if err := s.persist(entry); err != nil {
@@ -90,10 +154,11 @@ func (s *Store) Create(renderer RendererType, raw string, description string) (E
 	return entry, nil
 }
 
-// Update 覆盖现有内容。
-func (s *Store) Update(slugID string, renderer RendererType, raw string, description string) (Entry, error) {
+// Update 覆盖现有内容，不改变其草稿状态。
+func (s *Store) Update(slugID string, renderer RendererType, raw string, description string) (_ Entry, err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer func() { s.observe("update", err) }()
 
 	if err := validateRenderer(renderer); err != nil {
 		return Entry{}, err
@@ -113,21 +178,45 @@ func (s *Store) Update(slugID string, renderer RendererType, raw string, descrip
 	if err := s.persist(existing); err != nil {
 		return Entry{}, err
 	}
+	s.notify(slugID)
 
 	return existing, nil
 }
 
+// Publish 发布草稿：清除草稿标记，并以发布时间作为创建与更新时间。
+// 已发布的条目原样返回。
+func (s *Store) Publish(slugID string) (_ Entry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.observe("publish", err) }()
+
+	entry, err := s.read(slugID)
+	if err != nil || !entry.Draft {
+		return entry, err
+	}
+	now := time.Now().UTC()
+	entry.Draft = false
+	entry.CreatedAt, entry.UpdatedAt = now, now
+	if err := s.persist(entry); err != nil {
+		return Entry{}, err
+	}
+	s.notify(slugID)
+	return entry, nil
+}
+
 // Get 读取指定 slug 的内容。
-func (s *Store) Get(slugID string) (Entry, error) {
+func (s *Store) Get(slugID string) (_ Entry, err error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	defer func() { s.observe("get", err) }()
 	return s.read(slugID)
 }
 
 // List 返回所有内容，按创建时间倒序排列。
-func (s *Store) List() ([]Entry, error) {
+func (s *Store) List() (_ []Entry, err error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	defer func() { s.observe("list", err) }()
 
 	files, err := os.ReadDir(s.root)
 	if err != nil {
@@ -162,12 +251,96 @@ func (s *Store) List() ([]Entry, error) {
 	return entries, nil
 }
 
+// Count 返回内容目录中的条目文件数，不解析文件内容。
+func (s *Store) Count() (int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	files, err := os.ReadDir(s.root)
+	if err != nil {
+		return 0, fmt.Errorf("read content dir: %w", err)
+	}
+	n := 0
+	for _, f := range files {
+		if !f.IsDir() && filepath.Ext(f.Name()) == ".json" {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Probe 以与 persist 相同的“临时文件 + 重命名”方式在内容目录中做一次读写往返，
+// 用于就绪检查确认目录可读可写。探测文件名随机生成，并发探测互不干扰；
+// 文件不以 .json 结尾，不会被 List 读到，结束后即删除。
+func (s *Store) Probe() error {
+	if _, err := os.ReadDir(s.root); err != nil {
+		return fmt.Errorf("read content dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.root, ".readyz-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create probe file: %w", err)
+	}
+	tmpPath := tmp.Name()
+	want := []byte(time.Now().UTC().Format(time.RFC3339Nano))
+	_, werr := tmp.Write(want)
+	if cerr := tmp.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("write probe file: %w", werr)
+	}
+	path := strings.TrimSuffix(tmpPath, ".tmp") + ".probe"
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return fmt.Errorf("rename probe file: %w", err)
+	}
+	defer os.Remove(path)
+
+	got, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read probe file: %w", err)
+	}
+	if string(got) != string(want) {
+		return errors.New("probe file content mismatch")
+	}
+	return nil
+}
+
+// validSlug 只接受小写字母与数字。slug 来自 URL 与表单，ServeMux 会把 %2F 解码进
+// {slug}，拼接路径前必须校验，防止读到回收站、自动保存等内容目录下的其他文件。
+func validSlug(slugID string) bool {
+	if slugID == "" || len(slugID) > 64 {
+		return false
+	}
+	for _, c := range slugID {
+		if (c < 'a' || c > 'z') && (c < '0' || c > '9') {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *Store) entryPath(slugID string) string {
 	return filepath.Join(s.root, fmt.Sprintf("%s.json", slugID))
 }
 
+func (s *Store) trashPath(slugID string) string {
+	return filepath.Join(s.root, trashDirName, fmt.Sprintf("%s.json", slugID))
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return !errors.Is(err, os.ErrNotExist)
+}
+
 func (s *Store) persist(entry Entry) error {
-	path := s.entryPath(entry.Slug)
+	return writeJSON(s.entryPath(entry.Slug), &entry)
+}
+
+// writeJSON 以“临时文件 + 重命名”的方式原子写入 JSON 文件。
+func writeJSON(path string, v any) error {
 	tmpPath := path + ".tmp"
 
 	file, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
@@ -176,7 +349,7 @@ func (s *Store) persist(entry Entry) error {
 	}
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(&entry); err != nil {
+	if err := encoder.Encode(v); err != nil {
 		_ = file.Close()
 		return fmt.Errorf("encode entry: %w", err)
 	}
@@ -190,6 +363,9 @@ func (s *Store) persist(entry Entry) error {
 }
 
 func (s *Store) read(slugID string) (Entry, error) {
+	if !validSlug(slugID) {
+		return Entry{}, ErrEntryNotFound
+	}
 	path := s.entryPath(slugID)
 	file, err := os.Open(path)
 	if err != nil {
@@ -207,21 +383,156 @@ func (s *Store) read(slugID string) (Entry, error) {
 	return entry, nil
 }
 
-// Delete 移除指定 slug 的内容。
-func (s *Store) Delete(slugID string) error {
+// Delete 把指定 slug 的内容移入回收站，可通过 Restore 恢复。
+func (s *Store) Delete(slugID string) (err error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer func() { s.observe("delete", err) }()
 
-	path := s.entryPath(slugID)
-	if err := os.Remove(path); err != nil {
+	entry, err := s.read(slugID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(s.root, trashDirName), 0o755); err != nil {
+		return fmt.Errorf("create trash dir: %w", err)
+	}
+	// 先写回收站再删原文件，中途失败时至多留下两份，而不会丢失内容。
+	if err := writeJSON(s.trashPath(slugID), &TrashedEntry{Entry: entry, DeletedAt: time.Now().UTC()}); err != nil {
+		return err
+	}
+	if err := os.Remove(s.entryPath(slugID)); err != nil {
+		return fmt.Errorf("delete entry: %w", err)
+	}
+	s.notify(slugID)
+	return nil
+}
+
+// Trash 返回回收站中的条目，最近删除的在前。
+func (s *Store) Trash() (_ []TrashedEntry, err error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	defer func() { s.observe("trash", err) }()
+
+	files, err := os.ReadDir(filepath.Join(s.root, trashDirName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read trash dir: %w", err)
+	}
+	items := make([]TrashedEntry, 0, len(files))
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+		slugID := strings.TrimSuffix(f.Name(), filepath.Ext(f.Name()))
+		item, err := s.readTrashed(slugID)
+		if err != nil {
+			slog.Warn("skipping unreadable trash entry", "slug", slugID, "error", err)
+			continue
+		}
+		items = append(items, item)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].DeletedAt.After(items[j].DeletedAt) })
+	return items, nil
+}
+
+// Restore 把回收站中的条目恢复到内容目录，保留原有的创建与更新时间及草稿状态。
+func (s *Store) Restore(slugID string) (_ Entry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.observe("restore", err) }()
+
+	item, err := s.readTrashed(slugID)
+	if err != nil {
+		return Entry{}, err
+	}
+	if exists(s.entryPath(slugID)) {
+		return Entry{}, ErrEntryExists
+	}
+	if err := s.persist(item.Entry); err != nil {
+		return Entry{}, err
+	}
+	if err := os.Remove(s.trashPath(slugID)); err != nil {
+		return Entry{}, fmt.Errorf("remove trash entry: %w", err)
+	}
+	s.notify(slugID)
+	return item.Entry, nil
+}
+
+// Purge 从回收站永久删除指定条目，返回被删除的条目。
+func (s *Store) Purge(slugID string) (_ TrashedEntry, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.observe("purge", err) }()
+	item, err := s.readTrashed(slugID)
+	if err != nil {
+		return TrashedEntry{}, err
+	}
+	return item, s.removeTrashed(slugID)
+}
+
+// PurgeBefore 永久删除在 cutoff 之前移入回收站的条目，返回被删除的条目。
+func (s *Store) PurgeBefore(cutoff time.Time) (_ []TrashedEntry, err error) {
+	items, err := s.Trash()
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer func() { s.observe("purge", err) }()
+	var purged []TrashedEntry
+	for _, item := range items {
+		if !item.DeletedAt.Before(cutoff) {
+			continue
+		}
+		if err := s.removeTrashed(item.Slug); err != nil {
+			if errors.Is(err, ErrEntryNotFound) {
+				continue // 期间已被恢复或手动删除
+			}
+			return purged, err
+		}
+		purged = append(purged, item)
+	}
+	return purged, nil
+}
+
+func (s *Store) removeTrashed(slugID string) error {
+	if !validSlug(slugID) {
+		return ErrEntryNotFound
+	}
+	if err := os.Remove(s.trashPath(slugID)); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return ErrEntryNotFound
 		}
-		return fmt.Errorf("delete entry: %w", err)
+		return fmt.Errorf("purge entry: %w", err)
+	}
+	// 条目永久删除后，其自动保存的编辑也不再有意义。
+	if err := s.removeAutosave(slugID); err != nil {
+		slog.Warn("remove autosave of purged entry", "slug", slugID, "error", err)
 	}
 	return nil
 }
 
+func (s *Store) readTrashed(slugID string) (TrashedEntry, error) {
+	if !validSlug(slugID) {
+		return TrashedEntry{}, ErrEntryNotFound
+	}
+	raw, err := os.ReadFile(s.trashPath(slugID))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return TrashedEntry{}, ErrEntryNotFound
+		}
+		return TrashedEntry{}, fmt.Errorf("read trash entry: %w", err)
+	}
+	var item TrashedEntry
+	if err := json.Unmarshal(raw, &item); err != nil {
+		return TrashedEntry{}, fmt.Errorf("decode trash entry: %w", err)
+	}
+	return item, nil
+}
+
 func validateRenderer(renderer RendererType) error {
 	switch renderer {
 	case RendererMarkdown, RendererHTML:
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 )
@@ -156,4 +157,220 @@ func TestStoreDelete(t *testing.T) {
 	if _, err := store.Get(entry.Slug); !errors.Is(err, ErrEntryNotFound) {
 		t.Fatalf("expected get to return ErrEntryNotFound, got %v", err)
 	}
+	if err := store.Delete(entry.Slug); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("expected second delete to return ErrEntryNotFound, got %v", err)
+	}
+
+	// 删除只是移入回收站，内容与时间戳原样保留
+	trash, err := store.Trash()
+	if err != nil || len(trash) != 1 {
+		t.Fatalf("trash: %v %+v", err, trash)
+	}
+	if trash[0].Slug != entry.Slug || trash[0].Raw != "hello" || trash[0].DeletedAt.IsZero() {
+		t.Fatalf("unexpected trash item: %+v", trash[0])
+	}
+	if n, _ := store.Count(); n != 0 {
+		t.Fatalf("trashed entry should not be counted, got %d", n)
+	}
+
+	restored, err := store.Restore(entry.Slug)
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if !restored.CreatedAt.Equal(entry.CreatedAt) || restored.Description != "desc" {
+		t.Fatalf("restore should keep metadata: %+v", restored)
+	}
+	if _, err := store.Get(entry.Slug); err != nil {
+		t.Fatalf("get after restore: %v", err)
+	}
+	if trash, _ := store.Trash(); len(trash) != 0 {
+		t.Fatalf("trash should be empty after restore, got %d", len(trash))
+	}
+	if _, err := store.Restore(entry.Slug); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("restore missing: %v", err)
+	}
+}
+
+func TestStoreRejectsInvalidSlugs(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	entry, _ := store.Create(RendererMarkdown, "trashed", "")
+	if err := store.Delete(entry.Slug); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	// 路由会把 %2F 解码进 slug，任何带路径成分的 slug 都不能读到其他文件。
+	for _, slugID := range []string{".trash/" + entry.Slug, "../" + entry.Slug, ".trash", "", "UPPER", "a-b"} {
+		if _, err := store.Get(slugID); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("get %q: %v", slugID, err)
+		}
+		if err := store.Delete(slugID); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("delete %q: %v", slugID, err)
+		}
+		if _, err := store.Restore(slugID); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("restore %q: %v", slugID, err)
+		}
+		if _, err := store.Purge(slugID); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("purge %q: %v", slugID, err)
+		}
+	}
+	if trash, _ := store.Trash(); len(trash) != 1 {
+		t.Fatalf("trash should be untouched, got %d", len(trash))
+	}
+}
+
+func TestStorePurge(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	a, _ := store.Create(RendererMarkdown, "a", "")
+	b, _ := store.Create(RendererMarkdown, "b", "")
+	for _, e := range []Entry{a, b} {
+		if err := store.Delete(e.Slug); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+	}
+
+	if item, err := store.Purge(a.Slug); err != nil || item.Raw != "a" {
+		t.Fatalf("purge: %v %+v", err, item)
+	}
+	if _, err := store.Purge(a.Slug); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("purge missing: %v", err)
+	}
+	if _, err := store.Restore(a.Slug); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("purged entry must not be restorable: %v", err)
+	}
+
+	// 保留期内的条目不受影响
+	if purged, err := store.PurgeBefore(time.Now().Add(-time.Hour)); err != nil || len(purged) != 0 {
+		t.Fatalf("purge before: %v %+v", err, purged)
+	}
+	purged, err := store.PurgeBefore(time.Now().Add(time.Second))
+	if err != nil || len(purged) != 1 || purged[0].Slug != b.Slug {
+		t.Fatalf("purge expired: %v %+v", err, purged)
+	}
+	if trash, _ := store.Trash(); len(trash) != 0 {
+		t.Fatalf("trash should be empty, got %d", len(trash))
+	}
+}
+
+func TestStoreProbeLeavesNoFiles(t *testing.T) {
+	root := t.TempDir()
+	store, err := NewStore(root)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+
+	// 并发探测使用各自的临时文件，互不干扰。
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- store.Probe()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("probe: %v", err)
+		}
+	}
+	files, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(files) != 0 {
+		t.Fatalf("expected probe to clean up, found %d files", len(files))
+	}
+}
+
+func TestStoreDraftPublish(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	draft, err := store.CreateDraft(RendererMarkdown, "wip", "")
+	if err != nil || !draft.Draft {
+		t.Fatalf("create draft: %v %+v", err, draft)
+	}
+	// 更新不改变草稿状态。
+	updated, err := store.Update(draft.Slug, RendererMarkdown, "still wip", "")
+	if err != nil || !updated.Draft {
+		t.Fatalf("update draft: %v %+v", err, updated)
+	}
+
+	published, err := store.Publish(draft.Slug)
+	if err != nil || published.Draft || published.Raw != "still wip" {
+		t.Fatalf("publish: %v %+v", err, published)
+	}
+	if published.CreatedAt.Before(draft.CreatedAt) {
+		t.Fatalf("publishing should reset the creation time")
+	}
+	got, err := store.Get(draft.Slug)
+	if err != nil || got.Draft {
+		t.Fatalf("get after publish: %v %+v", err, got)
+	}
+	if _, err := store.Publish("missing1"); !errors.Is(err, ErrEntryNotFound) {
+		t.Fatalf("publish missing: %v", err)
+	}
+}
+
+func TestStoreAutosave(t *testing.T) {
+	store, err := NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	entry, _ := store.Create(RendererMarkdown, "saved", "")
+
+	if _, err := store.Autosave(entry.Slug); !errors.Is(err, ErrAutosaveNotFound) {
+		t.Fatalf("autosave before save: %v", err)
+	}
+	a, err := store.SaveAutosave(Autosave{Key: entry.Slug, Renderer: RendererMarkdown, Raw: "unsaved edit"})
+	if err != nil || a.SavedAt.IsZero() {
+		t.Fatalf("save autosave: %v %+v", err, a)
+	}
+	if got, err := store.Autosave(entry.Slug); err != nil || got.Raw != "unsaved edit" {
+		t.Fatalf("read autosave: %v %+v", err, got)
+	}
+	// 自动保存与已保存版本分开存放，也不会出现在条目列表中。
+	if e, _ := store.Get(entry.Slug); e.Raw != "saved" {
+		t.Fatalf("autosave must not touch the saved entry: %q", e.Raw)
+	}
+	if list, _ := store.List(); len(list) != 1 {
+		t.Fatalf("list should only contain the entry, got %d", len(list))
+	}
+
+	if _, err := store.SaveAutosave(Autosave{Key: NewEntryAutosave, Renderer: RendererHTML, Raw: "<p>new</p>"}); err != nil {
+		t.Fatalf("save new-entry autosave: %v", err)
+	}
+	for _, key := range []string{"missing1", "../etc", ""} {
+		if _, err := store.SaveAutosave(Autosave{Key: key, Renderer: RendererMarkdown}); !errors.Is(err, ErrEntryNotFound) {
+			t.Errorf("key %q: got %v, want ErrEntryNotFound", key, err)
+		}
+	}
+	if all, err := store.Autosaves(); err != nil || len(all) != 2 {
+		t.Fatalf("autosaves: %d, %v", len(all), err)
+	}
+
+	if err := store.DiscardAutosave(NewEntryAutosave); err != nil {
+		t.Fatalf("discard: %v", err)
+	}
+	if _, err := store.Autosave(NewEntryAutosave); !errors.Is(err, ErrAutosaveNotFound) {
+		t.Fatalf("autosave after discard: %v", err)
+	}
+
+	// 永久删除条目时一并删除其自动保存。
+	store.Delete(entry.Slug)
+	if _, err := store.Purge(entry.Slug); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+	if _, err := store.Autosave(entry.Slug); !errors.Is(err, ErrAutosaveNotFound) {
+		t.Fatalf("autosave of purged entry: %v", err)
+	}
 }
@@ -0,0 +1,218 @@
+// Package imageproc 处理上传的图片：移除 EXIF 等元数据、按拍摄方向摆正，并生成
+// 缩略图与供 srcset 使用的多尺寸变体。解码全部为纯 Go 实现（PNG、JPEG、GIF 与 WebP）。
+package imageproc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册 WebP 解码器
+)
+
+// MaxPixels 为允许处理的最大像素数，防止解压炸弹耗尽内存。
+const MaxPixels = 40_000_000
+
+// ThumbSize 为缩略图的最大边长。
+const ThumbSize = 320
+
+// Widths 为生成的响应式变体宽度，只生成比原图窄的档位。
+var Widths = []int{640, 1280, 1920}
+
+// jpegQuality 为变体的 JPEG 编码质量；摆正后的原图使用更高的 orientedQuality。
+const (
+	jpegQuality     = 85
+	orientedQuality = 92
+)
+
+var (
+	// ErrUnsupported 表示内容不是可解码的 PNG / JPEG / GIF / WebP 图片。
+	ErrUnsupported = errors.New("unsupported or corrupt image")
+	// ErrTooManyPixels 表示图片尺寸超过 MaxPixels。
+	ErrTooManyPixels = errors.New("image dimensions too large")
+)
+
+// Variant 是一个生成的图片变体。
+type Variant struct {
+	// Name 为 "thumb" 或 "w<宽度>"。
+	Name   string
+	Width  int
+	Height int
+	MIME   string
+	Data   []byte
+}
+
+// Result 是处理后的图片。
+type Result struct {
+	// Data 为移除元数据（必要时摆正并重新编码）后的原图，MIME 为其类型。
+	Data     []byte
+	MIME     string
+	Width    int
+	Height   int
+	Variants []Variant
+}
+
+// Process 移除图片元数据并生成变体。mime 为按内容嗅探得到的类型。
+// JPEG 带有非默认 EXIF 方向时按方向摆正后重新编码，避免移除 EXIF 后图片显示为横倒。
+// 动图 GIF 只移除元数据，不生成静态变体，以免丢失动画。
+func Process(data []byte, mime string) (Result, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return Result{}, ErrUnsupported
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return Result{}, ErrTooManyPixels
+	}
+
+	orientation := 1
+	if mime == "image/jpeg" {
+		orientation = jpegOrientation(data)
+	}
+	clean, err := StripMetadata(data, mime)
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	res := Result{Data: clean, MIME: mime, Width: cfg.Width, Height: cfg.Height}
+
+	if mime == "image/gif" {
+		g, err := gif.DecodeAll(bytes.NewReader(clean))
+		if err != nil {
+			return Result{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+		}
+		if len(g.Image) > 1 {
+			return res, nil
+		}
+	}
+
+	needsVariants := cfg.Width > ThumbSize || cfg.Height > ThumbSize
+	if orientation == 1 && !needsVariants {
+		return res, nil
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(clean))
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	if orientation != 1 {
+		src = orient(src, orientation)
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, src, &jpeg.Options{Quality: orientedQuality}); err != nil {
+			return Result{}, err
+		}
+		b := src.Bounds()
+		res.Data, res.Width, res.Height = buf.Bytes(), b.Dx(), b.Dy()
+	}
+
+	alpha := !isOpaque(src)
+	for _, w := range Widths {
+		if w >= res.Width {
+			break
+		}
+		h := max(1, res.Height*w/res.Width)
+		v, err := encodeVariant(fmt.Sprintf("w%d", w), resize(src, w, h), alpha)
+		if err != nil {
+			return Result{}, err
+		}
+		res.Variants = append(res.Variants, v)
+	}
+	if res.Width > ThumbSize || res.Height > ThumbSize {
+		w, h := fit(res.Width, res.Height, ThumbSize)
+		v, err := encodeVariant("thumb", resize(src, w, h), alpha)
+		if err != nil {
+			return Result{}, err
+		}
+		res.Variants = append(res.Variants, v)
+	}
+	return res, nil
+}
+
+// fit 返回把 w×h 等比缩放进 size×size 方框后的尺寸。
+func fit(w, h, size int) (int, int) {
+	if w >= h {
+		return size, max(1, h*size/w)
+	}
+	return max(1, w*size/h), size
+}
+
+func resize(src image.Image, w, h int) image.Image {
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, src.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// encodeVariant 对不透明图片使用 JPEG，含透明通道时使用 PNG。
+func encodeVariant(name string, img image.Image, alpha bool) (Variant, error) {
+	var buf bytes.Buffer
+	mime := "image/jpeg"
+	var err error
+	if alpha {
+		mime = "image/png"
+		err = png.Encode(&buf, img)
+	} else {
+		err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality})
+	}
+	if err != nil {
+		return Variant{}, fmt.Errorf("encode %s: %w", name, err)
+	}
+	b := img.Bounds()
+	return Variant{Name: name, Width: b.Dx(), Height: b.Dy(), MIME: mime, Data: buf.Bytes()}, nil
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// orient 按 EXIF Orientation（2–8）翻转或旋转图片。
+func orient(src image.Image, orientation int) image.Image {
+	b := src.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	// from 把目标坐标映射回源坐标。
+	var from func(x, y int) (int, int)
+	switch orientation {
+	case 2: // 水平翻转
+		from = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3: // 旋转 180°
+		from = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4: // 垂直翻转
+		from = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5: // 沿主对角线翻转
+		from = func(x, y int) (int, int) { return y, x }
+	case 6: // 顺时针旋转 90°
+		from = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7: // 沿副对角线翻转
+		from = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8: // 逆时针旋转 90°
+		from = func(x, y int) (int, int) { return w - 1 - y, x }
+	default:
+		return rgba
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := from(x, y)
+			si := rgba.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], rgba.Pix[si:si+4])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,241 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// halves 返回左半红、右半蓝的图片，便于判断旋转方向。
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// exifSegment 构造带 Orientation 与一段 GPS 标记文本的 APP1 段（大端 TIFF）。
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	ifd := make([]byte, 2+12+4)
+	binary.BigEndian.PutUint16(ifd[0:], 1)
+	binary.BigEndian.PutUint16(ifd[2:], 0x0112)
+	binary.BigEndian.PutUint16(ifd[4:], 3) // SHORT
+	binary.BigEndian.PutUint32(ifd[6:], 1)
+	binary.BigEndian.PutUint16(ifd[10:], orientation)
+	body := append([]byte("Exif\x00\x00"), append(append(tiff, ifd...), []byte("GPS 48.8584N 2.2945E")...)...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(body)+2))
+	return append(seg, body...)
+}
+
+func encodeJPEG(t *testing.T, img image.Image, segments ...[]byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatalf("encode jpeg: %v", err)
+	}
+	data := buf.Bytes()
+	out := append([]byte(nil), data[:2]...)
+	for _, s := range segments {
+		out = append(out, s...)
+	}
+	return append(out, data[2:]...)
+}
+
+func pngChunk(typ string, body []byte) []byte {
+	c := make([]byte, 8, 12+len(body))
+	binary.BigEndian.PutUint32(c, uint32(len(body)))
+	copy(c[4:], typ)
+	c = append(c, body...)
+	h := crc32.NewIEEE()
+	h.Write([]byte(typ))
+	h.Write(body)
+	return binary.BigEndian.AppendUint32(c, h.Sum32())
+}
+
+func TestProcessOrientsAndStripsJPEG(t *testing.T) {
+	data := encodeJPEG(t, halves(40, 20), exifSegment(6))
+	if jpegOrientation(data) != 6 {
+		t.Fatalf("orientation not detected")
+	}
+
+	res, err := Process(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if bytes.Contains(res.Data, []byte("Exif")) || bytes.Contains(res.Data, []byte("GPS")) {
+		t.Fatalf("metadata survived processing")
+	}
+	if res.Width != 20 || res.Height != 40 {
+		t.Fatalf("size = %dx%d, want 20x40 after rotating", res.Width, res.Height)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(res.Data))
+	if err != nil {
+		t.Fatalf("decode result: %v", err)
+	}
+	// 顺时针旋转 90° 后，原图左半（红）位于上半部分。
+	if r, _, b, _ := img.At(10, 5).RGBA(); r < b {
+		t.Errorf("top half should be red")
+	}
+	if r, _, b, _ := img.At(10, 35).RGBA(); b < r {
+		t.Errorf("bottom half should be blue")
+	}
+}
+
+func TestStripJPEGKeepsPixels(t *testing.T) {
+	data := encodeJPEG(t, halves(8, 8), exifSegment(1), []byte("\xFF\xFE\x00\x07hello"))
+	clean, err := StripMetadata(data, "image/jpeg")
+	if err != nil {
+		t.Fatalf("strip: %v", err)
+	}
+	if bytes.Contains(clean, []byte("Exif")) || bytes.Contains(clean, []byte("hello")) {
+		t.Fatalf("APP1/COM segments survived")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(clean)); err != nil {
+		t.Fatalf("decode stripped: %v", err)
+	}
+	// 无需摆正时不重新编码，只去掉元数据段。
+	res, err := Process(data, "image/jpeg")
+	if err != nil || !bytes.Equal(res.Data, clean) {
+		t.Fatalf("expected lossless strip, err %v", err)
+	}
+}
+
+func TestStripPNGChunks(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves(4, 4)); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	data := buf.Bytes()
+	// 在 IHDR（签名后 25 字节）之后插入文本与 EXIF 块。
+	at := 8 + 25
+	var withMeta []byte
+	withMeta = append(withMeta, data[:at]...)
+	withMeta = append(withMeta, pngChunk("tEXt", []byte("Comment\x00secret"))...)
+	withMeta = append(withMeta, pngChunk("eXIf", []byte("MM\x00\x2aGPS"))...)
+	withMeta = append(withMeta, data[at:]...)
+
+	res, err := Process(withMeta, "image/png")
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if bytes.Contains(res.Data, []byte("secret")) || bytes.Contains(res.Data, []byte("eXIf")) {
+		t.Fatalf("metadata chunks survived")
+	}
+	if !bytes.Equal(res.Data, data) {
+		t.Fatalf("stripped PNG should equal the original encoding")
+	}
+}
+
+// webp1x1 是一张 1×1 的无损 WebP（VP8L）。
+var webp1x1 = []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+
+func TestStripWebP(t *testing.T) {
+	vp8x := make([]byte, 18)
+	copy(vp8x, "VP8X")
+	binary.LittleEndian.PutUint32(vp8x[4:], 10)
+	vp8x[8] = vp8xEXIF
+	exif := append([]byte("EXIF\x05\x00\x00\x00GPS!!"), 0) // 奇数长度，带填充字节
+
+	var data []byte
+	data = append(data, "RIFF\x00\x00\x00\x00WEBP"...)
+	data = append(data, vp8x...)
+	data = append(data, exif...)
+	data = append(data, webp1x1[12:]...)
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(data)-8))
+
+	res, err := Process(data, "image/webp")
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	if bytes.Contains(res.Data, []byte("EXIF")) || bytes.Contains(res.Data, []byte("GPS")) {
+		t.Fatalf("EXIF chunk survived")
+	}
+	if res.Data[12+8]&vp8xEXIF != 0 {
+		t.Fatalf("VP8X EXIF flag not cleared")
+	}
+	if got := binary.LittleEndian.Uint32(res.Data[4:]); int(got) != len(res.Data)-8 {
+		t.Fatalf("RIFF size = %d, want %d", got, len(res.Data)-8)
+	}
+	if res.Width != 1 || res.Height != 1 {
+		t.Fatalf("size = %dx%d", res.Width, res.Height)
+	}
+}
+
+func TestProcessVariants(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves(2000, 1000)); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	res, err := Process(buf.Bytes(), "image/png")
+	if err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	want := map[string][2]int{"w640": {640, 320}, "w1280": {1280, 640}, "w1920": {1920, 960}, "thumb": {320, 160}}
+	if len(res.Variants) != len(want) {
+		t.Fatalf("got %d variants, want %d", len(res.Variants), len(want))
+	}
+	for _, v := range res.Variants {
+		size, ok := want[v.Name]
+		if !ok || v.Width != size[0] || v.Height != size[1] || v.MIME != "image/jpeg" {
+			t.Errorf("unexpected variant %s %dx%d %s", v.Name, v.Width, v.Height, v.MIME)
+		}
+		cfg, err := jpeg.DecodeConfig(bytes.NewReader(v.Data))
+		if err != nil || cfg.Width != v.Width {
+			t.Errorf("%s does not decode: %v", v.Name, err)
+		}
+	}
+
+	// 含透明通道的图片以 PNG 输出变体。
+	transparent := image.NewNRGBA(image.Rect(0, 0, 400, 100))
+	buf.Reset()
+	png.Encode(&buf, transparent)
+	res, err = Process(buf.Bytes(), "image/png")
+	if err != nil || len(res.Variants) != 1 || res.Variants[0].MIME != "image/png" {
+		t.Fatalf("transparent variants: %+v %v", res.Variants, err)
+	}
+}
+
+func TestProcessSkipsAnimatedGIF(t *testing.T) {
+	pal := color.Palette{color.Black, color.White}
+	frame := func() *image.Paletted { return image.NewPaletted(image.Rect(0, 0, 500, 500), pal) }
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, &gif.GIF{Image: []*image.Paletted{frame(), frame()}, Delay: []int{10, 10}}); err != nil {
+		t.Fatalf("encode gif: %v", err)
+	}
+	res, err := Process(buf.Bytes(), "image/gif")
+	if err != nil || len(res.Variants) != 0 || !bytes.Equal(res.Data, buf.Bytes()) {
+		t.Fatalf("animated gif should pass through: %d variants, %v", len(res.Variants), err)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	if _, err := Process([]byte("not an image"), "image/png"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("garbage: got %v, want ErrUnsupported", err)
+	}
+
+	// 只有 IHDR 声称 10000×10000 的 PNG，在解码像素前即被拒绝。
+	ihdr := make([]byte, 13)
+	binary.BigEndian.PutUint32(ihdr[0:], 10000)
+	binary.BigEndian.PutUint32(ihdr[4:], 10000)
+	ihdr[8], ihdr[9] = 8, 6
+	bomb := append(append([]byte(nil), pngSignature...), pngChunk("IHDR", ihdr)...)
+	if _, err := Process(bomb, "image/png"); !errors.Is(err, ErrTooManyPixels) {
+		t.Fatalf("bomb: got %v, want ErrTooManyPixels", err)
+	}
+}
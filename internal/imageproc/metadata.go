@@ -0,0 +1,200 @@
+package imageproc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// errMalformed 表示图片容器结构无法解析。
+var errMalformed = errors.New("malformed image")
+
+// StripMetadata 按格式移除 EXIF、XMP、IPTC 与文本注释等元数据，不重新编码像素：
+//   - JPEG：去掉 APP1（EXIF/XMP）、APP13（IPTC）与 COM 段，保留 JFIF、ICC 与 Adobe 段；
+//   - PNG：去掉 eXIf、tEXt、zTXt、iTXt 与 tIME 块；
+//   - WebP：去掉 EXIF 与 XMP 块并清除 VP8X 中对应的标志位；
+//   - GIF 没有标准的 EXIF 载体，原样返回。
+//
+// 其他类型原样返回。
+func StripMetadata(data []byte, mime string) ([]byte, error) {
+	switch mime {
+	case "image/jpeg":
+		return stripJPEG(data)
+	case "image/png":
+		return stripPNG(data)
+	case "image/webp":
+		return stripWebP(data)
+	default:
+		return data, nil
+	}
+}
+
+// stripJPEG 逐段复制 JPEG 头部并跳过元数据段；遇到 SOS 后其余数据原样保留。
+func stripJPEG(data []byte) ([]byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	i := 2
+	for {
+		if i+4 > len(data) || data[i] != 0xFF {
+			return nil, errMalformed
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // 填充字节
+			i++
+			continue
+		}
+		if marker == 0xDA { // SOS：之后是熵编码数据
+			return append(out, data[i:]...), nil
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + n
+		if n < 2 || end > len(data) {
+			return nil, errMalformed
+		}
+		switch marker {
+		case 0xE1, 0xED, 0xFE: // APP1、APP13、COM
+		default:
+			out = append(out, data[i:end]...)
+		}
+		i = end
+	}
+}
+
+// pngDropped 是需要移除的 PNG 辅助块。
+var pngDropped = map[string]bool{"eXIf": true, "tEXt": true, "zTXt": true, "iTXt": true, "tIME": true}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, errMalformed
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	i := len(pngSignature)
+	for i < len(data) {
+		if i+12 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + n
+		if end > len(data) {
+			return nil, errMalformed
+		}
+		typ := string(data[i+4 : i+8])
+		if !pngDropped[typ] {
+			out = append(out, data[i:end]...)
+		}
+		i = end
+		if typ == "IEND" {
+			break
+		}
+	}
+	return out, nil
+}
+
+// VP8X 标志位：EXIF 与 XMP 元数据是否存在。
+const (
+	vp8xEXIF = 0x08
+	vp8xXMP  = 0x04
+)
+
+func stripWebP(data []byte) ([]byte, error) {
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, errMalformed
+	}
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	i := 12
+	for i < len(data) {
+		if i+8 > len(data) {
+			return nil, errMalformed
+		}
+		n := int(binary.LittleEndian.Uint32(data[i+4:]))
+		end := i + 8 + n + n%2 // 块按偶数字节对齐
+		if end > len(data) {
+			if i+8+n == len(data) { // 容忍缺失末尾填充字节的文件
+				end = len(data)
+			} else {
+				return nil, errMalformed
+			}
+		}
+		chunk := data[i:end]
+		switch string(chunk[:4]) {
+		case "EXIF", "XMP ":
+		case "VP8X":
+			c := append([]byte(nil), chunk...)
+			if len(c) > 8 {
+				c[8] &^= vp8xEXIF | vp8xXMP
+			}
+			out = append(out, c...)
+		default:
+			out = append(out, chunk...)
+		}
+		i = end
+	}
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// jpegOrientation 读取 JPEG 中 EXIF 的 Orientation（1–8），缺失或无法解析时返回 1。
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	i := 2
+	for i+4 <= len(data) && data[i] == 0xFF {
+		marker := data[i+1]
+		if marker == 0xDA {
+			break
+		}
+		n := int(binary.BigEndian.Uint16(data[i+2:]))
+		if n < 2 || i+2+n > len(data) {
+			break
+		}
+		seg := data[i+4 : i+2+n]
+		if marker == 0xE1 && bytes.HasPrefix(seg, []byte("Exif\x00\x00")) {
+			return exifOrientation(seg[6:])
+		}
+		i += 2 + n
+	}
+	return 1
+}
+
+// exifOrientation 在 TIFF 结构的 IFD0 中查找 Orientation 标签（0x0112）。
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for k := 0; k < count; k++ {
+		e := ifd + 2 + k*12
+		if e+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			v := int(order.Uint16(tiff[e+8:]))
+			if v >= 1 && v <= 8 {
+				return v
+			}
+			return 1
+		}
+	}
+	return 1
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewJSONIncludesRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-123")
+	logger.ErrorContext(ctx, "create entry", "error", "boom")
+
+	var rec map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &rec); err != nil {
+		t.Fatalf("decode log line: %v, line: %s", err, buf.String())
+	}
+	if rec["request_id"] != "req-123" {
+		t.Fatalf("request_id = %v, want req-123", rec["request_id"])
+	}
+}
+
+func TestNewLevelFilters(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "text", "warn")
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "shown") {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
+
+func TestNewRejectsInvalidOptions(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Errorf("expected error for unknown format")
+	}
+	if _, err := New(&bytes.Buffer{}, "text", "loud"); err == nil {
+		t.Errorf("expected error for unknown level")
+	}
+}
@@ -0,0 +1,303 @@
+// Package metrics 提供一个极简的指标注册表，以 Prometheus 文本格式（0.0.4）输出。
+//
+// 只实现本项目用到的计数器、直方图与按需求值的 gauge，避免引入完整的客户端库。
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefBuckets 是适用于 HTTP 延迟的默认直方图桶（秒）。
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Registry 收集一组指标并负责序列化输出。
+type Registry struct {
+	mu      sync.Mutex
+	metrics []collector
+}
+
+type collector interface {
+	name() string
+	write(w *bufio.Writer)
+}
+
+// NewRegistry 创建一个空的注册表。
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range r.metrics {
+		if m.name() == c.name() {
+			panic("metrics: duplicate metric " + c.name())
+		}
+	}
+	r.metrics = append(r.metrics, c)
+}
+
+// WriteTo 以 Prometheus 文本格式写出全部指标，按指标名排序。
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.Lock()
+	metrics := append([]collector(nil), r.metrics...)
+	r.mu.Unlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, m := range metrics {
+		m.write(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+// Handler 返回输出全部指标的 HTTP handler。
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = r.WriteTo(w)
+	})
+}
+
+// CounterVec 是按标签区分的单调递增计数器。
+type CounterVec struct {
+	desc
+	mu     sync.Mutex
+	values map[string]*series
+}
+
+// NewCounterVec 注册一个计数器。
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{n: name, help: help, labels: labels}, values: make(map[string]*series)}
+	r.register(c)
+	return c
+}
+
+// Inc 将给定标签值对应的计数加一。
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add 将给定标签值对应的计数增加 v（v 必须非负）。
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := c.seriesFor(c.values, labelValues)
+	s.value += v
+}
+
+// Value 返回给定标签值当前的计数，主要供测试使用。
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if s, ok := c.values[seriesKey(labelValues)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.header(w, "counter")
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range sortedSeries(c.values) {
+		writeSample(w, c.n, c.labels, s.labelValues, "", "", s.value)
+	}
+}
+
+// HistogramVec 是按标签区分的直方图。
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.Mutex
+	values  map[string]*series
+}
+
+// NewHistogramVec 注册一个直方图；buckets 为升序的上界列表，nil 使用 DefBuckets。
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefBuckets
+	}
+	h := &HistogramVec{desc: desc{n: name, help: help, labels: labels}, buckets: buckets, values: make(map[string]*series)}
+	r.register(h)
+	return h
+}
+
+// Observe 记录一次观测值。
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.seriesFor(h.values, labelValues)
+	if s.counts == nil {
+		s.counts = make([]uint64, len(h.buckets))
+	}
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.value += v
+}
+
+// Count 返回给定标签值的观测次数，主要供测试使用。
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if s, ok := h.values[seriesKey(labelValues)]; ok {
+		return s.count
+	}
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.header(w, "histogram")
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, s := range sortedSeries(h.values) {
+		for i, upper := range h.buckets {
+			writeSample(w, h.n+"_bucket", h.labels, s.labelValues, "le", formatFloat(upper), float64(s.counts[i]))
+		}
+		writeSample(w, h.n+"_bucket", h.labels, s.labelValues, "le", "+Inf", float64(s.count))
+		writeSample(w, h.n+"_sum", h.labels, s.labelValues, "", "", s.value)
+		writeSample(w, h.n+"_count", h.labels, s.labelValues, "", "", float64(s.count))
+	}
+}
+
+// funcMetric 在每次输出时调用回调求值，适用于条目数、会话数等现成状态。
+type funcMetric struct {
+	desc
+	kind string
+	fn   func() float64
+}
+
+// NewGaugeFunc 注册一个按需求值的 gauge。
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{desc: desc{n: name, help: help}, kind: "gauge", fn: fn})
+}
+
+// NewCounterFunc 注册一个按需求值的计数器，回调返回值必须单调递增。
+func (r *Registry) NewCounterFunc(name, help string, fn func() float64) {
+	r.register(&funcMetric{desc: desc{n: name, help: help}, kind: "counter", fn: fn})
+}
+
+func (f *funcMetric) write(w *bufio.Writer) {
+	f.header(w, f.kind)
+	writeSample(w, f.n, nil, nil, "", "", f.fn())
+}
+
+type desc struct {
+	n      string
+	help   string
+	labels []string
+}
+
+func (d *desc) name() string { return d.n }
+
+func (d *desc) header(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.n, escapeHelp(d.help), d.n, kind)
+}
+
+func (d *desc) seriesFor(values map[string]*series, labelValues []string) *series {
+	if len(labelValues) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.n, len(d.labels), len(labelValues)))
+	}
+	key := seriesKey(labelValues)
+	s, ok := values[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		values[key] = s
+	}
+	return s
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // 计数器的值，或直方图的 sum
+	count       uint64   // 直方图观测次数
+	counts      []uint64 // 直方图各桶累计计数
+}
+
+func seriesKey(labelValues []string) string {
+	return strings.Join(labelValues, "\xff")
+}
+
+func sortedSeries(values map[string]*series) []*series {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*series, len(keys))
+	for i, k := range keys {
+		out[i] = values[k]
+	}
+	return out
+}
+
+func writeSample(w *bufio.Writer, name string, labels, values []string, extraLabel, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(labels) > 0 || extraLabel != "" {
+		w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", l, escapeLabel(values[i]))
+		}
+		if extraLabel != "" {
+			if len(labels) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, "%s=\"%s\"", extraLabel, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryTextFormat(t *testing.T) {
+	r := NewRegistry()
+	reqs := r.NewCounterVec("app_requests_total", "Total requests.", "route", "status")
+	lat := r.NewHistogramVec("app_latency_seconds", "Latency.", []float64{0.1, 1}, "route")
+	r.NewGaugeFunc("app_items", "Items.", func() float64 { return 7 })
+
+	reqs.Inc("GET /{slug}", "200")
+	reqs.Inc("GET /{slug}", "200")
+	reqs.Inc(`we"ird`, "500")
+	lat.Observe(0.05, "GET /{slug}")
+	lat.Observe(0.5, "GET /{slug}")
+
+	var sb strings.Builder
+	if _, err := r.WriteTo(&sb); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	out := sb.String()
+
+	for _, want := range []string{
+		"# TYPE app_requests_total counter",
+		`app_requests_total{route="GET /{slug}",status="200"} 2`,
+		`app_requests_total{route="we\"ird",status="500"} 1`,
+		"# TYPE app_latency_seconds histogram",
+		`app_latency_seconds_bucket{route="GET /{slug}",le="0.1"} 1`,
+		`app_latency_seconds_bucket{route="GET /{slug}",le="1"} 2`,
+		`app_latency_seconds_bucket{route="GET /{slug}",le="+Inf"} 2`,
+		`app_latency_seconds_count{route="GET /{slug}"} 2`,
+		"# TYPE app_items gauge",
+		"app_items 7",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in output:\n%s", want, out)
+		}
+	}
+
+	// 指标按名称排序输出
+	if strings.Index(out, "app_items") > strings.Index(out, "app_latency_seconds") {
+		t.Errorf("expected metrics sorted by name")
+	}
+}
+
+func TestCounterLabelArityPanics(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounterVec("x_total", "x", "a")
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on wrong label count")
+		}
+	}()
+	c.Inc("1", "2")
+}
+
+func TestHandlerContentType(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterFunc("up_total", "up", func() float64 { return 1 })
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(w.Body.String(), "up_total 1") {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
@@ -0,0 +1,259 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockSkew 为校验 exp / iat 时允许的时钟偏差。
+const clockSkew = time.Minute
+
+// jwksRefreshInterval 限制遇到未知 kid 时重新拉取 JWKS 的频率。
+const jwksRefreshInterval = time.Minute
+
+// keySet 缓存身份提供方的签名公钥，遇到未知 kid 时刷新（应对密钥轮换）。
+type keySet struct {
+	uri string
+
+	mu      sync.Mutex
+	keys    map[string]crypto.PublicKey
+	fetched time.Time
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (ks *keySet) key(ctx context.Context, c *Client, kid string) (crypto.PublicKey, error) {
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	if k, ok := ks.lookup(kid); ok {
+		return k, nil
+	}
+	if !ks.fetched.IsZero() && time.Since(ks.fetched) < jwksRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	if err := ks.fetch(ctx, c); err != nil {
+		return nil, err
+	}
+	if k, ok := ks.lookup(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// lookup 按 kid 查找；令牌未带 kid 时仅在只有一把密钥时可用。
+func (ks *keySet) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(ks.keys) == 1 {
+		for _, k := range ks.keys {
+			return k, true
+		}
+	}
+	k, ok := ks.keys[kid]
+	return k, ok
+}
+
+func (ks *keySet) fetch(ctx context.Context, c *Client) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ks.uri, nil)
+	if err != nil {
+		return err
+	}
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	status, err := c.doJSON(req, &doc)
+	if err != nil {
+		return fmt.Errorf("fetch jwks: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("fetch jwks: status %d", status)
+	}
+	keys := make(map[string]crypto.PublicKey, len(doc.Keys))
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue // 跳过不支持的密钥类型
+		}
+		keys[k.Kid] = pub
+	}
+	ks.keys = keys
+	ks.fetched = time.Now()
+	return nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := b64.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := b64.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		if len(n) < 256 {
+			return nil, errors.New("RSA key too short")
+		}
+		exp := 0
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, errX := b64.DecodeString(k.X)
+		y, errY := b64.DecodeString(k.Y)
+		if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+			return nil, errors.New("invalid P-256 key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, errors.New("point is not on curve")
+		}
+		return pub, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// idTokenClaims 在 Claims 之外包含校验所需的注册声明。
+type idTokenClaims struct {
+	Claims
+	Audience audience `json:"aud"`
+	AZP      string   `json:"azp"`
+	Expiry   int64    `json:"exp"`
+	IssuedAt int64    `json:"iat"`
+}
+
+// audience 兼容字符串与字符串数组两种形式。
+type audience []string
+
+func (a *audience) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*a = audience{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+// verifyIDToken 校验签名与 OpenID Connect Core §3.1.3.7 要求的声明。
+func (c *Client) verifyIDToken(ctx context.Context, raw, nonce string) (*Claims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("id token is not a compact JWS")
+	}
+	headerJSON, err := b64.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("decode id token header: %w", err)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, fmt.Errorf("decode id token header: %w", err)
+	}
+	sig, err := b64.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decode id token signature: %w", err)
+	}
+
+	pub, err := c.keys.key(ctx, c, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := verifyJWS(header.Alg, pub, digest[:], sig); err != nil {
+		return nil, err
+	}
+
+	payload, err := b64.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("decode id token payload: %w", err)
+	}
+	var claims idTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("decode id token payload: %w", err)
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Issuer != c.Issuer:
+		return nil, fmt.Errorf("unexpected issuer %q", claims.Issuer)
+	case !claims.Audience.contains(c.ClientID):
+		return nil, errors.New("id token was not issued for this client")
+	case len(claims.Audience) > 1 && claims.AZP != c.ClientID:
+		return nil, errors.New("id token authorized party mismatch")
+	case claims.Expiry == 0 || now.After(time.Unix(claims.Expiry, 0).Add(clockSkew)):
+		return nil, errors.New("id token expired")
+	case claims.IssuedAt != 0 && time.Unix(claims.IssuedAt, 0).After(now.Add(clockSkew)):
+		return nil, errors.New("id token issued in the future")
+	case claims.Subject == "":
+		return nil, errors.New("id token has no subject")
+	case subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 || nonce == "":
+		return nil, errors.New("nonce mismatch")
+	}
+	return &claims.Claims, nil
+}
+
+func (a audience) contains(id string) bool {
+	for _, v := range a {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyJWS(alg string, pub crypto.PublicKey, digest, sig []byte) error {
+	switch alg {
+	case "RS256":
+		if k, ok := pub.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, sig) == nil {
+			return nil
+		}
+	case "ES256":
+		// JWS 中的 ECDSA 签名为定长 r||s，而非 ASN.1
+		if k, ok := pub.(*ecdsa.PublicKey); ok && len(sig) == 64 {
+			r := new(big.Int).SetBytes(sig[:32])
+			s := new(big.Int).SetBytes(sig[32:])
+			if ecdsa.Verify(k, digest, r, s) {
+				return nil
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported id token algorithm %q", alg)
+	}
+	return errors.New("id token signature verification failed")
+}
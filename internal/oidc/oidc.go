@@ -0,0 +1,226 @@
+// Package oidc 实现 OpenID Connect 授权码流程（带 PKCE）的客户端一侧：
+// 发现文档、授权地址、令牌交换与 ID Token 校验。只支持 RS256 与 ES256 签名。
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxResponseBody 限制从身份提供方读取的响应大小。
+const maxResponseBody = 1 << 20
+
+var b64 = base64.RawURLEncoding
+
+// Claims 是 ID Token 中用于授权判断的声明。
+type Claims struct {
+	Issuer        string   `json:"iss"`
+	Subject       string   `json:"sub"`
+	Email         string   `json:"email"`
+	EmailVerified *bool    `json:"email_verified"`
+	Name          string   `json:"name"`
+	Groups        []string `json:"groups"`
+	Nonce         string   `json:"nonce"`
+}
+
+// metadata 是发现文档中用到的字段。
+type metadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// Client 是一个依赖方。发现文档在首次使用时获取并缓存，
+// 身份提供方暂时不可用不会阻止服务启动。
+type Client struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// HTTPClient 为空时使用带超时的默认客户端。
+	HTTPClient *http.Client
+
+	mu   sync.Mutex
+	meta *metadata
+	keys *keySet
+}
+
+// NewVerifier 生成 PKCE code_verifier。
+func NewVerifier() (string, error) {
+	return randomString(32)
+}
+
+// NewState 生成 state 或 nonce 随机串。
+func NewState() (string, error) {
+	return randomString(24)
+}
+
+func randomString(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("generate random: %w", err)
+	}
+	return b64.EncodeToString(buf), nil
+}
+
+// S256Challenge 返回 verifier 对应的 code_challenge。
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return b64.EncodeToString(sum[:])
+}
+
+// AuthCodeURL 返回把浏览器引导至身份提供方的授权地址。
+func (c *Client) AuthCodeURL(ctx context.Context, redirectURI, state, nonce, verifier string) (string, error) {
+	meta, err := c.metadata(ctx)
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(meta.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("parse authorization endpoint: %w", err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", c.ClientID)
+	q.Set("redirect_uri", redirectURI)
+	q.Set("scope", "openid email profile")
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", S256Challenge(verifier))
+	q.Set("code_challenge_method", "S256")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Exchange 用授权码换取令牌，并校验其中的 ID Token 与 nonce。
+func (c *Client) Exchange(ctx context.Context, redirectURI, code, verifier, nonce string) (*Claims, error) {
+	meta, err := c.metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+		"client_id":     {c.ClientID},
+	}
+	useBasic := c.ClientSecret != "" && !onlyPostAuth(meta.TokenAuthMethods)
+	if c.ClientSecret != "" && !useBasic {
+		form.Set("client_secret", c.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasic {
+		// RFC 6749 §2.3.1：凭据先做表单编码再放入 Basic 认证
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+
+	var tok struct {
+		IDToken   string `json:"id_token"`
+		Error     string `json:"error"`
+		ErrorDesc string `json:"error_description"`
+	}
+	status, err := c.doJSON(req, &tok)
+	if err != nil {
+		return nil, fmt.Errorf("token request: %w", err)
+	}
+	if status != http.StatusOK {
+		if tok.Error != "" {
+			return nil, fmt.Errorf("token request: %s: %s", tok.Error, tok.ErrorDesc)
+		}
+		return nil, fmt.Errorf("token request: status %d", status)
+	}
+	if tok.IDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+	return c.verifyIDToken(ctx, tok.IDToken, nonce)
+}
+
+func onlyPostAuth(methods []string) bool {
+	basic, post := false, false
+	for _, m := range methods {
+		switch m {
+		case "client_secret_basic":
+			basic = true
+		case "client_secret_post":
+			post = true
+		}
+	}
+	return post && !basic
+}
+
+// metadata 返回缓存的发现文档，必要时重新获取。
+func (c *Client) metadata(ctx context.Context) (*metadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.meta != nil {
+		return c.meta, nil
+	}
+
+	wellKnown := strings.TrimSuffix(c.Issuer, "/") + "/.well-known/openid-configuration"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, err
+	}
+	var meta metadata
+	status, err := c.doJSON(req, &meta)
+	if err != nil {
+		return nil, fmt.Errorf("fetch discovery document: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("fetch discovery document: status %d", status)
+	}
+	// OpenID Connect Discovery §4.3：文档中的 issuer 必须与配置完全一致
+	if meta.Issuer != c.Issuer {
+		return nil, fmt.Errorf("discovery issuer %q does not match %q", meta.Issuer, c.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, errors.New("discovery document is missing endpoints")
+	}
+	c.meta = &meta
+	c.keys = &keySet{uri: meta.JWKSURI}
+	return c.meta, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return defaultHTTPClient
+}
+
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+func (c *Client) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return 0, err
+	}
+	if err := json.Unmarshal(body, v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("decode response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,140 @@
+package oidc
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"minisnap/internal/oidc/oidctest"
+)
+
+const testRedirect = "https://snap.example.com/login/oidc/callback"
+
+var noRedirect = &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+// authorize 走一遍授权端点，返回回调中的授权码。
+func authorize(t *testing.T, c *Client, state, nonce, verifier string) string {
+	t.Helper()
+	authURL, err := c.AuthCodeURL(context.Background(), testRedirect, state, nonce, verifier)
+	if err != nil {
+		t.Fatalf("auth url: %v", err)
+	}
+	resp, err := noRedirect.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || !strings.HasPrefix(loc.String(), testRedirect) {
+		t.Fatalf("unexpected redirect %q", resp.Header.Get("Location"))
+	}
+	if loc.Query().Get("state") != state {
+		t.Fatalf("state not echoed")
+	}
+	return loc.Query().Get("code")
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	p := oidctest.New("minisnap", "s3cret&+")
+	defer p.Close()
+	p.Claims["email"] = "alice@example.com"
+	p.Claims["groups"] = []string{"staff"}
+
+	c := &Client{Issuer: p.Issuer, ClientID: p.ClientID, ClientSecret: p.ClientSecret}
+	verifier, _ := NewVerifier()
+	code := authorize(t, c, "st", "n0nce", verifier)
+
+	claims, err := c.Exchange(context.Background(), testRedirect, code, verifier, "n0nce")
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if claims.Email != "alice@example.com" || len(claims.Groups) != 1 || claims.Subject != "user-1" {
+		t.Fatalf("unexpected claims %+v", claims)
+	}
+
+	// 授权码只能使用一次
+	if _, err := c.Exchange(context.Background(), testRedirect, code, verifier, "n0nce"); err == nil {
+		t.Fatalf("expected reused code to fail")
+	}
+}
+
+func TestExchangeRejectsBadPKCEAndNonce(t *testing.T) {
+	p := oidctest.New("minisnap", "secret")
+	defer p.Close()
+	c := &Client{Issuer: p.Issuer, ClientID: p.ClientID, ClientSecret: p.ClientSecret}
+
+	verifier, _ := NewVerifier()
+	other, _ := NewVerifier()
+	code := authorize(t, c, "st", "n", verifier)
+	if _, err := c.Exchange(context.Background(), testRedirect, code, other, "n"); err == nil {
+		t.Fatalf("expected wrong verifier to fail")
+	}
+
+	code = authorize(t, c, "st", "n", verifier)
+	if _, err := c.Exchange(context.Background(), testRedirect, code, verifier, "other"); err == nil || !strings.Contains(err.Error(), "nonce") {
+		t.Fatalf("expected nonce mismatch, got %v", err)
+	}
+
+	wrongSecret := &Client{Issuer: p.Issuer, ClientID: p.ClientID, ClientSecret: "nope"}
+	code = authorize(t, wrongSecret, "st", "n", verifier)
+	if _, err := wrongSecret.Exchange(context.Background(), testRedirect, code, verifier, "n"); err == nil || !strings.Contains(err.Error(), "invalid_client") {
+		t.Fatalf("expected invalid_client, got %v", err)
+	}
+}
+
+func TestVerifyIDTokenClaims(t *testing.T) {
+	p := oidctest.New("minisnap", "secret")
+	defer p.Close()
+	c := &Client{Issuer: p.Issuer, ClientID: p.ClientID}
+	if _, err := c.metadata(context.Background()); err != nil {
+		t.Fatalf("discovery: %v", err)
+	}
+
+	now := time.Now()
+	base := func() map[string]any {
+		return map[string]any{"iss": p.Issuer, "sub": "u", "aud": "minisnap", "exp": now.Add(time.Minute).Unix(), "iat": now.Unix(), "nonce": "n"}
+	}
+	if _, err := c.verifyIDToken(context.Background(), p.Sign(base()), "n"); err != nil {
+		t.Fatalf("valid token: %v", err)
+	}
+
+	cases := map[string]func(map[string]any){
+		"expired":      func(m map[string]any) { m["exp"] = now.Add(-time.Hour).Unix() },
+		"wrong issuer": func(m map[string]any) { m["iss"] = "https://evil.example" },
+		"wrong aud":    func(m map[string]any) { m["aud"] = "other" },
+		"multi aud":    func(m map[string]any) { m["aud"] = []string{"minisnap", "other"} },
+		"future iat":   func(m map[string]any) { m["iat"] = now.Add(time.Hour).Unix() },
+		"no subject":   func(m map[string]any) { delete(m, "sub") },
+	}
+	for name, mutate := range cases {
+		claims := base()
+		mutate(claims)
+		if _, err := c.verifyIDToken(context.Background(), p.Sign(claims), "n"); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// 篡改载荷后签名失效；alg=none 被拒绝
+	token := p.Sign(base())
+	parts := strings.Split(token, ".")
+	forged := parts[0] + "." + b64.EncodeToString([]byte(`{"iss":"`+p.Issuer+`","sub":"admin","aud":"minisnap","exp":9999999999,"nonce":"n"}`)) + "." + parts[2]
+	if _, err := c.verifyIDToken(context.Background(), forged, "n"); err == nil {
+		t.Fatalf("expected forged payload to fail")
+	}
+	none := b64.EncodeToString([]byte(`{"alg":"none","kid":"test"}`)) + "." + parts[1] + "."
+	if _, err := c.verifyIDToken(context.Background(), none, "n"); err == nil {
+		t.Fatalf("expected alg=none to fail")
+	}
+}
+
+func TestDiscoveryIssuerMismatch(t *testing.T) {
+	p := oidctest.New("minisnap", "secret")
+	defer p.Close()
+	c := &Client{Issuer: p.Issuer + "/", ClientID: p.ClientID}
+	if _, err := c.AuthCodeURL(context.Background(), testRedirect, "s", "n", "v"); err == nil {
+		t.Fatalf("expected issuer mismatch")
+	}
+}
@@ -0,0 +1,116 @@
+// Package password 负责后台密码的哈希与校验。新哈希使用 argon2id（PHC 字符串格式），
+// 校验同时兼容 bcrypt（$2a$ / $2b$ / $2y$），便于沿用已有的哈希。
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// argon2id 参数，取 RFC 9106 推荐的第二组（低内存）配置。
+const (
+	argonTime    = 3
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+	argonKeyLen  = 32
+	argonSaltLen = 16
+
+	// maxArgonMemory 限制校验时接受的内存参数，防止异常哈希耗尽内存。
+	maxArgonMemory = 1 << 20 // 1 GiB
+)
+
+// MinLength 为通过界面设置新密码时的最短长度。
+const MinLength = 10
+
+// ErrUnsupportedHash 表示哈希字符串不是受支持的格式。
+var ErrUnsupportedHash = errors.New("password: unsupported hash format")
+
+var b64 = base64.RawStdEncoding
+
+// Hash 以 argon2id 哈希密码，返回 PHC 格式字符串。
+func Hash(password string) (string, error) {
+	salt := make([]byte, argonSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("generate salt: %w", err)
+	}
+	key := argon2.IDKey([]byte(password), salt, argonTime, argonMemory, argonThreads, argonKeyLen)
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argonMemory, argonTime, argonThreads, b64.EncodeToString(salt), b64.EncodeToString(key)), nil
+}
+
+// Verify 报告 password 是否与哈希匹配。哈希格式无效时返回错误。
+func Verify(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		p, err := parseArgon2(hash)
+		if err != nil {
+			return false, err
+		}
+		key := argon2.IDKey([]byte(password), p.salt, p.time, p.memory, p.threads, uint32(len(p.key)))
+		return subtle.ConstantTimeCompare(key, p.key) == 1, nil
+	case isBcrypt(hash):
+		err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return false, ErrUnsupportedHash
+}
+
+// Check 校验哈希字符串格式，用于启动时尽早发现配置错误。
+func Check(hash string) error {
+	switch {
+	case strings.HasPrefix(hash, "$argon2id$"):
+		_, err := parseArgon2(hash)
+		return err
+	case isBcrypt(hash):
+		_, err := bcrypt.Cost([]byte(hash))
+		return err
+	}
+	return ErrUnsupportedHash
+}
+
+func isBcrypt(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+type argonParams struct {
+	time, memory uint32
+	threads      uint8
+	salt, key    []byte
+}
+
+// parseArgon2 解析 $argon2id$v=19$m=...,t=...,p=...$salt$key。
+func parseArgon2(hash string) (*argonParams, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return nil, ErrUnsupportedHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, fmt.Errorf("password: unsupported argon2 version %q", parts[2])
+	}
+	p := &argonParams{}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.memory, &p.time, &p.threads); err != nil {
+		return nil, fmt.Errorf("password: invalid argon2 parameters %q", parts[3])
+	}
+	if p.time == 0 || p.threads == 0 || p.memory < 8*uint32(p.threads) || p.memory > maxArgonMemory {
+		return nil, fmt.Errorf("password: argon2 parameters out of range %q", parts[3])
+	}
+	var err error
+	if p.salt, err = b64.DecodeString(parts[4]); err != nil || len(p.salt) < 8 {
+		return nil, errors.New("password: invalid argon2 salt")
+	}
+	if p.key, err = b64.DecodeString(parts[5]); err != nil || len(p.key) < 16 {
+		return nil, errors.New("password: invalid argon2 hash")
+	}
+	return p, nil
+}
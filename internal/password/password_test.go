@@ -0,0 +1,70 @@
+package password
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHashAndVerify(t *testing.T) {
+	hash, err := Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=65536,t=3,p=4$") {
+		t.Fatalf("unexpected hash format %q", hash)
+	}
+	if err := Check(hash); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if ok, err := Verify(hash, "correct horse battery staple"); !ok || err != nil {
+		t.Fatalf("verify correct: %v %v", ok, err)
+	}
+	if ok, err := Verify(hash, "wrong"); ok || err != nil {
+		t.Fatalf("verify wrong: %v %v", ok, err)
+	}
+
+	// 相同密码每次生成不同的盐
+	again, _ := Hash("correct horse battery staple")
+	if again == hash {
+		t.Fatalf("expected distinct salts")
+	}
+}
+
+func TestVerifyBcrypt(t *testing.T) {
+	raw, err := bcrypt.GenerateFromPassword([]byte("s3cret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("bcrypt: %v", err)
+	}
+	hash := string(raw)
+	if err := Check(hash); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if ok, err := Verify(hash, "s3cret"); !ok || err != nil {
+		t.Fatalf("verify correct: %v %v", ok, err)
+	}
+	if ok, err := Verify(hash, "nope"); ok || err != nil {
+		t.Fatalf("verify wrong: %v %v", ok, err)
+	}
+}
+
+func TestRejectsMalformedHashes(t *testing.T) {
+	for _, hash := range []string{
+		"",
+		"plaintext",
+		"$argon2i$v=19$m=65536,t=3,p=4$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5aw",
+		"$argon2id$v=16$m=65536,t=3,p=4$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5aw",
+		"$argon2id$v=19$m=99999999,t=3,p=4$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5aw",
+		"$argon2id$v=19$m=65536,t=0,p=4$c2FsdHNhbHQ$a2V5a2V5a2V5a2V5a2V5aw",
+		"$argon2id$v=19$m=65536,t=3,p=4$!!$a2V5a2V5a2V5a2V5a2V5aw",
+		"$2b$04$short",
+	} {
+		if err := Check(hash); err == nil {
+			t.Errorf("Check(%q): expected error", hash)
+		}
+		if ok, err := Verify(hash, "x"); ok || err == nil {
+			t.Errorf("Verify(%q): ok %v err %v", hash, ok, err)
+		}
+	}
+}
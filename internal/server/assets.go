@@ -1,12 +1,114 @@
 package server
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"embed"
+	"encoding/hex"
 	"io/fs"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
 )
 
-//go:embed assets/base.css assets/theme.js
+//go:embed assets/base.css assets/theme.js assets/passkey.js assets/upload.js assets/preview.js assets/autosave.js
 var assetsFS embed.FS
 
 // assetsSubFS 返回以 assets 为根的子文件系统，便于 http.FileServer 直接服务。
 var assetsSubFS, _ = fs.Sub(assetsFS, "assets")
+
+// staticAsset 是一个嵌入的静态资源及其内容哈希。
+type staticAsset struct {
+	data []byte
+	hash string // sha256 前 8 字节的十六进制
+}
+
+// staticAssets 在启动时对全部嵌入资源计算内容哈希，键为原始文件名（如 base.css）。
+var staticAssets = loadStaticAssets()
+
+// assetVersion 汇总所有资源哈希，资源任一变化都会改变它，用于页面 ETag。
+var assetVersion = computeAssetVersion()
+
+func loadStaticAssets() map[string]staticAsset {
+	assets := make(map[string]staticAsset)
+	_ = fs.WalkDir(assetsSubFS, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(assetsSubFS, name)
+		if err != nil {
+			return err
+		}
+		sum := sha256.Sum256(data)
+		assets[name] = staticAsset{data: data, hash: hex.EncodeToString(sum[:8])}
+		return nil
+	})
+	return assets
+}
+
+// computeAssetVersion 按文件名排序汇总 staticAssets，新增资源无需另行登记。
+func computeAssetVersion() string {
+	names := make([]string, 0, len(staticAssets))
+	for name := range staticAssets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name + ":" + staticAssets[name].hash + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8])
+}
+
+// assetURL 返回带内容哈希的资源 URL，例如 /-/static/base.1a2b3c4d5e6f7a8b.css。
+// 模板中通过 {{ asset "base.css" }} 调用。未知资源回退为不带哈希的路径。
+func assetURL(name string) string {
+	a, ok := staticAssets[name]
+	if !ok {
+		return "/-/static/" + name
+	}
+	ext := path.Ext(name)
+	return "/-/static/" + strings.TrimSuffix(name, ext) + "." + a.hash + ext
+}
+
+// splitAssetName 将 base.1a2b3c4d5e6f7a8b.css 拆分为 base.css 与哈希；
+// 不带哈希的名称原样返回，哈希为空。
+func splitAssetName(name string) (string, string) {
+	ext := path.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	dot := strings.LastIndexByte(stem, '.')
+	if dot < 0 {
+		return name, ""
+	}
+	return stem[:dot] + ext, stem[dot+1:]
+}
+
+// serveStatic 提供嵌入的静态资源。
+// 哈希匹配当前内容的 URL 按 immutable 长期缓存；不带哈希（或哈希已过期）的
+// URL 使用可配置的较短缓存时长，并一律支持基于 ETag 的条件请求。
+func (s *Server) serveStatic(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/-/static/")
+	a, ok := staticAssets[name]
+	immutable := false
+	if !ok {
+		base, hash := splitAssetName(name)
+		a, ok = staticAssets[base]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		name = base
+		immutable = hash == a.hash
+	}
+
+	h := w.Header()
+	if immutable {
+		h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		h.Set("Cache-Control", maxAgeDirective("public", s.cfg.AssetCacheMaxAge))
+	}
+	h.Set("ETag", `"`+a.hash+`"`)
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(a.data))
+}
@@ -0,0 +1,76 @@
+// MiniSnap 前端：编辑器自动保存。
+// 输入停顿后把编辑器表单提交到自动保存接口，服务端与已保存的条目分开存放，
+// 浏览器崩溃或误关页面后可在编辑页恢复。
+(function () {
+	const form = document.getElementById('editor-form');
+	const status = document.querySelector('[data-autosave-status]');
+	if (!form || !form.dataset.autosaveUrl) return;
+
+	const DEBOUNCE_MS = 2000;
+
+	let timer = 0;
+	let seq = 0; // 最近一次请求的序号，用于丢弃过期响应
+	let lastSent = null; // 最近一次成功提交的内容，未变化时不重复请求
+	let pending = false; // 有尚未提交的改动
+	let stopped = false; // 表单已提交保存，不再自动保存
+
+	function setStatus(msg, isError) {
+		if (!status) return;
+		status.textContent = msg;
+		status.classList.toggle('error', Boolean(isError));
+	}
+
+	function payload() {
+		return new URLSearchParams(new FormData(form));
+	}
+
+	async function save() {
+		clearTimeout(timer);
+		if (stopped) return;
+		const body = payload();
+		const key = body.toString();
+		pending = false;
+		if (key === lastSent) return;
+		lastSent = key;
+		const id = ++seq;
+		setStatus('Autosaving…');
+		try {
+			const res = await fetch(form.dataset.autosaveUrl, {
+				method: 'POST',
+				credentials: 'same-origin',
+				headers: { 'Accept': 'application/json', 'X-CSRF-Token': form.dataset.csrf },
+				body,
+			});
+			const data = await res.json().catch(() => ({}));
+			if (id !== seq || stopped) return;
+			if (!res.ok) throw new Error(data.error || `Autosave failed (${res.status})`);
+			setStatus(data.status === 'saved' ? `Autosaved at ${data.saved_at}.` : '');
+		} catch (err) {
+			if (id !== seq || stopped) return;
+			lastSent = null;
+			setStatus(err.message, true);
+		}
+	}
+
+	function schedule() {
+		pending = true;
+		clearTimeout(timer);
+		timer = setTimeout(save, DEBOUNCE_MS);
+	}
+
+	form.addEventListener('input', schedule);
+	form.addEventListener('change', schedule);
+	form.addEventListener('submit', (e) => {
+		// 在新标签页打开完整预览不是保存，继续自动保存
+		if (e.submitter && e.submitter.hasAttribute('data-full-preview')) return;
+		stopped = true;
+		clearTimeout(timer);
+	});
+
+	// 离开页面时把尚未提交的改动交给 sendBeacon；CSRF token 随表单字段一并发送。
+	window.addEventListener('pagehide', () => {
+		if (stopped || !pending || !navigator.sendBeacon) return;
+		clearTimeout(timer);
+		navigator.sendBeacon(form.dataset.autosaveUrl, payload());
+	});
+})();
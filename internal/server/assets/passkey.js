@@ -0,0 +1,96 @@
+// MiniSnap 前端：通行密钥（WebAuthn）注册与登录。
+// 服务端以 base64url 传递二进制字段，这里负责与 ArrayBuffer 互转。
+(function () {
+	const b64url = {
+		decode(s) {
+			const pad = '='.repeat((4 - (s.length % 4)) % 4);
+			const bin = atob((s + pad).replace(/-/g, '+').replace(/_/g, '/'));
+			return Uint8Array.from(bin, (c) => c.charCodeAt(0)).buffer;
+		},
+		encode(buf) {
+			const bytes = new Uint8Array(buf);
+			let bin = '';
+			bytes.forEach((b) => { bin += String.fromCharCode(b); });
+			return btoa(bin).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+		},
+	};
+
+	const decodeDescriptors = (list) => (list || []).map((c) => ({ ...c, id: b64url.decode(c.id) }));
+
+	async function postJSON(url, body, headers) {
+		const res = await fetch(url, {
+			method: 'POST',
+			credentials: 'same-origin',
+			headers: { 'Accept': 'application/json', 'Content-Type': 'application/json', ...(headers || {}) },
+			body: body === undefined ? undefined : JSON.stringify(body),
+		});
+		const data = await res.json().catch(() => ({}));
+		if (!res.ok) throw new Error(data.error || `Request failed (${res.status})`);
+		return data;
+	}
+
+	function setStatus(el, msg) {
+		if (el) el.textContent = msg;
+	}
+
+	async function login(btn) {
+		const status = document.querySelector('[data-passkey-status]');
+		const next = btn.dataset.next || '';
+		const remember = document.querySelector('input[name="remember"]');
+		btn.disabled = true;
+		setStatus(status, '');
+		try {
+			const { publicKey } = await postJSON('/login/passkey/options?next=' + encodeURIComponent(next) + (remember && remember.checked ? '&remember=1' : ''));
+			publicKey.challenge = b64url.decode(publicKey.challenge);
+			publicKey.allowCredentials = decodeDescriptors(publicKey.allowCredentials);
+			const cred = await navigator.credentials.get({ publicKey });
+			const result = await postJSON('/login/passkey', {
+				id: b64url.encode(cred.rawId),
+				clientDataJSON: b64url.encode(cred.response.clientDataJSON),
+				authenticatorData: b64url.encode(cred.response.authenticatorData),
+				signature: b64url.encode(cred.response.signature),
+			});
+			window.location.assign(result.redirect || '/admin');
+		} catch (err) {
+			setStatus(status, err.name === 'NotAllowedError' ? 'Passkey sign-in was cancelled.' : err.message);
+			btn.disabled = false;
+		}
+	}
+
+	async function register(btn) {
+		const status = document.querySelector('[data-passkey-status]');
+		const nameInput = document.querySelector('[data-passkey-name]');
+		const headers = { 'X-CSRF-Token': btn.dataset.csrf };
+		btn.disabled = true;
+		setStatus(status, '');
+		try {
+			const { publicKey } = await postJSON('/admin/passkeys/options', undefined, headers);
+			publicKey.challenge = b64url.decode(publicKey.challenge);
+			publicKey.user.id = b64url.decode(publicKey.user.id);
+			publicKey.excludeCredentials = decodeDescriptors(publicKey.excludeCredentials);
+			const cred = await navigator.credentials.create({ publicKey });
+			await postJSON('/admin/passkeys', {
+				name: nameInput ? nameInput.value : '',
+				id: b64url.encode(cred.rawId),
+				clientDataJSON: b64url.encode(cred.response.clientDataJSON),
+				attestationObject: b64url.encode(cred.response.attestationObject),
+			}, headers);
+			window.location.reload();
+		} catch (err) {
+			setStatus(status, err.name === 'NotAllowedError' ? 'Registration was cancelled.' : err.message);
+			btn.disabled = false;
+		}
+	}
+
+	document.addEventListener('DOMContentLoaded', () => {
+		const supported = !!(window.PublicKeyCredential && navigator.credentials);
+		document.querySelectorAll('[data-passkey-login], [data-passkey-register]').forEach((btn) => {
+			if (!supported) {
+				btn.disabled = true;
+				btn.title = 'This browser does not support passkeys';
+				return;
+			}
+			btn.addEventListener('click', () => (btn.hasAttribute('data-passkey-login') ? login(btn) : register(btn)));
+		});
+	});
+})();
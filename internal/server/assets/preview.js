@@ -0,0 +1,147 @@
+// MiniSnap 前端：编辑器实时预览。
+// 输入停顿后把内容提交到预览接口，将返回的已消毒 HTML 写入同源预览框；
+// 支持编辑 / 分栏 / 预览三种布局，分栏时按比例同步源码与预览的滚动位置。
+(function () {
+	const workspace = document.querySelector('[data-workspace]');
+	const textarea = document.getElementById('content');
+	const frame = workspace ? workspace.querySelector('[data-preview-frame]') : null;
+	if (!workspace || !textarea || !frame) return;
+
+	const LAYOUT_KEY = 'minisnap.editor-layout';
+	const LAYOUTS = ['edit', 'split', 'preview'];
+	const DEBOUNCE_MS = 300;
+
+	const renderer = document.getElementById('renderer');
+	const status = workspace.querySelector('[data-preview-status]');
+	const buttons = document.querySelectorAll('[data-layout-option]');
+
+	let seq = 0; // 最近一次请求的序号，用于丢弃过期响应
+	let timer = 0;
+	let lastSent = null; // 最近一次成功提交的内容，未变化时不重复请求
+	let locked = null; // 正在被程序滚动的一侧，避免两侧互相触发
+	let unlockTimer = 0;
+
+	function frameDoc() {
+		try { return frame.contentDocument; } catch (e) { return null; }
+	}
+
+	function frameScroller() {
+		const doc = frameDoc();
+		return doc ? doc.scrollingElement : null;
+	}
+
+	function setStatus(msg) {
+		if (!status) return;
+		status.textContent = msg;
+		status.hidden = !msg;
+	}
+
+	// syncTheme 让预览框跟随编辑页的亮/暗主题（框内禁止脚本，由父页面设置）。
+	function syncTheme() {
+		const doc = frameDoc();
+		const theme = document.documentElement.getAttribute('data-theme');
+		if (!doc) return;
+		if (theme) doc.documentElement.setAttribute('data-theme', theme);
+		else doc.documentElement.removeAttribute('data-theme');
+	}
+
+	async function refresh() {
+		if (workspace.dataset.layout === 'edit') return;
+		const body = new URLSearchParams({
+			renderer: renderer ? renderer.value : 'markdown',
+			content: textarea.value,
+		});
+		const key = body.toString();
+		if (key === lastSent) return;
+		lastSent = key;
+		const id = ++seq;
+		try {
+			const res = await fetch(workspace.dataset.previewUrl, {
+				method: 'POST',
+				credentials: 'same-origin',
+				headers: { 'Accept': 'application/json', 'X-CSRF-Token': workspace.dataset.csrf },
+				body,
+			});
+			const data = await res.json().catch(() => ({}));
+			if (id !== seq) return;
+			if (!res.ok) throw new Error(data.error || `Preview failed (${res.status})`);
+			const doc = frameDoc();
+			const target = doc ? doc.querySelector('[data-preview-body]') : null;
+			if (!target) {
+				// 预览框尚未加载完成，load 事件中会重新渲染。
+				lastSent = null;
+				return;
+			}
+			target.innerHTML = data.html;
+			setStatus('');
+			sync(textarea);
+		} catch (err) {
+			if (id !== seq) return;
+			lastSent = null;
+			setStatus(err.message);
+		}
+	}
+
+	function schedule() {
+		clearTimeout(timer);
+		timer = setTimeout(refresh, DEBOUNCE_MS);
+	}
+
+	function ratio(el) {
+		const max = el.scrollHeight - el.clientHeight;
+		return max > 0 ? el.scrollTop / max : 0;
+	}
+
+	// sync 按滚动比例把 source 的位置同步到另一侧，仅在分栏布局下生效。
+	function sync(source) {
+		const scroller = frameScroller();
+		if (!scroller || workspace.dataset.layout !== 'split') return;
+		const target = source === textarea ? scroller : textarea;
+		locked = target;
+		clearTimeout(unlockTimer);
+		unlockTimer = setTimeout(() => { locked = null; }, 100);
+		target.scrollTop = ratio(source) * (target.scrollHeight - target.clientHeight);
+	}
+
+	function applyLayout(layout, persist) {
+		if (!LAYOUTS.includes(layout)) layout = 'split';
+		workspace.dataset.layout = layout;
+		document.body.dataset.editorLayout = layout;
+		buttons.forEach((btn) => {
+			btn.setAttribute('aria-pressed', String(btn.dataset.layoutOption === layout));
+		});
+		if (persist) {
+			try { window.localStorage.setItem(LAYOUT_KEY, layout); } catch (e) {}
+		}
+		if (layout !== 'edit') {
+			clearTimeout(timer);
+			refresh();
+		}
+	}
+
+	frame.addEventListener('load', () => {
+		syncTheme();
+		lastSent = null;
+		refresh();
+		const doc = frameDoc();
+		if (doc) {
+			doc.addEventListener('scroll', () => {
+				if (locked !== frameScroller()) sync(frameScroller());
+			});
+		}
+	});
+	new MutationObserver(syncTheme).observe(document.documentElement, { attributes: true, attributeFilter: ['data-theme'] });
+
+	textarea.addEventListener('input', schedule);
+	textarea.addEventListener('scroll', () => {
+		if (locked !== textarea) sync(textarea);
+	});
+	if (renderer) renderer.addEventListener('change', schedule);
+	buttons.forEach((btn) => {
+		btn.addEventListener('click', () => applyLayout(btn.dataset.layoutOption, true));
+	});
+
+	let saved = null;
+	try { saved = window.localStorage.getItem(LAYOUT_KEY); } catch (e) {}
+	applyLayout(saved || 'split', false);
+})();
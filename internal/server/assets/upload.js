@@ -0,0 +1,89 @@
+// MiniSnap 前端：编辑器附件上传。
+// 支持按钮选择、拖放与粘贴剪贴板中的文件，上传成功后在光标处插入 Markdown 或 HTML 引用。
+(function () {
+	const bar = document.querySelector('[data-upload]');
+	const textarea = document.getElementById('content');
+	if (!bar || !textarea) return;
+
+	const renderer = document.getElementById('renderer');
+	const input = bar.querySelector('[data-upload-input]');
+	const status = bar.querySelector('[data-upload-status]');
+	const idleText = status ? status.textContent : '';
+
+	function setStatus(msg) {
+		if (status) status.textContent = msg;
+	}
+
+	// insertAtCursor 在选区处插入文本并触发 input 事件，使未保存提示生效。
+	function insertAtCursor(text) {
+		const start = textarea.selectionStart;
+		const end = textarea.selectionEnd;
+		const before = textarea.value.slice(0, start);
+		const pad = before && !before.endsWith('\n') ? '\n' : '';
+		const snippet = pad + text + '\n';
+		textarea.setRangeText(snippet, start, end, 'end');
+		textarea.dispatchEvent(new Event('input', { bubbles: true }));
+		textarea.focus();
+	}
+
+	async function upload(file) {
+		const body = new FormData();
+		body.append('file', file, file.name || 'pasted-file');
+		const res = await fetch('/admin/files', {
+			method: 'POST',
+			credentials: 'same-origin',
+			headers: { 'Accept': 'application/json', 'X-CSRF-Token': bar.dataset.csrf },
+			body,
+		});
+		const data = await res.json().catch(() => ({}));
+		if (!res.ok) throw new Error(data.error || `Upload failed (${res.status})`);
+		return data;
+	}
+
+	async function uploadAll(files) {
+		const list = Array.from(files || []);
+		if (list.length === 0) return;
+		let done = 0;
+		for (const file of list) {
+			setStatus(`Uploading ${file.name || 'file'} (${done + 1}/${list.length})...`);
+			try {
+				const res = await upload(file);
+				insertAtCursor(renderer && renderer.value === 'html' ? res.html : res.markdown);
+				done++;
+			} catch (err) {
+				setStatus(`${file.name || 'File'}: ${err.message}`);
+				return;
+			}
+		}
+		setStatus(done === 1 ? 'Uploaded 1 file.' : `Uploaded ${done} files.`);
+		setTimeout(() => setStatus(idleText), 4000);
+	}
+
+	const pick = bar.querySelector('[data-upload-pick]');
+	if (pick && input) {
+		pick.addEventListener('click', () => input.click());
+		input.addEventListener('change', () => {
+			uploadAll(input.files).finally(() => { input.value = ''; });
+		});
+	}
+
+	textarea.addEventListener('dragover', (e) => {
+		if (e.dataTransfer && Array.from(e.dataTransfer.types).includes('Files')) {
+			e.preventDefault();
+			textarea.classList.add('drop-target');
+		}
+	});
+	textarea.addEventListener('dragleave', () => textarea.classList.remove('drop-target'));
+	textarea.addEventListener('drop', (e) => {
+		textarea.classList.remove('drop-target');
+		if (!e.dataTransfer || e.dataTransfer.files.length === 0) return;
+		e.preventDefault();
+		uploadAll(e.dataTransfer.files);
+	});
+	textarea.addEventListener('paste', (e) => {
+		const files = e.clipboardData ? e.clipboardData.files : null;
+		if (!files || files.length === 0) return;
+		e.preventDefault();
+		uploadAll(files);
+	});
+})();
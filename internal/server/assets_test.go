@@ -71,3 +71,20 @@ func TestStaticRouteDoesNotShadowSlug(t *testing.T) {
 		t.Errorf("slug route: status = %d, want 200", w.Code)
 	}
 }
+
+// TestAssetVersionCoversAllAssets 验证资源版本涵盖 staticAssets 中的每个资源。
+func TestAssetVersionCoversAllAssets(t *testing.T) {
+	if computeAssetVersion() != assetVersion {
+		t.Fatalf("asset version must be deterministic")
+	}
+	for name, a := range staticAssets {
+		changed := a
+		changed.hash = "0000000000000000"
+		staticAssets[name] = changed
+		got := computeAssetVersion()
+		staticAssets[name] = a
+		if got == assetVersion {
+			t.Errorf("changing %s did not change the asset version", name)
+		}
+	}
+}
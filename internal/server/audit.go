@@ -0,0 +1,135 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"minisnap/internal/audit"
+)
+
+// auditPageLimit 为审计页面单次展示的最大记录数。
+const auditPageLimit = 200
+
+type auditTemplateData struct {
+	Title     string
+	Events    []auditItem
+	Filter    auditFilterForm
+	HasFilter bool
+	Truncated bool
+	Error     string
+	CSRFToken string
+}
+
+type auditItem struct {
+	Time   string
+	Action string
+	Actor  string
+	IP     string
+	Slug   string
+	Hash   string
+	Detail string
+	// Danger 标记删除、登录失败等需要留意的记录。
+	Danger bool
+}
+
+// auditFilterForm 回填到页面筛选表单的原始输入。
+type auditFilterForm struct {
+	Action, Actor, IP, Slug, Since string
+}
+
+// audit 写入一条审计记录，未指定的操作者与 IP 取自当前请求。
+// 写入失败只记日志，不影响请求本身。
+func (s *Server) audit(r *http.Request, e audit.Event) {
+	if e.Actor == "" {
+		e.Actor = s.actor(r)
+	}
+	if e.IP == "" {
+		e.IP = s.clientIP(r)
+	}
+	if err := s.auditLog.Append(e); err != nil {
+		slog.ErrorContext(r.Context(), "write audit log", "action", e.Action, "error", err)
+	}
+}
+
+// actor 返回当前请求所属会话的登录身份，未登录时为空。
+func (s *Server) actor(r *http.Request) string {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return ""
+	}
+	return s.sessions.Actor(cookie.Value)
+}
+
+// loginFailed 统计一次失败的登录尝试并计入限流；触发锁定时额外记录锁定事件。
+func (s *Server) loginFailed(r *http.Request, method string) {
+	ip := s.clientIP(r)
+	s.metrics.loginFailures.Inc()
+	s.audit(r, audit.Event{Action: audit.LoginFailure, IP: ip, Detail: method})
+	if s.loginLim.recordFailure(ip, time.Now()) {
+		s.metrics.loginLockouts.Inc()
+		s.audit(r, audit.Event{Action: audit.LoginLockout, IP: ip})
+	}
+}
+
+// showAudit 按条件展示审计日志，最新的在前。
+func (s *Server) showAudit(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	form := auditFilterForm{
+		Action: strings.TrimSpace(q.Get("action")),
+		Actor:  strings.TrimSpace(q.Get("actor")),
+		IP:     strings.TrimSpace(q.Get("ip")),
+		Slug:   strings.TrimSpace(q.Get("slug")),
+		Since:  strings.TrimSpace(q.Get("since")),
+	}
+	data := auditTemplateData{
+		Title:     "Audit Log",
+		Filter:    form,
+		HasFilter: form != auditFilterForm{},
+		CSRFToken: s.csrfToken(r),
+	}
+
+	filter := audit.Filter{Action: form.Action, Actor: form.Actor, IP: form.IP, Slug: form.Slug, Limit: auditPageLimit + 1}
+	if form.Since != "" {
+		since, err := time.ParseInLocation("2006-01-02", form.Since, time.Local)
+		if err != nil {
+			data.Error = "Since must be a date like 2024-05-01"
+			s.renderTemplateStatus(w, r, http.StatusBadRequest, "audit.tmpl", data)
+			return
+		}
+		filter.Since = since
+	}
+
+	events, err := s.auditLog.Query(filter)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "read audit log", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Audit Log", "Failed to read the audit log.")
+		return
+	}
+	if len(events) > auditPageLimit {
+		events, data.Truncated = events[:auditPageLimit], true
+	}
+	for _, e := range events {
+		data.Events = append(data.Events, auditItem{
+			Time:   e.Time.Local().Format("2006-01-02 15:04:05"),
+			Action: e.Action,
+			Actor:  e.Actor,
+			IP:     e.IP,
+			Slug:   e.Slug,
+			Hash:   shortHash(e.Hash),
+			Detail: e.Detail,
+			Danger: e.Action == audit.EntryDelete || e.Action == audit.LoginFailure || e.Action == audit.LoginLockout,
+		})
+	}
+	s.renderTemplate(w, r, "audit.tmpl", data)
+}
+
+// shortHash 截短内容摘要用于展示，完整值保留在日志文件中。
+func shortHash(h string) string {
+	h = strings.TrimPrefix(h, "sha256:")
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
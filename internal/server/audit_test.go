@@ -0,0 +1,112 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"minisnap/internal/audit"
+)
+
+func auditEvents(t *testing.T, srv *Server, f audit.Filter) []audit.Event {
+	t.Helper()
+	events, err := srv.auditLog.Query(f)
+	if err != nil {
+		t.Fatalf("query audit log: %v", err)
+	}
+	return events
+}
+
+func TestAuditRecordsLoginsAndEntryChanges(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+
+	postForm(srv, "/login", url.Values{"password": {"wrong"}}, &http.Cookie{Name: "x", Value: "y"}, "")
+	cookie := loginWith(t, srv, url.Values{"password": {"testpass"}}, "test")
+	csrf := srv.sessions.CSRFToken(cookie.Value)
+
+	logins := auditEvents(t, srv, audit.Filter{Action: "login"})
+	if len(logins) != 2 || logins[0].Action != audit.LoginSuccess || logins[1].Action != audit.LoginFailure {
+		t.Fatalf("unexpected login events: %+v", logins)
+	}
+	if logins[0].Actor != adminUser || logins[0].IP != "192.0.2.1" || logins[1].Actor != "" {
+		t.Fatalf("unexpected actor/ip: %+v", logins)
+	}
+
+	w := postForm(srv, "/admin", url.Values{csrfFieldName: {csrf}, "renderer": {"markdown"}, "content": {"# One"}}, cookie, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("create: status %d", w.Code)
+	}
+	created := auditEvents(t, srv, audit.Filter{Action: audit.EntryCreate})
+	if len(created) != 1 || created[0].Actor != adminUser || created[0].Hash != audit.ContentHash("# One") {
+		t.Fatalf("unexpected create event: %+v", created)
+	}
+	slug := created[0].Slug
+
+	postForm(srv, "/"+slug+"/edit", url.Values{csrfFieldName: {csrf}, "renderer": {"markdown"}, "content": {"# Two"}}, cookie, "")
+	if w := postForm(srv, "/"+slug+"/delete", url.Values{csrfFieldName: {csrf}}, cookie, ""); w.Code != http.StatusFound {
+		t.Fatalf("delete: status %d", w.Code)
+	}
+
+	history := auditEvents(t, srv, audit.Filter{Slug: slug})
+	if len(history) != 3 || history[0].Action != audit.EntryDelete || history[1].Action != audit.EntryUpdate {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+	// 删除记录携带删除前的内容摘要
+	if history[0].Hash != audit.ContentHash("# Two") || history[0].Actor != adminUser {
+		t.Fatalf("delete event: %+v", history[0])
+	}
+}
+
+func TestAuditRecordsLockoutAndSettings(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	for i := 0; i < 10 && len(srv.loginLim.locked(time.Now())) == 0; i++ {
+		postForm(srv, "/login", url.Values{"password": {"wrong"}}, &http.Cookie{Name: "x", Value: "y"}, "")
+	}
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.LoginLockout}); len(got) != 1 || got[0].IP != "192.0.2.1" {
+		t.Fatalf("expected one lockout event, got %+v", got)
+	}
+
+	srv.loginLim.release("192.0.2.1")
+	cookie := loginWith(t, srv, url.Values{"password": {"testpass"}}, "test")
+	w := postForm(srv, "/admin/sessions/revoke-all", url.Values{csrfFieldName: {srv.sessions.CSRFToken(cookie.Value)}}, cookie, "")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("revoke all: status %d", w.Code)
+	}
+	// 撤销当前会话后仍能记下操作者
+	got := auditEvents(t, srv, audit.Filter{Action: "settings"})
+	if len(got) != 1 || got[0].Action != audit.SessionsRevoked || got[0].Actor != adminUser {
+		t.Fatalf("unexpected settings events: %+v", got)
+	}
+}
+
+func TestAuditPage(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie := loginWith(t, srv, url.Values{"password": {"testpass"}}, "test")
+	_ = srv.auditLog.Append(audit.Event{Action: audit.EntryDelete, Actor: adminUser, Slug: "gone1234", Hash: audit.ContentHash("x")})
+
+	w := getWithCookie(srv, "/admin/audit", cookie)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "entry.delete") || !strings.Contains(body, "login.success") {
+		t.Fatalf("audit page: status %d, body %s", w.Code, body)
+	}
+
+	w = getWithCookie(srv, "/admin/audit?slug=gone1234", cookie)
+	body = w.Body.String()
+	if !strings.Contains(body, "entry.delete") || strings.Contains(body, "login.success") {
+		t.Fatalf("slug filter not applied: %s", body)
+	}
+
+	w = getWithCookie(srv, "/admin/audit?since=yesterday", cookie)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid since: status %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Fatalf("invalid since: Content-Type %q", ct)
+	}
+
+	if w := getWithCookie(srv, "/admin/audit", &http.Cookie{Name: sessionCookieName, Value: "bogus"}); w.Code != http.StatusFound {
+		t.Fatalf("audit page must require login: status %d", w.Code)
+	}
+}
@@ -0,0 +1,215 @@
+package server
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/content"
+)
+
+// maxBulkItems 限制单次批量操作的条目数，防止一次请求长时间占用存储锁。
+const maxBulkItems = 1000
+
+// 批量操作类型，对应内容库操作栏中的选项。
+const (
+	bulkDelete   = "delete"
+	bulkRenderer = "renderer"
+	bulkExport   = "export"
+)
+
+type bulkTemplateData struct {
+	Title     string
+	Action    string
+	Results   []bulkResult
+	Succeeded int
+	Failed    int
+	CSRFToken string
+}
+
+// bulkResult 是批量操作中单个条目的结果。
+type bulkResult struct {
+	Slug    string
+	OK      bool
+	Message string
+}
+
+// bulkLibrary 对内容库中选中的多个条目执行同一操作，并逐条汇报结果。
+// 导出直接下发 zip 附件，其余操作渲染结果汇总页。
+func (s *Server) bulkLibrary(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		s.renderErrorPage(w, r, http.StatusBadRequest, "Bulk action", "Invalid form data.")
+		return
+	}
+	slugs := uniqueSlugs(r.Form["slug"])
+	if len(slugs) == 0 {
+		s.renderErrorPage(w, r, http.StatusBadRequest, "Bulk action", "Select at least one entry.")
+		return
+	}
+	if len(slugs) > maxBulkItems {
+		s.renderErrorPage(w, r, http.StatusRequestEntityTooLarge, "Bulk action", fmt.Sprintf("Select at most %d entries at a time.", maxBulkItems))
+		return
+	}
+
+	var results []bulkResult
+	switch action := r.FormValue("action"); action {
+	case bulkDelete:
+		results = s.bulkApply(r, slugs, s.bulkDeleteOne)
+	case bulkRenderer:
+		renderer := content.RendererType(r.FormValue("renderer"))
+		if renderer != content.RendererMarkdown && renderer != content.RendererHTML {
+			s.renderErrorPage(w, r, http.StatusBadRequest, "Bulk action", "Choose a renderer.")
+			return
+		}
+		results = s.bulkApply(r, slugs, func(r *http.Request, slug string) (string, error) {
+			return s.bulkSetRenderer(r, slug, renderer)
+		})
+	case bulkExport:
+		s.bulkExport(w, r, slugs)
+		return
+	default:
+		s.renderErrorPage(w, r, http.StatusBadRequest, "Bulk action", "Unknown bulk action.")
+		return
+	}
+
+	data := bulkTemplateData{
+		Title:     "Bulk " + bulkActionLabel(r.FormValue("action")),
+		Action:    r.FormValue("action"),
+		Results:   results,
+		CSRFToken: s.csrfToken(r),
+	}
+	for _, res := range results {
+		if res.OK {
+			data.Succeeded++
+		} else {
+			data.Failed++
+		}
+	}
+	slog.InfoContext(r.Context(), "bulk action", "action", data.Action, "succeeded", data.Succeeded, "failed", data.Failed)
+	s.renderTemplate(w, r, "bulk.tmpl", data)
+}
+
+// bulkApply 依次对每个条目执行 fn，单条失败不影响其余条目。
+func (s *Server) bulkApply(r *http.Request, slugs []string, fn func(r *http.Request, slug string) (string, error)) []bulkResult {
+	results := make([]bulkResult, 0, len(slugs))
+	for _, slug := range slugs {
+		msg, err := fn(r, slug)
+		switch {
+		case errors.Is(err, content.ErrEntryNotFound):
+			results = append(results, bulkResult{Slug: slug, Message: "Not found"})
+		case err != nil:
+			slog.ErrorContext(r.Context(), "bulk action", "slug", slug, "error", err)
+			// 存储错误可能包含内容目录路径，只写入日志，页面显示通用提示。
+			results = append(results, bulkResult{Slug: slug, Message: "Failed"})
+		default:
+			results = append(results, bulkResult{Slug: slug, OK: true, Message: msg})
+		}
+	}
+	return results
+}
+
+func (s *Server) bulkDeleteOne(r *http.Request, slug string) (string, error) {
+	entry, err := s.store.Get(slug)
+	if err != nil {
+		return "", err
+	}
+	if err := s.store.Delete(slug); err != nil {
+		return "", err
+	}
+	s.audit(r, audit.Event{Action: audit.EntryDelete, Slug: slug, Hash: audit.ContentHash(entry.Raw), Detail: "bulk"})
+	return "Moved to trash", nil
+}
+
+func (s *Server) bulkSetRenderer(r *http.Request, slug string, renderer content.RendererType) (string, error) {
+	entry, err := s.store.Get(slug)
+	if err != nil {
+		return "", err
+	}
+	if entry.Renderer == renderer {
+		return "Already " + string(renderer), nil
+	}
+	updated, err := s.store.Update(slug, renderer, entry.Raw, entry.Description)
+	if err != nil {
+		return "", err
+	}
+	s.audit(r, audit.Event{Action: audit.EntryUpdate, Slug: slug, Hash: audit.ContentHash(updated.Raw), Detail: "bulk: renderer " + string(renderer)})
+	return "Renderer set to " + string(renderer), nil
+}
+
+// bulkExport 把选中条目的源码打包为 zip 下载，文件名与单条下载一致。
+// 找不到的条目跳过；全部缺失时改为渲染结果汇总页。
+func (s *Server) bulkExport(w http.ResponseWriter, r *http.Request, slugs []string) {
+	var entries []content.Entry
+	var missing []bulkResult
+	for _, slug := range slugs {
+		entry, err := s.store.Get(slug)
+		if err != nil {
+			missing = append(missing, bulkResult{Slug: slug, Message: "Not found"})
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		s.renderTemplate(w, r, "bulk.tmpl", bulkTemplateData{
+			Title:     "Bulk " + bulkActionLabel(bulkExport),
+			Action:    bulkExport,
+			Results:   missing,
+			Failed:    len(missing),
+			CSRFToken: s.csrfToken(r),
+		})
+		return
+	}
+
+	name := "minisnap-export-" + time.Now().Format("20060102-150405") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("Cache-Control", "no-store")
+	zw := zip.NewWriter(w)
+	for _, entry := range entries {
+		f, err := zw.CreateHeader(&zip.FileHeader{Name: sourceFilename(entry), Method: zip.Deflate, Modified: entry.UpdatedAt})
+		if err == nil {
+			_, err = f.Write([]byte(entry.Raw))
+		}
+		if err != nil {
+			// 响应头已发出，只能记录错误并中止
+			slog.ErrorContext(r.Context(), "bulk export", "slug", entry.Slug, "error", err)
+			return
+		}
+	}
+	if err := zw.Close(); err != nil {
+		slog.ErrorContext(r.Context(), "bulk export", "error", err)
+	}
+}
+
+// uniqueSlugs 去除空值与重复项，保持提交顺序。
+func uniqueSlugs(in []string) []string {
+	seen := make(map[string]bool, len(in))
+	out := make([]string, 0, len(in))
+	for _, slug := range in {
+		slug = strings.TrimSpace(slug)
+		if slug == "" || seen[slug] {
+			continue
+		}
+		seen[slug] = true
+		out = append(out, slug)
+	}
+	return out
+}
+
+func bulkActionLabel(action string) string {
+	switch action {
+	case bulkDelete:
+		return "delete"
+	case bulkRenderer:
+		return "renderer change"
+	case bulkExport:
+		return "export"
+	}
+	return "action"
+}
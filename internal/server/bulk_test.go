@@ -0,0 +1,142 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/content"
+)
+
+func TestBulkDelete(t *testing.T) {
+	srv, first := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+	second, _ := srv.store.Create(content.RendererMarkdown, "# Two", "")
+
+	form := url.Values{csrfFieldName: {csrf}, "action": {bulkDelete}, "slug": {first.Slug, second.Slug, "missing1", first.Slug}}
+	w := postForm(srv, "/admin/library/bulk", form, cookie, "")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "2 succeeded · 1 failed") || !strings.Contains(body, "Not found") {
+		t.Fatalf("bulk delete: status %d, body %s", w.Code, body)
+	}
+	if entries, _ := srv.store.List(); len(entries) != 0 {
+		t.Fatalf("expected library to be empty, got %d", len(entries))
+	}
+	if trash, _ := srv.store.Trash(); len(trash) != 2 {
+		t.Fatalf("expected both entries in trash, got %d", len(trash))
+	}
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.EntryDelete}); len(got) != 2 {
+		t.Fatalf("expected one audit record per deleted entry, got %+v", got)
+	}
+}
+
+func TestBulkChangeRenderer(t *testing.T) {
+	srv, first := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+	html, _ := srv.store.Create(content.RendererHTML, "<p>x</p>", "")
+
+	form := url.Values{csrfFieldName: {csrf}, "action": {bulkRenderer}, "renderer": {"html"}, "slug": {first.Slug, html.Slug}}
+	w := postForm(srv, "/admin/library/bulk", form, cookie, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Renderer set to html") || !strings.Contains(w.Body.String(), "Already html") {
+		t.Fatalf("bulk renderer: status %d, body %s", w.Code, w.Body.String())
+	}
+	if got, _ := srv.store.Get(first.Slug); got.Renderer != content.RendererHTML || got.Raw != first.Raw {
+		t.Fatalf("renderer not changed: %+v", got)
+	}
+
+	form.Set("renderer", "plain")
+	if w := postForm(srv, "/admin/library/bulk", form, cookie, ""); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid renderer: status %d", w.Code)
+	}
+}
+
+func TestBulkExport(t *testing.T) {
+	srv, first := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+	html, _ := srv.store.Create(content.RendererHTML, "<p>x</p>", "")
+
+	form := url.Values{csrfFieldName: {csrf}, "action": {bulkExport}, "slug": {first.Slug, html.Slug, "missing1"}}
+	w := postForm(srv, "/admin/library/bulk", form, cookie, "")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "application/zip" {
+		t.Fatalf("export: status %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment;") {
+		t.Fatalf("expected attachment, got %q", w.Header().Get("Content-Disposition"))
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+	if err != nil {
+		t.Fatalf("open zip: %v", err)
+	}
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+	if len(files) != 2 || files[first.Slug+".md"] != first.Raw || files[html.Slug+".html"] != "<p>x</p>" {
+		t.Fatalf("unexpected zip contents: %v", files)
+	}
+
+	// 全部缺失时返回结果页而不是空压缩包
+	form.Set("slug", "missing1")
+	w = postForm(srv, "/admin/library/bulk", form, cookie, "")
+	if w.Header().Get("Content-Type") == "application/zip" || !strings.Contains(w.Body.String(), "0 succeeded · 1 failed") {
+		t.Fatalf("export of missing entries: %s", w.Body.String())
+	}
+}
+
+func TestBulkRejectsInvalidRequests(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	cases := map[string]url.Values{
+		"no selection":   {csrfFieldName: {csrf}, "action": {bulkDelete}},
+		"unknown action": {csrfFieldName: {csrf}, "action": {"publish"}, "slug": {entry.Slug}},
+	}
+	for name, form := range cases {
+		if w := postForm(srv, "/admin/library/bulk", form, cookie, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", name, w.Code)
+		}
+	}
+
+	many := url.Values{csrfFieldName: {csrf}, "action": {bulkDelete}}
+	for i := 0; i <= maxBulkItems; i++ {
+		many.Add("slug", fmt.Sprintf("s%04d", i))
+	}
+	if w := postForm(srv, "/admin/library/bulk", many, cookie, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("too many items: status %d, want 413", w.Code)
+	}
+	if _, err := srv.store.Get(entry.Slug); err != nil {
+		t.Fatalf("rejected requests must not touch entries: %v", err)
+	}
+}
+
+// TestBulkHidesStoreErrors 验证存储错误只写入日志，结果页不暴露内容目录路径。
+func TestBulkHidesStoreErrors(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+	// 回收站目录被同名文件占用，移入回收站必然失败，错误信息中带有路径。
+	if err := os.WriteFile(filepath.Join(srv.store.Dir(), ".trash"), nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	form := url.Values{csrfFieldName: {csrf}, "action": {bulkDelete}, "slug": {entry.Slug}}
+	w := postForm(srv, "/admin/library/bulk", form, cookie, "")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "0 succeeded · 1 failed") {
+		t.Fatalf("bulk delete: status %d, body %s", w.Code, body)
+	}
+	if strings.Contains(body, srv.store.Dir()) || strings.Contains(body, "trash dir") {
+		t.Fatalf("result page leaks the store error: %s", body)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"minisnap/internal/content"
+)
+
+// maxAgeDirective 生成 Cache-Control 值；时长为零时要求客户端每次回源校验。
+func maxAgeDirective(scope string, maxAge time.Duration) string {
+	if maxAge <= 0 {
+		return "no-cache"
+	}
+	return scope + ", max-age=" + strconv.Itoa(int(maxAge/time.Second))
+}
+
+// pageCacheControl 返回阅读页与源码端点的 Cache-Control。
+// 已登录访客看到的页面包含编辑入口，不能进入共享缓存。
+func (s *Server) pageCacheControl(authenticated bool) string {
+	if authenticated {
+		return "private, no-cache"
+	}
+	return maxAgeDirective("public", s.cfg.PageCacheMaxAge)
+}
+
+// pageETag 由条目内容、更新时间与影响输出的其他因素（模板/资源版本、登录态）派生。
+// 任一因素变化都会产生新的 ETag，从而绕过客户端缓存。
+func (s *Server) pageETag(entry content.Entry, authenticated bool) string {
+	h := sha256.New()
+	h.Write([]byte(entry.Slug))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Renderer))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.UpdatedAt.UTC().Format(time.RFC3339Nano)))
+	h.Write([]byte{0})
+	h.Write([]byte(entry.Raw))
+	h.Write([]byte{0})
+	h.Write([]byte(s.pageVersion))
+	h.Write([]byte(content.RendererVersion))
+	if authenticated {
+		h.Write([]byte{0, 1})
+	}
+	return `W/"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// setValidators 写出缓存校验相关的响应头。
+func setValidators(w http.ResponseWriter, etag string, modtime time.Time, cacheControl string) {
+	h := w.Header()
+	h.Set("ETag", etag)
+	if !modtime.IsZero() {
+		h.Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
+	}
+	h.Set("Cache-Control", cacheControl)
+	h.Add("Vary", "Cookie")
+}
+
+// notModified 判断条件请求是否命中。If-None-Match 优先于 If-Modified-Since（RFC 9110 §13.2.2）。
+func notModified(r *http.Request, etag string, modtime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, etag)
+	}
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || modtime.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	// HTTP 日期精度为秒
+	return !modtime.Truncate(time.Second).After(t)
+}
+
+// etagListMatches 对 If-None-Match 做弱比较。
+func etagListMatches(header, etag string) bool {
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
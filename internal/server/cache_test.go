@@ -0,0 +1,208 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+// TestViewPageConditionalGET 验证阅读页携带 ETag / Last-Modified，并对条件请求返回 304。
+func TestViewPageConditionalGET(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", PageCacheMaxAge: 5 * time.Minute}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	entry, err := store.Create(content.RendererMarkdown, "# Cached", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+	if w.Header().Get("Last-Modified") == "" {
+		t.Errorf("expected Last-Modified header")
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Errorf("Cache-Control = %q, want public, max-age=300", got)
+	}
+
+	// If-None-Match 命中
+	req = httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("If-None-Match: status = %d, want 304", w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("304 response must not carry a body")
+	}
+
+	// If-Modified-Since 命中
+	req = httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil)
+	req.Header.Set("If-Modified-Since", entry.UpdatedAt.Add(time.Second).UTC().Format(http.TimeFormat))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("If-Modified-Since: status = %d, want 304", w.Code)
+	}
+
+	// 更新后旧 ETag 失效
+	if _, err := store.Update(entry.Slug, content.RendererMarkdown, "# Changed", ""); err != nil {
+		t.Fatalf("update entry: %v", err)
+	}
+	req = httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil)
+	req.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("stale ETag: status = %d, want 200", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Changed") {
+		t.Errorf("expected updated content after ETag change")
+	}
+}
+
+// TestViewPageAuthenticatedNotShared 验证已登录访客的页面不会进入共享缓存，且 ETag 与匿名访客不同。
+func TestViewPageAuthenticatedNotShared(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", PageCacheMaxAge: time.Minute}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererMarkdown, "# Hi", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	anon := httptest.NewRecorder()
+	srv.ServeHTTP(anon, httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil))
+
+	rec := httptest.NewRecorder()
+	srv.setSession(rec, httptest.NewRequest(http.MethodPost, "/login", nil), false)
+	req := httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil)
+	req.AddCookie(rec.Result().Cookies()[0])
+	authed := httptest.NewRecorder()
+	srv.ServeHTTP(authed, req)
+
+	if got := authed.Header().Get("Cache-Control"); got != "private, no-cache" {
+		t.Errorf("authenticated Cache-Control = %q, want private, no-cache", got)
+	}
+	if anon.Header().Get("ETag") == authed.Header().Get("ETag") {
+		t.Errorf("anonymous and authenticated pages must not share an ETag")
+	}
+}
+
+// TestHashedAssetsImmutable 验证带哈希的资源 URL 长期缓存，不带哈希的使用配置时长。
+func TestHashedAssetsImmutable(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", AssetCacheMaxAge: time.Hour}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	hashed := assetURL("base.css")
+	if hashed == "/-/static/base.css" {
+		t.Fatalf("expected hashed asset URL, got %q", hashed)
+	}
+
+	cases := []struct {
+		path         string
+		wantStatus   int
+		cacheControl string
+	}{
+		{hashed, http.StatusOK, "public, max-age=31536000, immutable"},
+		{"/-/static/base.css", http.StatusOK, "public, max-age=3600"},
+		{"/-/static/base.0000000000000000.css", http.StatusOK, "public, max-age=3600"},
+		{"/-/static/nope.0000000000000000.css", http.StatusNotFound, ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, c.path, nil))
+		if w.Code != c.wantStatus {
+			t.Errorf("%s: status = %d, want %d", c.path, w.Code, c.wantStatus)
+			continue
+		}
+		if c.cacheControl != "" && w.Header().Get("Cache-Control") != c.cacheControl {
+			t.Errorf("%s: Cache-Control = %q, want %q", c.path, w.Header().Get("Cache-Control"), c.cacheControl)
+		}
+		if c.wantStatus == http.StatusOK && !strings.HasPrefix(w.Header().Get("Content-Type"), "text/css") {
+			t.Errorf("%s: Content-Type = %q, want text/css", c.path, w.Header().Get("Content-Type"))
+		}
+	}
+
+	// 资源同样支持条件请求
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, hashed, nil))
+	req := httptest.NewRequest(http.MethodGet, hashed, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w2 := httptest.NewRecorder()
+	srv.ServeHTTP(w2, req)
+	if w2.Code != http.StatusNotModified {
+		t.Errorf("asset conditional GET: status = %d, want 304", w2.Code)
+	}
+}
+
+// TestViewPageUsesRenderCache 验证启用渲染缓存后重复访问命中缓存，编辑后内容立即刷新。
+func TestViewPageUsesRenderCache(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", RenderCacheEntries: 8}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererMarkdown, "# First", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("view %d: status = %d", i, w.Code)
+		}
+	}
+	if st := srv.renderCache.Stats(); st.Hits != 1 || st.Misses != 1 {
+		t.Fatalf("stats = %+v, want 1 hit and 1 miss", st)
+	}
+
+	if _, err := store.Update(entry.Slug, content.RendererMarkdown, "# Second", ""); err != nil {
+		t.Fatalf("update entry: %v", err)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil))
+	if !strings.Contains(w.Body.String(), "Second") {
+		t.Fatalf("expected updated content after edit")
+	}
+}
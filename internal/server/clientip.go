@@ -0,0 +1,195 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// proxyList 是可信反向代理的网段集合。只有直连对端落在其中时才采信转发头，
+// 否则任何人都能伪造 X-Forwarded-For 绕过按 IP 的登录限流。
+type proxyList []netip.Prefix
+
+func (p proxyList) trusts(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range p {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP 解析客户端 IP。直连对端可信时，依次取 Forwarded、X-Forwarded-For、
+// X-Real-IP 中第一个存在的头，从右向左跳过可信代理，第一个不可信的地址即为客户端；
+// 遇到无法解析的条目就停在它右侧最近的可信跳。
+func (p proxyList) clientIP(r *http.Request) string {
+	peer, ok := remoteAddr(r)
+	if !ok {
+		return r.RemoteAddr
+	}
+	if !p.trusts(peer) {
+		return peer.String()
+	}
+
+	hops := forwardedChain(r)
+	client := peer
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			break
+		}
+		client = addr.Unmap()
+		if !p.trusts(client) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// isTLS 判断客户端到达本站的连接是否为 HTTPS：直连 TLS，或由可信代理终止 TLS 并声明协议。
+func (p proxyList) isTLS(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	peer, ok := remoteAddr(r)
+	if !ok || !p.trusts(peer) {
+		return false
+	}
+	if fwd := r.Header.Values("Forwarded"); len(fwd) > 0 {
+		elems := forwardedElements(strings.Join(fwd, ","))
+		if len(elems) > 0 {
+			return strings.EqualFold(elems[len(elems)-1]["proto"], "https")
+		}
+		return false
+	}
+	protos := splitList(strings.Join(r.Header.Values("X-Forwarded-Proto"), ","))
+	return len(protos) > 0 && strings.EqualFold(protos[len(protos)-1], "https")
+}
+
+func remoteAddr(r *http.Request) (netip.Addr, bool) {
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.WithZone("").Unmap(), true
+}
+
+// forwardedChain 返回转发头记录的地址链（从左到右为客户端到最近代理）。
+// 同时存在多种头时以标准化的 Forwarded 为准。
+func forwardedChain(r *http.Request) []string {
+	if fwd := r.Header.Values("Forwarded"); len(fwd) > 0 {
+		var hops []string
+		for _, elem := range forwardedElements(strings.Join(fwd, ",")) {
+			hops = append(hops, forwardedNode(elem["for"]))
+		}
+		return hops
+	}
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		return splitList(strings.Join(xff, ","))
+	}
+	if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+		return []string{xri}
+	}
+	return nil
+}
+
+// forwardedElements 解析 RFC 7239 Forwarded 头，每个元素返回小写参数名到值的映射。
+func forwardedElements(header string) []map[string]string {
+	var elems []map[string]string
+	for _, elem := range splitQuoted(header, ',') {
+		params := make(map[string]string)
+		for _, pair := range splitQuoted(elem, ';') {
+			name, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				continue
+			}
+			value = strings.TrimSpace(value)
+			if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+				value = strings.ReplaceAll(value[1:len(value)-1], `\`, "")
+			}
+			params[strings.ToLower(strings.TrimSpace(name))] = value
+		}
+		elems = append(elems, params)
+	}
+	return elems
+}
+
+// forwardedNode 去掉 Forwarded 节点标识中的端口与 IPv6 方括号；
+// "unknown" 与混淆标识（"_hidden"）原样返回，随后会因无法解析而终止回溯。
+func forwardedNode(node string) string {
+	if strings.HasPrefix(node, "[") {
+		if end := strings.IndexByte(node, ']'); end > 0 {
+			return node[1:end]
+		}
+		return node
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
+
+// splitQuoted 按 sep 切分，忽略引号内的分隔符。
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	inQuote, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuote:
+			i++
+		case s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, strings.TrimSpace(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(parts, strings.TrimSpace(s[start:]))
+}
+
+func splitList(s string) []string {
+	var out []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+type clientIPKey struct{}
+
+// clientIP 返回 instrument 中间件解析并记录的客户端 IP，
+// 保证限流与访问日志看到同一个值；未经中间件时现场解析。
+func (s *Server) clientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return s.proxies.clientIP(r)
+}
+
+func withClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+// secureCookie 判断 cookie 是否应带 Secure 属性：本服务直接提供 TLS，
+// 或请求经可信代理以 HTTPS 到达。会话、二次验证、通行密钥与 OIDC 的 cookie 统一使用。
+func (s *Server) secureCookie(r *http.Request) bool {
+	return s.cfg.TLSEnabled() || s.proxies.isTLS(r)
+}
+
+// requestOrigin 按请求的协议与 Host 推断本站来源，如 https://snap.example.com。
+func (s *Server) requestOrigin(r *http.Request) string {
+	scheme := "http"
+	if s.proxies.isTLS(r) {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+func testProxies(t *testing.T, cidrs ...string) proxyList {
+	t.Helper()
+	var p proxyList
+	for _, c := range cidrs {
+		p = append(p, netip.MustParsePrefix(c))
+	}
+	return p
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := testProxies(t, "10.0.0.0/8", "2001:db8:ffff::/48")
+	tests := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"direct", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"untrusted peer spoofs xff", "203.0.113.5:1234", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "203.0.113.5"},
+		{"untrusted peer spoofs x-real-ip", "203.0.113.5:1234", map[string]string{"X-Real-IP": "198.51.100.7"}, "203.0.113.5"},
+		{"xff single", "10.0.0.1:99", map[string]string{"X-Forwarded-For": "198.51.100.7"}, "198.51.100.7"},
+		{"xff skips trusted hops", "10.0.0.1:99", map[string]string{"X-Forwarded-For": "198.51.100.7, 10.0.0.2"}, "198.51.100.7"},
+		// 客户端自带的伪造值在最左侧，从右回溯时会先遇到代理追加的真实地址
+		{"xff spoofed prefix", "10.0.0.1:99", map[string]string{"X-Forwarded-For": "1.2.3.4, 198.51.100.7"}, "198.51.100.7"},
+		{"xff garbage stops walk", "10.0.0.1:99", map[string]string{"X-Forwarded-For": "198.51.100.7, bogus, 10.0.0.2"}, "10.0.0.2"},
+		{"xff all trusted", "10.0.0.1:99", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"x-real-ip", "10.0.0.1:99", map[string]string{"X-Real-IP": "198.51.100.9"}, "198.51.100.9"},
+		{"forwarded", "10.0.0.1:99", map[string]string{"Forwarded": `for=198.51.100.7;proto=https, for="10.0.0.2:8080"`}, "198.51.100.7"},
+		{"forwarded ipv6", "[2001:db8:ffff::1]:443", map[string]string{"Forwarded": `for="[2001:db8::cafe]:4711"`}, "2001:db8::cafe"},
+		{"forwarded wins over xff", "10.0.0.1:99", map[string]string{"Forwarded": "for=198.51.100.7", "X-Forwarded-For": "192.0.2.1"}, "198.51.100.7"},
+		{"forwarded obfuscated", "10.0.0.1:99", map[string]string{"Forwarded": "for=_hidden"}, "10.0.0.1"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remote
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			if got := proxies.clientIP(req); got != tc.want {
+				t.Fatalf("clientIP = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestIsTLSRequiresTrustedProxy(t *testing.T) {
+	proxies := testProxies(t, "10.0.0.0/8")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	req.RemoteAddr = "203.0.113.5:1234"
+	if proxies.isTLS(req) {
+		t.Fatalf("X-Forwarded-Proto from untrusted peer must be ignored")
+	}
+	req.RemoteAddr = "10.0.0.1:1234"
+	if !proxies.isTLS(req) {
+		t.Fatalf("X-Forwarded-Proto from trusted proxy should be honoured")
+	}
+
+	req.Header.Del("X-Forwarded-Proto")
+	req.Header.Set("Forwarded", "for=198.51.100.7;proto=https")
+	if !proxies.isTLS(req) {
+		t.Fatalf("Forwarded proto from trusted proxy should be honoured")
+	}
+}
+
+// TestLoginLockoutIgnoresSpoofedHeaders 验证不可信来源无法靠轮换 X-Forwarded-For 绕过登录锁定，
+// 且访问日志记录的 IP 与限流使用的一致。
+func TestLoginLockoutIgnoresSpoofedHeaders(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", TrustedProxies: testProxies(t, "10.0.0.0/8")}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	buf := captureLogs(t)
+
+	login := func(remote, xff string) int {
+		form := url.Values{"password": {"wrong"}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remote
+		req.Header.Set("X-Forwarded-For", xff)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 5; i++ {
+		login("203.0.113.5:1234", "198.51.100."+string(rune('1'+i)))
+	}
+	if code := login("203.0.113.5:1234", "198.51.100.99"); code != http.StatusTooManyRequests {
+		t.Fatalf("status = %d, want 429 despite rotating X-Forwarded-For", code)
+	}
+	// 经可信代理转发的另一客户端不受影响
+	if code := login("10.0.0.1:1234", "198.51.100.99"); code == http.StatusTooManyRequests {
+		t.Fatalf("client behind trusted proxy must not share the lockout")
+	}
+
+	records := logRecords(t, buf)
+	if got := records[0]["ip"]; got != "203.0.113.5" {
+		t.Errorf("access log ip = %v, want 203.0.113.5", got)
+	}
+	if got := records[len(records)-1]["ip"]; got != "198.51.100.99" {
+		t.Errorf("access log ip = %v, want 198.51.100.99", got)
+	}
+}
+
+// TestSecureCookieBehindTLSProxy 验证可信代理终止 TLS 时会话 cookie 带 Secure，
+// 直连 HTTP 或不可信来源声明的 HTTPS 则不带。
+func TestSecureCookieBehindTLSProxy(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", TrustedProxies: testProxies(t, "10.0.0.0/8")}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	login := func(remote, proto string) *http.Cookie {
+		form := url.Values{"password": {"testpass"}}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RemoteAddr = remote
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		for _, c := range w.Result().Cookies() {
+			if c.Name == sessionCookieName {
+				return c
+			}
+		}
+		t.Fatalf("login from %s: no session cookie, status %d", remote, w.Code)
+		return nil
+	}
+
+	if c := login("10.0.0.1:1234", "https"); !c.Secure {
+		t.Errorf("cookie behind a trusted TLS proxy must be Secure")
+	}
+	if c := login("10.0.0.1:1234", ""); c.Secure {
+		t.Errorf("cookie over plain HTTP must not be Secure")
+	}
+	if c := login("203.0.113.5:1234", "https"); c.Secure {
+		t.Errorf("untrusted X-Forwarded-Proto must not mark the cookie Secure")
+	}
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+// newTestSession 创建一个已登录会话，返回会话 cookie 与绑定的 CSRF token。
+func newTestSession(t *testing.T, srv *Server) (*http.Cookie, string) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	srv.setSession(rec, httptest.NewRequest(http.MethodPost, "/login", nil), false)
+	cookies := rec.Result().Cookies()
+	if len(cookies) == 0 {
+		t.Fatalf("expected session cookie")
+	}
+	return cookies[0], srv.sessions.CSRFToken(cookies[0].Value)
+}
+
+func newCSRFTestServer(t *testing.T) (*Server, content.Entry) {
+	t.Helper()
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererMarkdown, "# Hi", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+	return srv, entry
+}
+
+func postForm(srv *Server, path string, form url.Values, cookie *http.Cookie, origin string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+// TestCSRFProtectsAdminForms 对每个改变状态的后台表单验证：缺少 token、token 错误、
+// 跨站 Origin 均返回 403，携带正确 token 的同源请求正常处理。
+func TestCSRFProtectsAdminForms(t *testing.T) {
+	forms := []struct {
+		name       string
+		path       func(slug string) string
+		form       url.Values
+		wantStatus int
+	}{
+		{"create", func(string) string { return "/admin" }, url.Values{"renderer": {"markdown"}, "content": {"# New"}}, http.StatusOK},
+		{"preview", func(string) string { return "/admin/preview" }, url.Values{"renderer": {"markdown"}, "content": {"# P"}}, http.StatusOK},
+		{"edit", func(slug string) string { return "/" + slug + "/edit" }, url.Values{"renderer": {"markdown"}, "content": {"# Edited"}}, http.StatusOK},
+		{"delete", func(slug string) string { return "/" + slug + "/delete" }, url.Values{}, http.StatusFound},
+		{"publish", func(slug string) string { return "/" + slug + "/publish" }, url.Values{}, http.StatusFound},
+		{"autosave", func(slug string) string { return "/admin/autosave" }, url.Values{"slug": {""}, "renderer": {"markdown"}, "content": {"# Draft"}}, http.StatusOK},
+		{"discard autosave", func(string) string { return "/admin/autosave/discard" }, url.Values{}, http.StatusFound},
+		{"logout", func(string) string { return "/logout" }, url.Values{}, http.StatusFound},
+		{"unlock ip", func(string) string { return "/admin/lockouts/unlock" }, url.Values{"ip": {"192.0.2.1"}}, http.StatusSeeOther},
+		{"enable totp", func(string) string { return "/admin/security/totp/enable" }, url.Values{"code": {"000000"}}, http.StatusSeeOther},
+		{"disable totp", func(string) string { return "/admin/security/totp/disable" }, url.Values{"code": {"000000"}}, http.StatusSeeOther},
+		{"log out everywhere", func(string) string { return "/admin/sessions/revoke-all" }, url.Values{}, http.StatusSeeOther},
+		{"bulk", func(slug string) string { return "/admin/library/bulk" }, url.Values{"action": {"delete"}, "slug": {"missing1"}}, http.StatusOK},
+		{"restore", func(slug string) string { return "/admin/trash/" + slug + "/restore" }, url.Values{}, http.StatusSeeOther},
+		{"delete forever", func(slug string) string { return "/admin/trash/" + slug + "/delete" }, url.Values{}, http.StatusSeeOther},
+		{"change password", func(string) string { return "/admin/security/password" }, url.Values{"current_password": {"wrong"}}, http.StatusUnprocessableEntity},
+	}
+
+	for _, f := range forms {
+		t.Run(f.name, func(t *testing.T) {
+			srv, entry := newCSRFTestServer(t)
+			cookie, token := newTestSession(t, srv)
+			path := f.path(entry.Slug)
+
+			// 缺少 token
+			w := postForm(srv, path, f.form, cookie, "")
+			if w.Code != http.StatusForbidden {
+				t.Fatalf("missing token: status = %d, want 403", w.Code)
+			}
+			if !strings.Contains(w.Body.String(), "Request Blocked") {
+				t.Errorf("expected explanatory 403 page, got: %s", w.Body.String())
+			}
+
+			// token 错误
+			bad := cloneValues(f.form)
+			bad.Set(csrfFieldName, "not-the-token")
+			if w := postForm(srv, path, bad, cookie, ""); w.Code != http.StatusForbidden {
+				t.Fatalf("wrong token: status = %d, want 403", w.Code)
+			}
+
+			// 跨站 Origin，即便 token 正确也拒绝
+			good := cloneValues(f.form)
+			good.Set(csrfFieldName, token)
+			if w := postForm(srv, path, good, cookie, "https://evil.example"); w.Code != http.StatusForbidden {
+				t.Fatalf("cross-site origin: status = %d, want 403", w.Code)
+			}
+
+			// 同源 + 正确 token
+			if w := postForm(srv, path, good, cookie, "http://example.com"); w.Code != f.wantStatus {
+				t.Fatalf("valid request: status = %d, want %d, body: %s", w.Code, f.wantStatus, w.Body.String())
+			}
+		})
+	}
+}
+
+// TestCSRFRefererFallback 验证缺少 Origin 时按 Referer 判定来源。
+func TestCSRFRefererFallback(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, token := newTestSession(t, srv)
+	form := url.Values{"renderer": {"markdown"}, "content": {"x"}, csrfFieldName: {token}}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Referer", "https://evil.example/page")
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("cross-site referer: status = %d, want 403", w.Code)
+	}
+}
+
+// TestCSRFTokenEmbeddedInForms 验证编辑器与内容库页面的每个表单都嵌入了会话 token。
+func TestCSRFTokenEmbeddedInForms(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	cookie, token := newTestSession(t, srv)
+	field := `name="csrf_token" value="` + token + `"`
+
+	for _, c := range []struct {
+		path  string
+		forms int // 页面中 <form method="post"> 的数量
+	}{
+		{"/admin", 2},                   // 编辑器 + 登出
+		{"/" + entry.Slug + "/edit", 2}, // 编辑器 + 登出
+		{"/admin/library", 3},           // 登出 + 批量操作 + 一条删除
+	} {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		body := w.Body.String()
+		if got := strings.Count(body, field); got != c.forms {
+			t.Errorf("%s: found %d embedded tokens, want %d", c.path, got, c.forms)
+		}
+	}
+}
+
+func cloneValues(v url.Values) url.Values {
+	out := url.Values{}
+	for k, vs := range v {
+		out[k] = append([]string(nil), vs...)
+	}
+	return out
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/content"
+)
+
+// autosaveRoute 为编辑器自动保存接口，请求体与保存条目相同，按条目上限约束。
+const autosaveRoute = "POST /admin/autosave"
+
+// autosaveNotice 提示编辑页存在尚未保存的自动保存。
+type autosaveNotice struct {
+	SavedAt string
+	// Restored 为 true 时编辑器中已是自动保存的内容。
+	Restored bool
+}
+
+// autosaveResult 是自动保存接口的 JSON 响应。Status 为 saved 或 clean，
+// clean 表示内容与已保存的版本一致，原有的自动保存已被丢弃。
+type autosaveResult struct {
+	Status  string `json:"status"`
+	SavedAt string `json:"saved_at,omitempty"`
+}
+
+// autosaveEntry 保存编辑器中尚未提交的内容，与已保存的条目分开存放，保存条目时丢弃。
+// 表单字段与编辑器表单一致，slug 为空表示新建条目。
+func (s *Server) autosaveEntry(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		if isTooLarge(err) {
+			jsonError(w, http.StatusRequestEntityTooLarge, s.tooLargeMessage(r))
+			return
+		}
+		jsonError(w, http.StatusBadRequest, "Invalid form data.")
+		return
+	}
+
+	key := r.FormValue("slug")
+	if key == "" {
+		key = content.NewEntryAutosave
+	}
+	renderer := content.RendererType(r.FormValue("renderer"))
+	if renderer != content.RendererMarkdown && renderer != content.RendererHTML {
+		jsonError(w, http.StatusBadRequest, "Invalid renderer type.")
+		return
+	}
+	raw := r.FormValue("content")
+	description := r.FormValue("description")
+
+	// 内容回到已保存的状态时不再需要自动保存。
+	clean := raw == "" && description == ""
+	if key != content.NewEntryAutosave {
+		entry, err := s.store.Get(key)
+		if err != nil {
+			jsonError(w, http.StatusNotFound, "Entry not found.")
+			return
+		}
+		clean = raw == entry.Raw && renderer == entry.Renderer && description == entry.Description
+	}
+	if clean {
+		if err := s.store.DiscardAutosave(key); err != nil {
+			slog.ErrorContext(r.Context(), "discard autosave", "key", key, "error", err)
+			jsonError(w, http.StatusInternalServerError, "Autosave failed.")
+			return
+		}
+		writeJSON(w, http.StatusOK, autosaveResult{Status: "clean"})
+		return
+	}
+
+	growth := int64(len(raw) + len(description))
+	if prev, err := s.store.Autosave(key); err == nil {
+		growth -= int64(len(prev.Raw) + len(prev.Description))
+	}
+	if !s.checkEntrySize(w, r, raw, growth) {
+		return
+	}
+
+	a, err := s.store.SaveAutosave(content.Autosave{Key: key, Renderer: renderer, Raw: raw, Description: description})
+	if err != nil {
+		if errors.Is(err, content.ErrEntryNotFound) {
+			jsonError(w, http.StatusNotFound, "Entry not found.")
+			return
+		}
+		slog.ErrorContext(r.Context(), "save autosave", "key", key, "error", err)
+		jsonError(w, http.StatusInternalServerError, "Autosave failed.")
+		return
+	}
+	writeJSON(w, http.StatusOK, autosaveResult{Status: "saved", SavedAt: formatTime(a.SavedAt)})
+}
+
+// discardAutosave 丢弃编辑页的自动保存并回到该编辑页。
+func (s *Server) discardAutosave(w http.ResponseWriter, r *http.Request) {
+	slug := r.FormValue("slug")
+	key, target := content.NewEntryAutosave, "/admin"
+	if slug != "" {
+		key, target = slug, "/"+url.PathEscape(slug)+"/edit"
+	}
+	if err := s.store.DiscardAutosave(key); err != nil {
+		slog.ErrorContext(r.Context(), "discard autosave", "key", key, "error", err)
+		s.renderError(w, http.StatusInternalServerError, "Discard Failed")
+		return
+	}
+	http.Redirect(w, r, target, http.StatusFound)
+}
+
+// applyAutosave 在编辑页提示尚未保存的自动保存；?autosave=restore 时把它载入编辑器。
+func (s *Server) applyAutosave(r *http.Request, data *adminTemplateData, key string) {
+	a, err := s.store.Autosave(key)
+	if err != nil {
+		if !errors.Is(err, content.ErrAutosaveNotFound) {
+			slog.WarnContext(r.Context(), "read autosave", "key", key, "error", err)
+		}
+		return
+	}
+	notice := &autosaveNotice{SavedAt: formatTime(a.SavedAt)}
+	if r.URL.Query().Get("autosave") == "restore" {
+		data.Renderer = a.Renderer
+		data.Content = a.Raw
+		data.Description = a.Description
+		notice.Restored = true
+	}
+	data.Autosave = notice
+}
+
+// publishEntry 发布草稿并回到内容库；已发布的条目不做改动。
+func (s *Server) publishEntry(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	entry, err := s.store.Get(slug)
+	if err != nil {
+		if errors.Is(err, content.ErrEntryNotFound) {
+			s.renderError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		slog.ErrorContext(r.Context(), "load entry", "slug", slug, "error", err)
+		s.renderError(w, http.StatusInternalServerError, "Publish Failed")
+		return
+	}
+	if entry.Draft {
+		if _, ok := s.publishDraft(w, r, slug); !ok {
+			return
+		}
+	}
+	http.Redirect(w, r, "/admin/library?published="+url.QueryEscape(slug), http.StatusFound)
+}
+
+// publishDraft 发布草稿并写入审计日志。返回 false 表示已写入错误响应。
+func (s *Server) publishDraft(w http.ResponseWriter, r *http.Request, slug string) (content.Entry, bool) {
+	entry, err := s.store.Publish(slug)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "publish entry", "slug", slug, "error", err)
+		s.renderError(w, http.StatusInternalServerError, "Publish Failed")
+		return content.Entry{}, false
+	}
+	s.audit(r, audit.Event{Action: audit.EntryPublish, Slug: slug, Hash: audit.ContentHash(entry.Raw)})
+	return entry, true
+}
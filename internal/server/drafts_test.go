@@ -0,0 +1,214 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/content"
+)
+
+func TestDraftHiddenUntilPublished(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	form := url.Values{csrfFieldName: {csrf}, "renderer": {"markdown"}, "content": {"# Work in progress"}, "draft": {"1"}}
+	w := postForm(srv, "/admin", form, cookie, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Draft Saved") {
+		t.Fatalf("create draft: status %d", w.Code)
+	}
+	var draft content.Entry
+	entries, _ := srv.store.List()
+	for _, e := range entries {
+		if e.Draft {
+			draft = e
+		}
+	}
+	if draft.Slug == "" {
+		t.Fatalf("draft entry not created")
+	}
+
+	// 草稿对访客与管理员都不公开，源码与下载同样不可见。
+	for _, path := range []string{"/" + draft.Slug, "/" + draft.Slug + "/raw", "/" + draft.Slug + "/download"} {
+		if w := getWithCookie(srv, path, cookie); w.Code != http.StatusNotFound {
+			t.Fatalf("GET %s: status %d, want 404", path, w.Code)
+		}
+	}
+	if w := getWithCookie(srv, "/"+draft.Slug+"/edit", cookie); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `name="publish"`) {
+		t.Fatalf("draft editor should offer publishing")
+	}
+
+	// 保存草稿不改变其状态。
+	form = url.Values{csrfFieldName: {csrf}, "renderer": {"markdown"}, "content": {"# Almost done"}}
+	if w := postForm(srv, "/"+draft.Slug+"/edit", form, cookie, ""); w.Code != http.StatusOK {
+		t.Fatalf("save draft: status %d", w.Code)
+	}
+	if w := getWithCookie(srv, "/"+draft.Slug, cookie); w.Code != http.StatusNotFound {
+		t.Fatalf("saved draft became public: status %d", w.Code)
+	}
+
+	form.Set("publish", "1")
+	w = postForm(srv, "/"+draft.Slug+"/edit", form, cookie, "")
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Entry Published") {
+		t.Fatalf("publish from editor: status %d", w.Code)
+	}
+	if w := getWithCookie(srv, "/"+draft.Slug, cookie); w.Code != http.StatusOK {
+		t.Fatalf("published entry: status %d", w.Code)
+	}
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.EntryPublish, Slug: draft.Slug}); len(got) != 1 {
+		t.Fatalf("got %d publish events, want 1", len(got))
+	}
+}
+
+func TestPublishFromLibrary(t *testing.T) {
+	srv, published := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+	draft, err := srv.store.CreateDraft(content.RendererMarkdown, "draft body", "")
+	if err != nil {
+		t.Fatalf("create draft: %v", err)
+	}
+
+	body := getWithCookie(srv, "/admin/library?status=draft", cookie).Body.String()
+	if !strings.Contains(body, draft.Slug) || strings.Contains(body, published.Slug) {
+		t.Fatalf("drafts filter should list only the draft")
+	}
+	if !strings.Contains(body, "Drafts (1)") || !strings.Contains(body, "/"+draft.Slug+"/publish") {
+		t.Fatalf("library should show the draft count and a publish action")
+	}
+	body = getWithCookie(srv, "/admin/library?status=published", cookie).Body.String()
+	if strings.Contains(body, draft.Slug) || !strings.Contains(body, published.Slug) {
+		t.Fatalf("published filter should exclude the draft")
+	}
+
+	w := postForm(srv, "/"+draft.Slug+"/publish", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/library?published="+draft.Slug {
+		t.Fatalf("publish: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if e, _ := srv.store.Get(draft.Slug); e.Draft {
+		t.Fatalf("entry still a draft after publishing")
+	}
+	// 重复发布不会再次记录审计。
+	postForm(srv, "/"+draft.Slug+"/publish", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.EntryPublish}); len(got) != 1 {
+		t.Fatalf("got %d publish events, want 1", len(got))
+	}
+	if w := postForm(srv, "/missing1/publish", url.Values{csrfFieldName: {csrf}}, cookie, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("publish missing entry: status %d, want 404", w.Code)
+	}
+}
+
+func postAutosave(srv *Server, form url.Values, cookie *http.Cookie, csrf string) (int, autosaveResult) {
+	form.Set(csrfFieldName, csrf)
+	w := postForm(srv, "/admin/autosave", form, cookie, "")
+	var res autosaveResult
+	_ = json.Unmarshal(w.Body.Bytes(), &res)
+	return w.Code, res
+}
+
+func TestAutosaveRestoreAndDiscard(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	code, res := postAutosave(srv, url.Values{"slug": {entry.Slug}, "renderer": {"markdown"}, "content": {"# Hi, unsaved"}}, cookie, csrf)
+	if code != http.StatusOK || res.Status != "saved" || res.SavedAt == "" {
+		t.Fatalf("autosave: status %d, result %+v", code, res)
+	}
+	// 自动保存不影响已发布版本。
+	if e, _ := srv.store.Get(entry.Slug); e.Raw != "# Hi" {
+		t.Fatalf("autosave changed the saved entry: %q", e.Raw)
+	}
+
+	editURL := "/" + entry.Slug + "/edit"
+	body := getWithCookie(srv, editURL, cookie).Body.String()
+	if !strings.Contains(body, "Unsaved changes from") || strings.Contains(body, "# Hi, unsaved") {
+		t.Fatalf("editor should offer the autosave without loading it")
+	}
+	body = getWithCookie(srv, editURL+"?autosave=restore", cookie).Body.String()
+	if !strings.Contains(body, "# Hi, unsaved") || !strings.Contains(body, "data-dirty") {
+		t.Fatalf("restore should load the autosaved content")
+	}
+
+	// 内容改回已保存版本时丢弃自动保存。
+	code, res = postAutosave(srv, url.Values{"slug": {entry.Slug}, "renderer": {"markdown"}, "content": {"# Hi"}}, cookie, csrf)
+	if code != http.StatusOK || res.Status != "clean" {
+		t.Fatalf("clean autosave: status %d, result %+v", code, res)
+	}
+	if _, err := srv.store.Autosave(entry.Slug); err == nil {
+		t.Fatalf("clean content should discard the autosave")
+	}
+
+	// 新建条目的自动保存可被手动丢弃。
+	postAutosave(srv, url.Values{"renderer": {"html"}, "content": {"<p>new</p>"}}, cookie, csrf)
+	if !strings.Contains(getWithCookie(srv, "/admin", cookie).Body.String(), "Unsaved changes from") {
+		t.Fatalf("new-entry editor should offer the autosave")
+	}
+	w := postForm(srv, "/admin/autosave/discard", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin" {
+		t.Fatalf("discard: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if _, err := srv.store.Autosave(content.NewEntryAutosave); err == nil {
+		t.Fatalf("autosave should be discarded")
+	}
+
+	if code, _ := postAutosave(srv, url.Values{"slug": {"missing1"}, "renderer": {"markdown"}, "content": {"x"}}, cookie, csrf); code != http.StatusNotFound {
+		t.Fatalf("autosave for missing entry: status %d, want 404", code)
+	}
+}
+
+func TestSavingDiscardsAutosave(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	postAutosave(srv, url.Values{"slug": {entry.Slug}, "renderer": {"markdown"}, "content": {"# Edited"}}, cookie, csrf)
+	postAutosave(srv, url.Values{"renderer": {"markdown"}, "content": {"# New"}}, cookie, csrf)
+
+	form := url.Values{csrfFieldName: {csrf}, "renderer": {"markdown"}, "content": {"# Edited"}}
+	if w := postForm(srv, "/"+entry.Slug+"/edit", form, cookie, ""); w.Code != http.StatusOK {
+		t.Fatalf("update: status %d", w.Code)
+	}
+	if _, err := srv.store.Autosave(entry.Slug); err == nil {
+		t.Fatalf("saving the entry should discard its autosave")
+	}
+	form.Set("content", "# New")
+	if w := postForm(srv, "/admin", form, cookie, ""); w.Code != http.StatusOK {
+		t.Fatalf("create: status %d", w.Code)
+	}
+	if _, err := srv.store.Autosave(content.NewEntryAutosave); err == nil {
+		t.Fatalf("creating an entry should discard the new-entry autosave")
+	}
+}
+
+// TestDraftsAndAutosavesNotPublic 验证草稿与自动保存在任何公开路由上都返回 404，
+// 包括借 %2F 指向 .autosave 目录的路径。
+func TestDraftsAndAutosavesNotPublic(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	srv.cfg.SandboxHTML = true
+	draft, err := srv.store.CreateDraft(content.RendererHTML, "<p>draft body</p>", "")
+	if err != nil {
+		t.Fatalf("create draft: %v", err)
+	}
+	for key, raw := range map[string]string{
+		content.NewEntryAutosave: "<p>unsaved new entry</p>",
+		draft.Slug:               "<p>unsaved draft edit</p>",
+		entry.Slug:               "<p>unsaved entry edit</p>",
+	} {
+		if _, err := srv.store.SaveAutosave(content.Autosave{Key: key, Renderer: content.RendererHTML, Raw: raw}); err != nil {
+			t.Fatalf("save autosave %s: %v", key, err)
+		}
+	}
+
+	for _, slug := range []string{draft.Slug, ".autosave%2F" + content.NewEntryAutosave, ".autosave%2F" + draft.Slug, ".autosave%2F" + entry.Slug} {
+		for _, suffix := range []string{"", "/raw", "/download", "/frame"} {
+			path := "/" + slug + suffix
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "unsaved") || strings.Contains(w.Body.String(), "draft body") {
+				t.Errorf("GET %s: status %d, want 404 without content", path, w.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"html"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"minisnap/internal/attach"
+	"minisnap/internal/audit"
+	"minisnap/internal/imageproc"
+)
+
+const (
+	// defaultUploadMaxSize 为未配置 UploadMaxSize 时单个附件的字节上限。
+	defaultUploadMaxSize = 10 << 20
+	// multipartOverhead 为 multipart 边界与表头预留的额外请求体字节数。
+	multipartOverhead = 64 << 10
+	// uploadMemory 为解析上传表单时保留在内存中的字节数，超出部分写入临时文件。
+	uploadMemory = 1 << 20
+
+	// orphanGracePeriod 内上传的附件即使尚未被任何条目引用也不会被清理，
+	// 给编辑中尚未保存的条目留出时间。
+	orphanGracePeriod = 24 * time.Hour
+	// fileCleanupInterval 为后台清理孤儿附件的周期。
+	fileCleanupInterval = time.Hour
+)
+
+// attachmentItem 是编辑器中展示的已引用附件。
+type attachmentItem struct {
+	Name  string
+	URL   string
+	MIME  string
+	Size  string
+	Image bool
+	// Thumb 为图片的缩略图地址，Dimensions 为原图尺寸说明。
+	Thumb      string
+	Dimensions string
+}
+
+// uploadResult 是上传接口的 JSON 响应，Markdown / HTML 为可直接插入编辑器的引用片段。
+type uploadResult struct {
+	Hash     string `json:"hash"`
+	URL      string `json:"url"`
+	Name     string `json:"name"`
+	MIME     string `json:"mime"`
+	Size     int64  `json:"size"`
+	Image    bool   `json:"image"`
+	Markdown string `json:"markdown"`
+	HTML     string `json:"html"`
+}
+
+// uploadMaxSize 返回单个附件的字节上限。
+func (s *Server) uploadMaxSize() int64 {
+	if s.cfg.UploadMaxSize > 0 {
+		return s.cfg.UploadMaxSize
+	}
+	return defaultUploadMaxSize
+}
+
+// uploadFile 接收编辑器上传的单个附件（multipart 字段 file），按内容哈希保存后
+// 返回访问地址与可插入的引用片段。
+func (s *Server) uploadFile(w http.ResponseWriter, r *http.Request) {
+	limit := s.uploadMaxSize()
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
+		if isTooLarge(err) {
+			jsonError(w, http.StatusRequestEntityTooLarge, s.tooLargeMessage(r))
+			return
+		}
+		jsonError(w, http.StatusBadRequest, "Expected a multipart/form-data upload.")
+		return
+	}
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Missing file field.")
+		return
+	}
+	defer file.Close()
+	if err := s.checkQuota(header.Size); err != nil {
+		if errors.Is(err, errQuotaExceeded) {
+			jsonError(w, http.StatusRequestEntityTooLarge, s.quotaMessage())
+			return
+		}
+		slog.ErrorContext(r.Context(), "measure storage", "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to check storage quota.")
+		return
+	}
+
+	f, err := s.storeUpload(header.Filename, file, limit)
+	s.usage.invalidate()
+	switch {
+	case errors.Is(err, attach.ErrTooLarge):
+		jsonError(w, http.StatusRequestEntityTooLarge, "File exceeds the "+formatSize(limit)+" upload limit.")
+		return
+	case errors.Is(err, attach.ErrEmpty):
+		jsonError(w, http.StatusBadRequest, "File is empty.")
+		return
+	case errors.Is(err, errInvalidImage):
+		jsonError(w, http.StatusUnprocessableEntity, "The image could not be decoded.")
+		return
+	case errors.Is(err, imageproc.ErrTooManyPixels):
+		jsonError(w, http.StatusUnprocessableEntity, "The image dimensions are too large.")
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "save upload", "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to save the file.")
+		return
+	}
+	s.audit(r, audit.Event{Action: audit.FileUpload, Hash: "sha256:" + f.Hash, Detail: f.Name + " (" + f.MIME + ")"})
+	writeJSON(w, http.StatusCreated, newUploadResult(f))
+}
+
+func newUploadResult(f attach.File) uploadResult {
+	res := uploadResult{
+		Hash:  f.Hash,
+		URL:   f.URL(),
+		Name:  f.Name,
+		MIME:  f.MIME,
+		Size:  f.Size,
+		Image: f.IsImage(),
+	}
+	if res.Image {
+		res.Markdown = imageMarkdown(f)
+		res.HTML = imageHTML(f)
+	} else {
+		res.Markdown = "[" + markdownLabel(f.Name) + "](" + res.URL + ")"
+		res.HTML = `<a href="` + res.URL + `">` + html.EscapeString(f.Name) + `</a>`
+	}
+	return res
+}
+
+// markdownLabel 转义会破坏链接文本的字符。
+func markdownLabel(name string) string {
+	return strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, "\n", " ").Replace(name)
+}
+
+// serveFile 提供已上传的附件。内容不可变，按哈希长期缓存；只有常见位图内联显示，
+// 其余类型一律作为下载，避免上传的 HTML/SVG 在本站源下执行。
+func (s *Server) serveFile(w http.ResponseWriter, r *http.Request) {
+	blob, f, err := s.files.Open(r.PathValue("hash"))
+	if errors.Is(err, attach.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "open file", "hash", r.PathValue("hash"), "error", err)
+		s.renderError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	defer blob.Close()
+
+	disposition := "attachment"
+	if f.IsImage() {
+		disposition = "inline"
+	}
+	h := w.Header()
+	h.Set("Content-Type", f.MIME)
+	h.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": f.Name}))
+	h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	h.Set("ETag", `"`+f.Hash+`"`)
+	http.ServeContent(w, r, "", f.UploadedAt, blob)
+}
+
+// entryAttachments 返回条目内容引用且仍存在的附件，供编辑器展示。
+func (s *Server) entryAttachments(raw string) []attachmentItem {
+	var items []attachmentItem
+	for _, hash := range attach.References(raw) {
+		f, err := s.files.Stat(hash)
+		if err != nil {
+			continue
+		}
+		item := attachmentItem{
+			Name:  f.Name,
+			URL:   f.URL(),
+			MIME:  f.MIME,
+			Size:  formatSize(f.Size),
+			Image: f.IsImage(),
+		}
+		if item.Image {
+			item.Thumb = thumbnailURL(f)
+			if f.Width > 0 {
+				item.Dimensions = strconv.Itoa(f.Width) + "×" + strconv.Itoa(f.Height)
+			}
+		}
+		items = append(items, item)
+	}
+	return items
+}
+
+// referencedFiles 汇总条目、回收站条目与自动保存引用的附件；回收站中的条目仍可恢复，
+// 自动保存中刚上传的附件尚未随条目保存，都需保留。
+func (s *Server) referencedFiles() (map[string]bool, error) {
+	entries, err := s.store.List()
+	if err != nil {
+		return nil, err
+	}
+	trashed, err := s.store.Trash()
+	if err != nil {
+		return nil, err
+	}
+	autosaves, err := s.store.Autosaves()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]bool)
+	for _, a := range autosaves {
+		for _, hash := range attach.References(a.Raw) {
+			refs[hash] = true
+		}
+	}
+	for _, e := range entries {
+		for _, hash := range attach.References(e.Raw) {
+			refs[hash] = true
+		}
+	}
+	for _, e := range trashed {
+		for _, hash := range attach.References(e.Raw) {
+			refs[hash] = true
+		}
+	}
+	return refs, nil
+}
+
+// cleanupOrphanFiles 删除不再被任何条目引用、且已过宽限期的附件，并逐个写入审计日志。
+func (s *Server) cleanupOrphanFiles(ctx context.Context) {
+	refs, err := s.referencedFiles()
+	if err != nil {
+		slog.ErrorContext(ctx, "collect file references", "error", err)
+		return
+	}
+	removed, err := s.files.RemoveUnreferenced(refs, time.Now().Add(-orphanGracePeriod))
+	if err != nil {
+		slog.ErrorContext(ctx, "remove orphaned files", "error", err)
+	}
+	for _, f := range removed {
+		e := audit.Event{Action: audit.FilePurge, Actor: systemActor, Hash: "sha256:" + f.Hash, Detail: f.Name}
+		if err := s.auditLog.Append(e); err != nil {
+			slog.ErrorContext(ctx, "write audit log", "action", e.Action, "error", err)
+		}
+	}
+	if len(removed) > 0 {
+		s.usage.invalidate()
+		slog.InfoContext(ctx, "removed orphaned files", "count", len(removed))
+	}
+}
+
+// RunFileCleanup 立即并按 fileCleanupInterval 周期清理孤儿附件，直到 ctx 结束。
+func (s *Server) RunFileCleanup(ctx context.Context) {
+	ticker := time.NewTicker(fileCleanupInterval)
+	defer ticker.Stop()
+	for {
+		s.cleanupOrphanFiles(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// formatSize 以 B / KB / MB / GB（1024 进制）展示字节数。
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	value := strconv.FormatFloat(float64(n)/float64(div), 'f', 1, 64)
+	return strings.TrimSuffix(value, ".0") + " " + string("KMG"[exp]) + "B"
+}
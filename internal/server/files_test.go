@@ -0,0 +1,236 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"minisnap/internal/attach"
+	"minisnap/internal/audit"
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+// testPNG 是一张 2×2 的 PNG，尺寸小于缩略图，不会生成变体。
+var testPNG = encodeTestImage(2, 2, "png")
+
+// uploadRequest 构造携带单个文件的 multipart 上传请求。
+func uploadRequest(t *testing.T, name string, data []byte, cookie *http.Cookie, csrf string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("create form file: %v", err)
+	}
+	part.Write(data)
+	mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/files", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set(csrfHeaderName, csrf)
+	req.AddCookie(cookie)
+	return req
+}
+
+func TestUploadAndServeImage(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "shot.png", testPNG, cookie, csrf))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d, body %s", w.Code, w.Body.String())
+	}
+	var res uploadResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !res.Image || res.MIME != "image/png" || res.Markdown != "![shot.png]("+res.URL+")" {
+		t.Fatalf("unexpected result: %+v", res)
+	}
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.FileUpload}); len(got) != 1 || got[0].Actor != adminUser {
+		t.Fatalf("expected upload audit event, got %+v", got)
+	}
+
+	// 附件无需登录即可访问，图片内联显示并长期缓存。
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, res.URL, nil))
+	h := w.Header()
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), testPNG) {
+		t.Fatalf("serve: status %d", w.Code)
+	}
+	if h.Get("Content-Type") != "image/png" || !strings.HasPrefix(h.Get("Content-Disposition"), "inline") ||
+		!strings.Contains(h.Get("Cache-Control"), "immutable") || h.Get("Content-Security-Policy") != sourceCSP {
+		t.Fatalf("unexpected headers: %v", h)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, res.URL, nil)
+	req.Header.Set("If-None-Match", h.Get("ETag"))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("conditional request: status %d, want 304", w.Code)
+	}
+}
+
+// TestUploadedHTMLIsDownloaded 验证非图片附件（即使内容是 HTML）一律以附件形式下载。
+func TestUploadedHTMLIsDownloaded(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "evil.png", []byte("<html><script>alert(1)</script></html>"), cookie, csrf))
+	var res uploadResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil || res.Image {
+		t.Fatalf("upload: %v %+v", err, res)
+	}
+	if res.Markdown != "[evil.png]("+res.URL+")" {
+		t.Fatalf("markdown = %q", res.Markdown)
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, res.URL, nil))
+	if cd := w.Header().Get("Content-Disposition"); !strings.HasPrefix(cd, "attachment") {
+		t.Fatalf("Content-Disposition = %q, want attachment", cd)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Fatalf("expected nosniff")
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass", UploadMaxSize: 16}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	cookie, csrf := newTestSession(t, srv)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "big.bin", bytes.Repeat([]byte("x"), 17), cookie, csrf))
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "upload limit") {
+		t.Fatalf("oversize: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	// 远超上限的请求体在 multipart 解析阶段即被截断。
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "huge.bin", bytes.Repeat([]byte("x"), 2*multipartOverhead), cookie, csrf))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("huge body: status %d, want 413", w.Code)
+	}
+
+	// 未携带 CSRF token 的上传被拒绝。
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "a.txt", []byte("hi"), cookie, ""))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("missing token: status %d, want 403", w.Code)
+	}
+}
+
+func TestServeFileNotFound(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	for _, path := range []string{"/-/files/" + strings.Repeat("0", 64), "/-/files/not-a-hash"} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, w.Code)
+		}
+	}
+}
+
+// TestEditorListsAttachments 验证编辑页展示条目引用的附件与上传入口。
+func TestEditorListsAttachments(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, _ := newTestSession(t, srv)
+	f, err := srv.files.Save("diagram.png", bytes.NewReader(testPNG), 0)
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	entry, err := srv.store.Create(content.RendererMarkdown, "![d]("+f.URL()+")", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	body := getWithCookie(srv, "/"+entry.Slug+"/edit", cookie).Body.String()
+	for _, want := range []string{"diagram.png", f.URL(), "data-upload", "upload.", "up to 10 MB each"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("editor missing %q", want)
+		}
+	}
+}
+
+// TestOrphanCleanup 验证只有不被条目、回收站条目或自动保存引用、且超过宽限期的附件会被清理。
+func TestOrphanCleanup(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	save := func(name string) attach.File {
+		f, err := srv.files.Save(name, strings.NewReader(name), 0)
+		if err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		return f
+	}
+	live, trashed, orphan, fresh := save("live.txt"), save("trashed.txt"), save("orphan.txt"), save("fresh.txt")
+	unsaved := save("unsaved.txt")
+
+	if _, err := srv.store.Create(content.RendererMarkdown, "[a]("+live.URL()+")", ""); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	gone, err := srv.store.Create(content.RendererMarkdown, "[b]("+trashed.URL()+")", "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if err := srv.store.Delete(gone.Slug); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := srv.store.SaveAutosave(content.Autosave{Key: content.NewEntryAutosave, Renderer: content.RendererMarkdown, Raw: "[c](" + unsaved.URL() + ")"}); err != nil {
+		t.Fatalf("autosave: %v", err)
+	}
+
+	// 把除 fresh 外的附件改到宽限期之前。
+	backdate(t, srv, time.Now().Add(-2*orphanGracePeriod), live, trashed, orphan, unsaved)
+
+	srv.cleanupOrphanFiles(context.Background())
+	for _, c := range []struct {
+		f    attach.File
+		want bool
+	}{{live, true}, {trashed, true}, {orphan, false}, {fresh, true}, {unsaved, true}} {
+		if _, err := srv.files.Stat(c.f.Hash); (err == nil) != c.want {
+			t.Errorf("%s: exists = %v, want %v", c.f.Name, err == nil, c.want)
+		}
+	}
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.FilePurge}); len(got) != 1 || got[0].Actor != systemActor {
+		t.Fatalf("expected one system file.purge event, got %+v", got)
+	}
+
+	// 永久删除回收站条目后，其附件随之成为孤儿被清理。
+	cookie, csrf := newTestSession(t, srv)
+	postForm(srv, "/admin/trash/"+gone.Slug+"/delete", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if _, err := srv.files.Stat(trashed.Hash); err == nil {
+		t.Fatalf("attachment of purged entry should be removed")
+	}
+}
+
+// backdate 重写附件元数据中的上传时间。
+func backdate(t *testing.T, srv *Server, at time.Time, files ...attach.File) {
+	t.Helper()
+	for _, f := range files {
+		f.UploadedAt = at
+		data, _ := json.Marshal(f)
+		if err := os.WriteFile(filepath.Join(srv.files.Dir(), f.Hash+".json"), data, 0o644); err != nil {
+			t.Fatalf("backdate %s: %v", f.Name, err)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// contentProbeInterval 为内容目录写入探测的最短间隔。/readyz 无需认证，
+// 间隔内的检查复用上次结果，避免被频繁请求时反复写盘。
+const contentProbeInterval = time.Second
+
+type readinessCheck struct {
+	Status    string  `json:"status"`
+	LatencyMS float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type readinessReport struct {
+	Status string                    `json:"status"`
+	Checks map[string]readinessCheck `json:"checks"`
+}
+
+// contentProbe 串行执行并缓存内容目录探测，并发的就绪检查共用一次结果。
+type contentProbe struct {
+	mu  sync.Mutex
+	at  time.Time
+	err error
+}
+
+func (p *contentProbe) run(probe func() error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.at.IsZero() && time.Since(p.at) < contentProbeInterval {
+		return p.err
+	}
+	p.err = probe()
+	p.at = time.Now()
+	return p.err
+}
+
+// SetDraining 标记服务进入优雅关停：/readyz 此后返回 503，
+// 让负载均衡器在 httpServer.Shutdown 之前把流量摘走。
+func (s *Server) SetDraining() {
+	s.draining.Store(true)
+}
+
+// ready 执行深度就绪检查，以 JSON 报告每项检查的状态与耗时。
+// 与只反映进程存活的 /healthz 不同，任一检查失败或正在关停时返回 503。
+func (s *Server) ready(w http.ResponseWriter, r *http.Request) {
+	report := readinessReport{Status: "ok", Checks: make(map[string]readinessCheck)}
+	run := func(name string, fn func() error) {
+		start := time.Now()
+		err := fn()
+		c := readinessCheck{Status: "ok", LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+		if err != nil {
+			c.Status = "fail"
+			c.Error = err.Error()
+			report.Status = "fail"
+		}
+		report.Checks[name] = c
+	}
+
+	run("shutdown", func() error {
+		if s.draining.Load() {
+			return errors.New("server is shutting down")
+		}
+		return nil
+	})
+	run("content_dir", func() error { return s.probe.run(s.store.Probe) })
+	run("templates", s.checkTemplates)
+
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// checkTemplates 确认模板目录仍可读取，且其中每个模板都已在启动时加载。
+// 模板清单取自与启动时相同的 glob，新增模板无需另行登记。
+func (s *Server) checkTemplates() error {
+	paths, err := filepath.Glob(s.tplPattern)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		return errors.New("no templates found: " + s.tplPattern)
+	}
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if s.templates.Lookup(name) == nil {
+			return errors.New("template not loaded: " + name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+func decodeReadiness(t *testing.T, w *httptest.ResponseRecorder) readinessReport {
+	t.Helper()
+	var report readinessReport
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode readiness: %v, body: %s", err, w.Body.String())
+	}
+	return report
+}
+
+// TestReadyz 验证就绪检查在正常情况下逐项报告 ok。
+func TestReadyz(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200, body: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	report := decodeReadiness(t, w)
+	for _, name := range []string{"shutdown", "content_dir", "templates"} {
+		if c, ok := report.Checks[name]; !ok || c.Status != "ok" {
+			t.Errorf("check %s = %+v, want ok", name, c)
+		}
+	}
+}
+
+// TestReadyzDraining 验证进入优雅关停后 /readyz 返回 503，而 /healthz 仍为 200。
+func TestReadyzDraining(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	srv.SetDraining()
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("readyz while draining: status = %d, want 503", w.Code)
+	}
+	if report := decodeReadiness(t, w); report.Checks["shutdown"].Status != "fail" {
+		t.Errorf("expected shutdown check to fail, got %+v", report.Checks["shutdown"])
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("healthz while draining: status = %d, want 200", w.Code)
+	}
+}
+
+// TestReadyzContentDirMissing 验证内容目录不可用时就绪检查失败。
+func TestReadyzContentDirMissing(t *testing.T) {
+	root := t.TempDir()
+	store, err := content.NewStore(root)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	if err := os.RemoveAll(root); err != nil {
+		t.Fatalf("remove content dir: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	report := decodeReadiness(t, w)
+	if c := report.Checks["content_dir"]; c.Status != "fail" || c.Error == "" {
+		t.Errorf("content_dir check = %+v, want fail with error", c)
+	}
+}
+
+// TestReadyzTemplatesFollowDirectory 验证模板清单取自模板目录：
+// 启动后新增的模板未被加载，就绪检查应报告失败。
+func TestReadyzTemplatesFollowDirectory(t *testing.T) {
+	tplDir := t.TempDir()
+	paths, err := filepath.Glob("../../templates/*.tmpl")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("glob templates: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read template: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(tplDir, filepath.Base(path)), data, 0o644); err != nil {
+			t.Fatalf("copy template: %v", err)
+		}
+	}
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, tplDir)
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	if err := srv.checkTemplates(); err != nil {
+		t.Fatalf("check templates: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(tplDir, "extra.tmpl"), []byte("extra"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", w.Code)
+	}
+	if c := decodeReadiness(t, w).Checks["templates"]; c.Status != "fail" || !strings.Contains(c.Error, "extra.tmpl") {
+		t.Errorf("templates check = %+v, want fail naming extra.tmpl", c)
+	}
+}
+
+// TestContentProbeRateLimited 验证间隔内的就绪检查复用上次探测结果，不重复写盘。
+func TestContentProbeRateLimited(t *testing.T) {
+	var p contentProbe
+	calls := 0
+	probe := func() error {
+		calls++
+		return errors.New("disk full")
+	}
+	for i := 0; i < 3; i++ {
+		if err := p.run(probe); err == nil {
+			t.Fatalf("cached probe should keep the last error")
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("probe ran %d times within the interval, want 1", calls)
+	}
+	p.at = time.Now().Add(-contentProbeInterval)
+	p.run(probe)
+	if calls != 2 {
+		t.Fatalf("probe should run again after the interval, ran %d times", calls)
+	}
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"minisnap/internal/attach"
+	"minisnap/internal/imageproc"
+)
+
+// displayVariant 为 Markdown 中插入的图片版本，点击可查看原图。
+const displayVariant = "w1280"
+
+// imageSizes 为 srcset 配套的 sizes 属性，与阅读页正文的最大宽度一致。
+const imageSizes = "(max-width: 880px) 100vw, 880px"
+
+// errInvalidImage 表示上传内容被识别为图片，但无法解码。
+var errInvalidImage = errors.New("invalid image")
+
+// storeUpload 保存上传的文件。图片先移除 EXIF 等元数据，再生成缩略图与缩放版本；
+// 其他类型原样流式写入。
+func (s *Server) storeUpload(name string, file multipart.File, limit int64) (attach.File, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return attach.File{}, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return attach.File{}, err
+	}
+	mimeType := attach.DetectMIME(head[:n])
+	if !attach.InlineImage(mimeType) {
+		return s.files.Save(name, file, limit)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, limit+1))
+	if err != nil {
+		return attach.File{}, err
+	}
+	if int64(len(data)) > limit {
+		return attach.File{}, attach.ErrTooLarge
+	}
+	img, err := imageproc.Process(data, mimeType)
+	if errors.Is(err, imageproc.ErrTooManyPixels) {
+		return attach.File{}, err
+	}
+	if err != nil {
+		return attach.File{}, errInvalidImage
+	}
+
+	f, err := s.files.Save(name, bytes.NewReader(img.Data), limit)
+	if err != nil || f.Width > 0 {
+		// 相同内容已处理过，直接复用已有变体。
+		return f, err
+	}
+	renditions := make([]attach.Rendition, 0, len(img.Variants))
+	for _, v := range img.Variants {
+		renditions = append(renditions, attach.Rendition{
+			Variant: attach.Variant{Name: v.Name, Width: v.Width, Height: v.Height, MIME: v.MIME},
+			Data:    v.Data,
+		})
+	}
+	return s.files.SetImage(f.Hash, img.Width, img.Height, renditions)
+}
+
+// serveFileVariant 提供图片附件的缩略图或缩放版本。
+func (s *Server) serveFileVariant(w http.ResponseWriter, r *http.Request) {
+	hash, name := r.PathValue("hash"), r.PathValue("variant")
+	blob, v, err := s.files.OpenVariant(hash, name)
+	if errors.Is(err, attach.ErrNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "open file variant", "hash", hash, "variant", name, "error", err)
+		s.renderError(w, http.StatusInternalServerError, "Internal Server Error")
+		return
+	}
+	defer blob.Close()
+
+	h := w.Header()
+	h.Set("Content-Type", v.MIME)
+	h.Set("Content-Disposition", "inline")
+	h.Set("Cache-Control", "public, max-age=31536000, immutable")
+	h.Set("ETag", `"`+hash+"-"+name+`"`)
+	http.ServeContent(w, r, "", time.Time{}, blob)
+}
+
+// imageMarkdown 返回图片的 Markdown 引用。原图宽于展示尺寸时插入缩放版本，并链接到原图。
+func imageMarkdown(f attach.File) string {
+	label := markdownLabel(f.Name)
+	if _, ok := f.Variant(displayVariant); ok {
+		return "[![" + label + "](" + f.VariantURL(displayVariant) + ")](" + f.URL() + ")"
+	}
+	return "![" + label + "](" + f.URL() + ")"
+}
+
+// imageHTML 返回图片的 HTML 引用。有缩放版本时生成 <picture>/<source srcset>，
+// 由浏览器按显示宽度选择合适的版本，<img> 指向原图作为回退。
+func imageHTML(f attach.File) string {
+	img := `<img src="` + f.URL() + `" alt="` + html.EscapeString(f.Name) + `"`
+	if f.Width > 0 {
+		img += ` width="` + strconv.Itoa(f.Width) + `" height="` + strconv.Itoa(f.Height) + `"`
+	}
+	img += ` />`
+
+	srcset, typ := imageSrcset(f)
+	if srcset == "" {
+		return img
+	}
+	return `<picture><source type="` + typ + `" srcset="` + srcset + `" sizes="` + imageSizes + `" />` + img + `</picture>`
+}
+
+// imageSrcset 汇总按宽度生成的变体；原图与变体格式相同时也列入，供高分屏选用。
+func imageSrcset(f attach.File) (string, string) {
+	var parts []string
+	typ := ""
+	for _, v := range f.Variants {
+		if !strings.HasPrefix(v.Name, "w") {
+			continue
+		}
+		parts = append(parts, f.VariantURL(v.Name)+" "+strconv.Itoa(v.Width)+"w")
+		typ = v.MIME
+	}
+	if len(parts) == 0 {
+		return "", ""
+	}
+	if f.MIME == typ {
+		parts = append(parts, f.URL()+" "+strconv.Itoa(f.Width)+"w")
+	}
+	return strings.Join(parts, ", "), typ
+}
+
+// thumbnailURL 返回编辑器中展示的缩略图地址，小图直接使用原图。
+func thumbnailURL(f attach.File) string {
+	if _, ok := f.Variant("thumb"); ok {
+		return f.VariantURL("thumb")
+	}
+	return f.URL()
+}
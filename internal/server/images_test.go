@@ -0,0 +1,133 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"minisnap/internal/content"
+)
+
+// encodeTestImage 生成 w×h 的不透明图片并编码为 png 或 jpeg。
+func encodeTestImage(w, h int, format string) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		jpeg.Encode(&buf, img, nil)
+	} else {
+		png.Encode(&buf, img)
+	}
+	return buf.Bytes()
+}
+
+// withEXIF 在 JPEG 的 SOI 之后插入一段包含 GPS 文本的 APP1 段。
+func withEXIF(data []byte) []byte {
+	body := "Exif\x00\x00MM\x00\x2a\x00\x00\x00\x08\x00\x00GPSLatitude 48.8584"
+	seg := []byte{0xFF, 0xE1, byte((len(body) + 2) >> 8), byte(len(body) + 2)}
+	out := append([]byte(nil), data[:2]...)
+	out = append(out, seg...)
+	out = append(out, body...)
+	return append(out, data[2:]...)
+}
+
+func TestUploadImageStripsEXIFAndBuildsVariants(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "IMG_0001.jpg", withEXIF(encodeTestImage(1500, 1000, "jpeg")), cookie, csrf))
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d, body %s", w.Code, w.Body.String())
+	}
+	var res uploadResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+
+	// 原图不再含 EXIF。
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, res.URL, nil))
+	if w.Code != http.StatusOK || bytes.Contains(w.Body.Bytes(), []byte("GPSLatitude")) {
+		t.Fatalf("original still carries EXIF (status %d)", w.Code)
+	}
+
+	// 生成 640 / 1280 两档宽度与缩略图，可直接访问。
+	for _, v := range []struct {
+		name  string
+		width int
+	}{{"w640", 640}, {"w1280", 1280}, {"thumb", 320}} {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, res.URL+"/"+v.name, nil))
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/jpeg" {
+			t.Fatalf("%s: status %d, type %q", v.name, w.Code, w.Header().Get("Content-Type"))
+		}
+		cfg, err := jpeg.DecodeConfig(w.Body)
+		if err != nil || cfg.Width != v.width {
+			t.Fatalf("%s: width %d (%v), want %d", v.name, cfg.Width, err, v.width)
+		}
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, res.URL+"/w1920", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("variant wider than the original should not exist: status %d", w.Code)
+	}
+
+	if want := "[![IMG_0001.jpg](" + res.URL + "/w1280)](" + res.URL + ")"; res.Markdown != want {
+		t.Fatalf("markdown = %q, want %q", res.Markdown, want)
+	}
+	for _, want := range []string{"<picture>", `type="image/jpeg"`, res.URL + "/w640 640w", res.URL + " 1500w", `width="1500"`} {
+		if !strings.Contains(res.HTML, want) {
+			t.Errorf("html snippet missing %q: %s", want, res.HTML)
+		}
+	}
+
+	// 插入的 <picture> 片段经原始 HTML 消毒策略后保留 srcset。
+	rendered, err := content.RenderHTML(content.Entry{Renderer: content.RendererHTML, Raw: res.HTML})
+	if err != nil || !strings.Contains(string(rendered), "srcset=") || !strings.Contains(string(rendered), "<picture>") {
+		t.Fatalf("sanitized snippet lost srcset: %s (%v)", rendered, err)
+	}
+}
+
+func TestUploadRejectsCorruptImage(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	// 只有 PNG 签名与半截 IHDR，能被嗅探为图片但无法解码。
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "broken.png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"), cookie, csrf))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status %d, want 422", w.Code)
+	}
+}
+
+func TestEditorShowsThumbnails(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "wide.png", encodeTestImage(800, 400, "png"), cookie, csrf))
+	var res uploadResult
+	if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	entry, err := srv.store.Create(content.RendererMarkdown, res.Markdown, "")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	body := getWithCookie(srv, "/"+entry.Slug+"/edit", cookie).Body.String()
+	if !strings.Contains(body, res.URL+"/thumb") || !strings.Contains(body, "800×400") {
+		t.Fatalf("editor should show the thumbnail and dimensions")
+	}
+}
@@ -0,0 +1,107 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"minisnap/internal/content"
+)
+
+// frameSandbox 是承载原始 HTML 条目的 iframe 沙箱权限：不含 allow-same-origin 与
+// allow-scripts，框内文档处于不透明源，即使消毒被绕过也无法读取本站 cookie 或执行脚本。
+const frameSandbox = "allow-popups allow-popups-to-escape-sandbox"
+
+// contentHostRoutes 是独立内容域名上唯一允许访问的路由，后台与登录一律拒绝。
+var contentHostRoutes = map[string]bool{
+	"GET /{slug}/frame": true,
+	"GET /-/static/":    true,
+	// 原始 HTML 条目以相对路径引用附件，框内文档位于内容域名时同样需要能加载。
+	"GET /-/files/{hash}":           true,
+	"GET /-/files/{hash}/{variant}": true,
+}
+
+// configureIsolation 解析原始 HTML 隔离相关配置。
+func (s *Server) configureIsolation() error {
+	if s.cfg.ContentOrigin == "" {
+		return nil
+	}
+	u, err := url.Parse(s.cfg.ContentOrigin)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+		return fmt.Errorf("invalid content origin %q: want scheme://host[:port]", s.cfg.ContentOrigin)
+	}
+	s.contentOrigin = u.Scheme + "://" + u.Host
+	s.contentHost = strings.ToLower(u.Host)
+	return nil
+}
+
+// isolateHTML 报告原始 HTML 条目是否应放入沙箱 iframe 而非内联渲染。
+func (s *Server) isolateHTML() bool {
+	return s.cfg.SandboxHTML || s.contentOrigin != ""
+}
+
+// frameURL 返回承载条目的 iframe 地址；配置了独立内容域名时指向该域名。
+func (s *Server) frameURL(slug string) string {
+	return s.contentOrigin + "/" + url.PathEscape(slug) + "/frame"
+}
+
+// frameCSP 返回 iframe 文档的 CSP。使用独立内容域名时父页面位于另一个源，
+// 框内文档又是不可执行脚本的静态内容，因此不限制嵌入方。
+func (s *Server) frameCSP() string {
+	ancestors := "'self'"
+	if s.contentOrigin != "" {
+		ancestors = "*"
+	}
+	return "default-src 'none'; style-src 'unsafe-inline'; img-src * data:; media-src *; font-src * data:; " +
+		"sandbox " + frameSandbox + "; frame-ancestors " + ancestors
+}
+
+// guardContentHost 在独立内容域名上只放行 iframe 与静态资源路由，
+// 使该域名上永远不会出现后台页面或会话 cookie。
+func (s *Server) guardContentHost(next http.Handler) http.Handler {
+	if s.contentHost == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Host, s.contentHost) && !contentHostRoutes[routeFromContext(r.Context())] {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// frameEntry 输出仅包含条目 HTML 的独立文档，供阅读页的沙箱 iframe 加载。
+// 只承载原始 HTML 条目；Markdown 条目始终在阅读页内联渲染。
+func (s *Server) frameEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.loadPublicEntry(w, r)
+	if !ok {
+		return
+	}
+	if entry.Renderer != content.RendererHTML || !s.isolateHTML() {
+		s.renderError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	// 框内文档与登录态无关，统一按匿名访客缓存。
+	etag := s.pageETag(entry, false)
+	// 该文档本就用于被嵌入，去掉全局的 X-Frame-Options，改由 CSP frame-ancestors 约束。
+	w.Header().Del("X-Frame-Options")
+	if notModified(r, etag, entry.UpdatedAt) {
+		setValidators(w, etag, entry.UpdatedAt, s.pageCacheControl(false))
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	html, err := s.renderEntry(entry)
+	if err != nil {
+		s.renderError(w, http.StatusInternalServerError, "Render Failed")
+		return
+	}
+	setValidators(w, etag, entry.UpdatedAt, s.pageCacheControl(false))
+	s.renderTemplate(w, r, "frame.tmpl", map[string]any{
+		"Title": entry.Slug,
+		"HTML":  html,
+	})
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+// TestSandboxedHTMLEntry 验证开启沙箱后原始 HTML 条目经 iframe 承载，框内文档带 sandbox CSP。
+func TestSandboxedHTMLEntry(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass", SandboxHTML: true}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	htmlEntry, err := store.Create(content.RendererHTML, "<p class=\"marker\">raw html</p>", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+	mdEntry, err := store.Create(content.RendererMarkdown, "# markdown body", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+htmlEntry.Slug, nil))
+	body := w.Body.String()
+	if !strings.Contains(body, `src="/`+htmlEntry.Slug+`/frame"`) || !strings.Contains(body, `sandbox="`+frameSandbox+`"`) {
+		t.Fatalf("expected sandboxed iframe in view page, got: %s", body)
+	}
+	if strings.Contains(body, "raw html") {
+		t.Errorf("raw HTML must not be inlined on the main origin")
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+htmlEntry.Slug+"/frame", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "raw html") {
+		t.Fatalf("frame: status = %d, body: %s", w.Code, w.Body.String())
+	}
+	csp := w.Header().Get("Content-Security-Policy")
+	if !strings.Contains(csp, "sandbox "+frameSandbox) || strings.Contains(csp, "allow-same-origin") {
+		t.Errorf("frame CSP = %q, want opaque-origin sandbox", csp)
+	}
+	if w.Header().Get("X-Frame-Options") != "" {
+		t.Errorf("frame document must be embeddable")
+	}
+
+	// Markdown 条目仍内联渲染，且没有 frame 端点
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+mdEntry.Slug, nil))
+	if !strings.Contains(w.Body.String(), "markdown body") {
+		t.Errorf("markdown entry should render inline")
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+mdEntry.Slug+"/frame", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("markdown frame: status = %d, want 404", w.Code)
+	}
+}
+
+// TestFrameDisabledByDefault 验证未开启隔离时不提供 frame 端点，HTML 照常内联。
+func TestFrameDisabledByDefault(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererHTML, "<p>inline</p>", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Slug+"/frame", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("frame: status = %d, want 404", w.Code)
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil))
+	if !strings.Contains(w.Body.String(), "<p>inline</p>") {
+		t.Errorf("expected inline HTML without isolation")
+	}
+}
+
+// TestContentOrigin 验证独立内容域名：iframe 指向该域名，该域名上拒绝后台路由。
+func TestContentOrigin(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", ContentOrigin: "https://usercontent.example.net"}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererHTML, "<p>isolated</p>", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil))
+	if !strings.Contains(w.Body.String(), `src="https://usercontent.example.net/`+entry.Slug+`/frame"`) {
+		t.Fatalf("expected iframe on content origin, got: %s", w.Body.String())
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "frame-src 'self' https://usercontent.example.net") {
+		t.Errorf("view CSP must allow the content origin as frame source, got %q", csp)
+	}
+
+	onContentHost := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "https://usercontent.example.net"+path, nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		return w
+	}
+	if w := onContentHost(http.MethodGet, "/"+entry.Slug+"/frame"); w.Code != http.StatusOK {
+		t.Errorf("frame on content host: status = %d, want 200", w.Code)
+	}
+	for _, path := range []string{"/admin", "/login", "/admin/library", "/" + entry.Slug, "/" + entry.Slug + "/edit"} {
+		if w := onContentHost(http.MethodGet, path); w.Code != http.StatusNotFound {
+			t.Errorf("%s on content host: status = %d, want 404", path, w.Code)
+		}
+	}
+	if w := onContentHost(http.MethodPost, "/login"); w.Code != http.StatusNotFound {
+		t.Errorf("POST /login on content host: status = %d, want 404", w.Code)
+	}
+}
+
+func TestContentOriginInvalid(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	for _, origin := range []string{"usercontent.example.net", "ftp://x", "https://x/path"} {
+		if _, err := New(config.Config{AdminPassword: "testpass", ContentOrigin: origin}, store, "../../templates"); err == nil {
+			t.Errorf("expected error for content origin %q", origin)
+		}
+	}
+}
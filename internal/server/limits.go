@@ -0,0 +1,156 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+const (
+	// defaultMaxEntrySize 为未配置 MaxEntrySize 时单篇条目内容的字节上限。
+	defaultMaxEntrySize = 1 << 20
+	// maxFormBody 为未单独配置的路由的请求体上限，足够容纳登录、设置等普通表单。
+	maxFormBody = 64 << 10
+	// maxBulkBody 为批量操作表单的请求体上限，可容纳 maxBulkItems 个 slug。
+	maxBulkBody = 256 << 10
+	// formOverhead 为编辑表单中内容以外的字段（描述、渲染器、CSRF token）预留的字节数。
+	formOverhead = 64 << 10
+)
+
+// entryRoutes 是提交条目内容的路由，请求体上限由 MaxEntrySize 推算。
+var entryRoutes = []string{"POST /admin", "POST /admin/preview", "POST /admin/preview/render", "POST /{slug}/edit", autosaveRoute}
+
+// uploadRoute 为附件上传路由，请求体上限由 UploadMaxSize 推算。
+const uploadRoute = "POST /admin/files"
+
+// maxEntrySize 返回单篇条目内容的字节上限。
+func (s *Server) maxEntrySize() int64 {
+	if s.cfg.MaxEntrySize > 0 {
+		return s.cfg.MaxEntrySize
+	}
+	return defaultMaxEntrySize
+}
+
+// buildBodyLimits 按路由模式生成请求体上限表；未列出的路由使用 maxFormBody。
+// 表单提交时内容按 URL 编码，最坏情况下膨胀为三倍，编辑路由据此放宽，
+// 再由处理函数按解码后的长度精确校验。
+func (s *Server) buildBodyLimits() {
+	entry := 3*s.maxEntrySize() + formOverhead
+	s.bodyLimits = map[string]int64{
+		uploadRoute:                s.uploadMaxSize() + multipartOverhead,
+		"POST /admin/library/bulk": maxBulkBody,
+	}
+	for _, route := range entryRoutes {
+		s.bodyLimits[route] = entry
+	}
+}
+
+// bodyLimit 返回当前请求所匹配路由的请求体上限。
+func (s *Server) bodyLimit(r *http.Request) int64 {
+	if n, ok := s.bodyLimits[routeFromContext(r.Context())]; ok {
+		return n
+	}
+	return maxFormBody
+}
+
+// limitBodies 按路由限制请求体大小。声明的 Content-Length 已超限时直接返回 413，
+// 否则用 http.MaxBytesReader 包裹请求体，读取超限时解析函数返回 *http.MaxBytesError。
+func (s *Server) limitBodies(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := s.bodyLimit(r)
+		if r.ContentLength > limit {
+			s.renderTooLarge(w, r, s.tooLargeMessage(r))
+			return
+		}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isTooLarge 报告错误是否由请求体超出 MaxBytesReader 上限引起。
+func isTooLarge(err error) bool {
+	var tooLarge *http.MaxBytesError
+	return errors.As(err, &tooLarge)
+}
+
+// parseRequestForm 解析 URL 编码或 multipart 表单。
+func parseRequestForm(r *http.Request) error {
+	if err := r.ParseForm(); err != nil {
+		return err
+	}
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseMultipartForm(uploadMemory)
+	}
+	return nil
+}
+
+// parseForm 解析表单，请求体超限时返回 413，其他错误返回 400。
+// 返回 false 表示已写入错误响应。
+func (s *Server) parseForm(w http.ResponseWriter, r *http.Request) bool {
+	err := r.ParseForm()
+	switch {
+	case err == nil:
+		return true
+	case isTooLarge(err):
+		s.renderTooLarge(w, r, s.tooLargeMessage(r))
+	default:
+		s.renderError(w, http.StatusBadRequest, "Invalid form data")
+	}
+	return false
+}
+
+// tooLargeMessage 说明当前路由被超出的上限。
+func (s *Server) tooLargeMessage(r *http.Request) string {
+	route := routeFromContext(r.Context())
+	switch {
+	case route == uploadRoute:
+		return "File exceeds the " + formatSize(s.uploadMaxSize()) + " upload limit."
+	case slices.Contains(entryRoutes, route):
+		return entryTooLargeMessage(s.maxEntrySize())
+	default:
+		return "The request exceeds the " + formatSize(s.bodyLimit(r)) + " size limit."
+	}
+}
+
+func entryTooLargeMessage(limit int64) string {
+	return "Content exceeds the " + formatSize(limit) + " entry size limit."
+}
+
+// renderTooLarge 返回 413：请求 JSON 的调用方（上传、通行密钥等脚本接口）得到
+// {"error": ...}，浏览器表单提交得到错误页面。
+func (s *Server) renderTooLarge(w http.ResponseWriter, r *http.Request, message string) {
+	if wantsJSON(r) {
+		jsonError(w, http.StatusRequestEntityTooLarge, message)
+		return
+	}
+	s.renderErrorPage(w, r, http.StatusRequestEntityTooLarge, "Too Large", message)
+}
+
+// wantsJSON 报告请求是否期望 JSON 响应。
+func wantsJSON(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "application/json") ||
+		strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
+
+// checkEntrySize 校验条目内容长度与存储配额，growth 为保存后内容目录的预计增长字节数。
+// 返回 false 表示已写入 413 响应。
+func (s *Server) checkEntrySize(w http.ResponseWriter, r *http.Request, raw string, growth int64) bool {
+	if int64(len(raw)) > s.maxEntrySize() {
+		s.renderTooLarge(w, r, entryTooLargeMessage(s.maxEntrySize()))
+		return false
+	}
+	if err := s.checkQuota(growth); err != nil {
+		if !errors.Is(err, errQuotaExceeded) {
+			slog.ErrorContext(r.Context(), "measure storage", "error", err)
+			s.renderError(w, http.StatusInternalServerError, "Failed to check storage quota")
+			return false
+		}
+		s.renderTooLarge(w, r, s.quotaMessage())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+func newLimitTestServer(t *testing.T, cfg config.Config) *Server {
+	t.Helper()
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg.AdminPassword = "testpass"
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	return srv
+}
+
+func TestEntrySizeLimit(t *testing.T) {
+	srv := newLimitTestServer(t, config.Config{MaxEntrySize: 1 << 10})
+	cookie, csrf := newTestSession(t, srv)
+
+	form := url.Values{csrfFieldName: {csrf}, "renderer": {"markdown"}, "content": {strings.Repeat("a", 1<<10)}}
+	if w := postForm(srv, "/admin", form, cookie, ""); w.Code != http.StatusOK {
+		t.Fatalf("entry at the limit: status %d", w.Code)
+	}
+
+	form.Set("content", strings.Repeat("a", 1<<10+1))
+	w := postForm(srv, "/admin", form, cookie, "")
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "1 KB entry size limit") {
+		t.Fatalf("oversize entry: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	// 声明的 Content-Length 超出路由上限时，不读取请求体直接拒绝。
+	form.Set("content", strings.Repeat("a", 200<<10))
+	if w := postForm(srv, "/admin/preview", form, cookie, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("oversize preview body: status %d", w.Code)
+	}
+	entries, _ := srv.store.List()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want only the one within the limit", len(entries))
+	}
+}
+
+// TestBodyLimitWithoutContentLength 验证分块传输的超大请求体在读取时被截断，
+// 并返回 413 而不是误报为缺少 CSRF token。
+func TestBodyLimitWithoutContentLength(t *testing.T) {
+	srv := newLimitTestServer(t, config.Config{})
+	cookie, csrf := newTestSession(t, srv)
+
+	form := url.Values{csrfFieldName: {csrf}, "ip": {strings.Repeat("1", maxFormBody)}}
+	req := httptest.NewRequest(http.MethodPost, "/admin/lockouts/unlock", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.ContentLength = -1
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "64 KB size limit") {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+
+	// 未登录的登录表单同样受默认上限约束。
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("password="+strings.Repeat("x", maxFormBody)))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("login: status %d, want 413", w.Code)
+	}
+}
+
+func TestTooLargeJSON(t *testing.T) {
+	srv := newLimitTestServer(t, config.Config{UploadMaxSize: 16})
+	cookie, csrf := newTestSession(t, srv)
+
+	req := uploadRequest(t, "huge.bin", bytes.Repeat([]byte("x"), 2*multipartOverhead), cookie, csrf)
+	req.Header.Set("Accept", "application/json")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	var body map[string]string
+	if w.Code != http.StatusRequestEntityTooLarge || json.Unmarshal(w.Body.Bytes(), &body) != nil {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+	if body["error"] != "File exceeds the 16 B upload limit." {
+		t.Fatalf("error = %q", body["error"])
+	}
+}
+
+func TestStorageQuota(t *testing.T) {
+	srv := newLimitTestServer(t, config.Config{StorageQuota: 4 << 10})
+	cookie, csrf := newTestSession(t, srv)
+
+	form := url.Values{csrfFieldName: {csrf}, "renderer": {"markdown"}, "content": {strings.Repeat("a", 2<<10)}}
+	if w := postForm(srv, "/admin", form, cookie, ""); w.Code != http.StatusOK {
+		t.Fatalf("first entry: status %d", w.Code)
+	}
+	w := postForm(srv, "/admin", form, cookie, "")
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "Storage quota exceeded") {
+		t.Fatalf("over quota: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	entries, err := srv.store.List()
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("list: %d entries, %v", len(entries), err)
+	}
+	slug := entries[0].Slug
+
+	// 超额时不能把条目改长，但可以改短。
+	form.Set("content", strings.Repeat("a", 4<<10))
+	if w := postForm(srv, "/"+slug+"/edit", form, cookie, ""); w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("growing update: status %d, want 413", w.Code)
+	}
+	form.Set("content", "short")
+	if w := postForm(srv, "/"+slug+"/edit", form, cookie, ""); w.Code != http.StatusOK {
+		t.Fatalf("shrinking update: status %d", w.Code)
+	}
+
+	// 上传同样受配额约束，并返回 JSON 错误。
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, uploadRequest(t, "big.bin", bytes.Repeat([]byte("x"), 8<<10), cookie, csrf))
+	if w.Code != http.StatusRequestEntityTooLarge || !strings.Contains(w.Body.String(), "Storage quota exceeded") {
+		t.Fatalf("upload over quota: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	body := getWithCookie(srv, "/admin/library", cookie).Body.String()
+	if !strings.Contains(body, "of 4 KB used") || !strings.Contains(body, "<progress") {
+		t.Fatalf("library header should show storage usage")
+	}
+}
+
+func TestLibraryShowsUsageWithoutQuota(t *testing.T) {
+	srv := newLimitTestServer(t, config.Config{})
+	cookie, _ := newTestSession(t, srv)
+	body := getWithCookie(srv, "/admin/library", cookie).Body.String()
+	if !strings.Contains(body, "Storage: ") || strings.Contains(body, "<progress") {
+		t.Fatalf("library should show usage without a quota meter")
+	}
+}
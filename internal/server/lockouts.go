@@ -0,0 +1,77 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"minisnap/internal/audit"
+)
+
+type lockoutsTemplateData struct {
+	Title        string
+	Locked       []lockoutItem
+	GlobalLocked bool
+	Notice       string
+	CSRFToken    string
+}
+
+type lockoutItem struct {
+	IP          string
+	Failures    int
+	Strikes     int
+	LockedAt    string
+	LockedUntil string
+	Remaining   string
+}
+
+// showLockouts 列出当前被登录限流锁定的 IP。
+func (s *Server) showLockouts(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+	var items []lockoutItem
+	for _, l := range s.loginLim.locked(now) {
+		items = append(items, lockoutItem{
+			IP:          l.IP,
+			Failures:    l.Failures,
+			Strikes:     l.Strikes,
+			LockedAt:    l.LockedAt.Format("2006-01-02 15:04:05"),
+			LockedUntil: l.LockedUntil.Format("2006-01-02 15:04:05"),
+			Remaining:   l.LockedUntil.Sub(now).Round(time.Second).String(),
+		})
+	}
+
+	var notice string
+	switch {
+	case r.URL.Query().Get("unlocked") != "":
+		notice = "Unlocked " + r.URL.Query().Get("unlocked")
+	case r.URL.Query().Get("missing") != "":
+		notice = r.URL.Query().Get("missing") + " is not locked"
+	}
+
+	s.renderTemplate(w, r, "lockouts.tmpl", lockoutsTemplateData{
+		Title:        "Login Lockouts",
+		Locked:       items,
+		GlobalLocked: s.loginLim.globalLocked(now),
+		Notice:       notice,
+		CSRFToken:    s.csrfToken(r),
+	})
+}
+
+// unlockIP 手动解除某 IP 的锁定。
+func (s *Server) unlockIP(w http.ResponseWriter, r *http.Request) {
+	ip := strings.TrimSpace(r.FormValue("ip"))
+	if _, err := netip.ParseAddr(ip); err != nil {
+		s.renderErrorPage(w, r, http.StatusBadRequest, "Invalid IP", "The submitted address is not a valid IP.")
+		return
+	}
+	if !s.loginLim.release(ip) {
+		http.Redirect(w, r, "/admin/lockouts?missing="+url.QueryEscape(ip), http.StatusSeeOther)
+		return
+	}
+	slog.InfoContext(r.Context(), "login lockout released", "ip", ip)
+	s.audit(r, audit.Event{Action: audit.LockoutRelease, Detail: ip})
+	http.Redirect(w, r, "/admin/lockouts?unlocked="+url.QueryEscape(ip), http.StatusSeeOther)
+}
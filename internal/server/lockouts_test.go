@@ -0,0 +1,53 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLockoutsPageAndUnlock(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+	for i := 0; i < 5; i++ {
+		srv.loginLim.recordFailure("198.51.100.7", time.Now())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/lockouts", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "198.51.100.7") {
+		t.Fatalf("expected locked IP in page")
+	}
+
+	form := url.Values{"ip": {"198.51.100.7"}, "csrf_token": {csrf}}
+	req = httptest.NewRequest(http.MethodPost, "/admin/lockouts/unlock", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(cookie)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("unlock status = %d, want 303", w.Code)
+	}
+	if srv.loginLim.isLocked("198.51.100.7", time.Now()) {
+		t.Fatalf("expected IP to be unlocked")
+	}
+}
+
+func TestLockoutsRequireAuth(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	req := httptest.NewRequest(http.MethodPost, "/admin/lockouts/unlock", strings.NewReader("ip=198.51.100.7"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code == http.StatusSeeOther {
+		t.Fatalf("unauthenticated unlock must not succeed")
+	}
+}
@@ -1,96 +1,246 @@
 package server
 
 import (
-	"net"
-	"net/http"
-	"strings"
+	"sort"
 	"sync"
 	"time"
+
+	"minisnap/internal/config"
 )
 
+// loginPolicy 描述登录限流策略。
+type loginPolicy struct {
+	MaxFails int           // 窗口内允许的失败次数
+	Window   time.Duration // 失败计数窗口
+	Lockout  time.Duration // 首次锁定时长
+	// MaxLockout 为指数退避的上限；同一 IP 每次再被锁定，时长翻倍直至该值。
+	// 锁定解除后超过该时长未再被锁定，退避等级清零。零值表示不退避。
+	MaxLockout time.Duration
+	// GlobalMaxFails 为窗口内所有 IP 合计的失败上限，达到后全局暂停密码登录，
+	// 用于拖慢分布式猜测。匿名请求即可触发，默认关闭；通行密钥登录与已通过密码的
+	// 验证码步骤不受其影响，管理员仍可借此登录。零值关闭。
+	GlobalMaxFails int
+}
+
 // loginLimiter 基于 IP 对登录失败进行限流。
 // 仅统计失败的尝试，避免误伤正常浏览。
-// 在 window 时间窗口内，某 IP 的失败次数达到 maxFails 后即被锁定 lockDur。
+// 在 window 时间窗口内，某 IP 的失败次数达到 maxFails 后即被锁定。
 type loginLimiter struct {
-	mu       sync.Mutex
-	maxFails int
-	window   time.Duration
-	lockDur  time.Duration
-	fails    map[string]*failState
+	mu     sync.Mutex
+	policy loginPolicy
+	fails  map[string]*failState
+
+	globalCount int
+	globalStart time.Time
+	lastPurge   time.Time
 }
 
 type failState struct {
-	count    int
-	firstAt  time.Time // 窗口内首次失败时间
-	lockedAt time.Time // 进入锁定的时间；零值表示未锁定
+	count       int
+	firstAt     time.Time // 窗口内首次失败时间
+	lockedAt    time.Time // 进入锁定的时间；零值表示未锁定
+	lockedUntil time.Time
+	strikes     int // 历史锁定次数，决定下一次锁定时长
 }
 
-func newLoginLimiter(maxFails int, window, lockDur time.Duration) *loginLimiter {
-	return &loginLimiter{
-		maxFails: maxFails,
-		window:   window,
-		lockDur:  lockDur,
-		fails:    make(map[string]*failState),
-	}
+// lockedIP 是管理页展示的一条锁定记录。
+type lockedIP struct {
+	IP          string
+	Failures    int
+	Strikes     int
+	LockedAt    time.Time
+	LockedUntil time.Time
 }
 
-// ipFromRequest 提取客户端 IP，优先使用 X-Forwarded-For 的首个地址。
-func ipFromRequest(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		for i := 0; i < len(xff); i++ {
-			if xff[i] == ',' {
-				return net.ParseIP(strings.TrimSpace(xff[:i])).String()
-			}
-		}
-		return net.ParseIP(strings.TrimSpace(xff)).String()
-	}
-	host, _, err := net.SplitHostPort(r.RemoteAddr)
-	if err != nil {
-		return r.RemoteAddr
+func newLoginLimiter(policy loginPolicy) *loginLimiter {
+	return &loginLimiter{
+		policy: policy,
+		fails:  make(map[string]*failState),
 	}
-	return host
 }
 
 // isLocked 返回是否处于锁定状态。锁定过期会自动解除。
 func (l *loginLimiter) isLocked(ip string, now time.Time) bool {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.maybePurge(now)
 	st, ok := l.fails[ip]
 	if !ok || st.lockedAt.IsZero() {
 		return false
 	}
-	if now.Sub(st.lockedAt) >= l.lockDur {
-		// 锁定过期，清空计数重新开始
-		delete(l.fails, ip)
+	if !now.Before(st.lockedUntil) {
+		// 锁定过期，清空计数重新开始；退避等级保留到 purge 判定遗忘为止
+		l.unlock(ip, st)
 		return false
 	}
 	return true
 }
 
-// recordFailure 记录一次失败尝试，必要时触发锁定。
-func (l *loginLimiter) recordFailure(ip string, now time.Time) {
+// globalLocked 报告全局失败次数是否已达上限。
+func (l *loginLimiter) globalLocked(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.policy.GlobalMaxFails <= 0 || now.Sub(l.globalStart) >= l.policy.Window {
+		return false
+	}
+	return l.globalCount >= l.policy.GlobalMaxFails
+}
+
+// recordFailure 记录一次失败尝试，必要时触发锁定。本次失败导致进入锁定时返回 true。
+func (l *loginLimiter) recordFailure(ip string, now time.Time) bool {
 	l.mu.Lock()
 	defer l.mu.Unlock()
+	l.maybePurge(now)
+
+	if now.Sub(l.globalStart) >= l.policy.Window {
+		l.globalCount = 0
+		l.globalStart = now
+	}
+	l.globalCount++
+
 	st, ok := l.fails[ip]
 	if !ok {
 		st = &failState{firstAt: now}
 		l.fails[ip] = st
 	}
 	// 窗口过期则重置计数
-	if now.Sub(st.firstAt) >= l.window {
+	if now.Sub(st.firstAt) >= l.policy.Window && st.lockedAt.IsZero() {
 		st.count = 0
 		st.firstAt = now
-		st.lockedAt = time.Time{}
 	}
 	st.count++
-	if st.count >= l.maxFails && st.lockedAt.IsZero() {
+	if st.count >= l.policy.MaxFails && st.lockedAt.IsZero() {
 		st.lockedAt = now
+		st.lockedUntil = now.Add(l.lockoutFor(st.strikes))
+		st.strikes++
+		return true
+	}
+	return false
+}
+
+// lockoutFor 返回第 strikes+1 次锁定的时长：Lockout × 2^strikes，不超过 MaxLockout。
+func (l *loginLimiter) lockoutFor(strikes int) time.Duration {
+	d := l.policy.Lockout
+	for i := 0; i < strikes && d < l.policy.MaxLockout; i++ {
+		d *= 2
 	}
+	if l.policy.MaxLockout > l.policy.Lockout && d > l.policy.MaxLockout {
+		d = l.policy.MaxLockout
+	}
+	return d
 }
 
-// recordSuccess 登录成功时清除该 IP 的失败记录。
+// recordSuccess 登录成功时清除该 IP 的失败记录与退避等级。
 func (l *loginLimiter) recordSuccess(ip string) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 	delete(l.fails, ip)
 }
+
+// locked 返回当前仍处于锁定中的 IP，按解锁时间倒序。
+func (l *loginLimiter) locked(now time.Time) []lockedIP {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []lockedIP
+	for ip, st := range l.fails {
+		if st.lockedAt.IsZero() || !now.Before(st.lockedUntil) {
+			continue
+		}
+		out = append(out, lockedIP{
+			IP:          ip,
+			Failures:    st.count,
+			Strikes:     st.strikes,
+			LockedAt:    st.lockedAt,
+			LockedUntil: st.lockedUntil,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].LockedUntil.Equal(out[j].LockedUntil) {
+			return out[i].LockedUntil.After(out[j].LockedUntil)
+		}
+		return out[i].IP < out[j].IP
+	})
+	return out
+}
+
+// release 由管理员手动解除某 IP 的锁定，并清零其退避等级。IP 不存在时返回 false。
+func (l *loginLimiter) release(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.fails[ip]; !ok {
+		return false
+	}
+	delete(l.fails, ip)
+	return true
+}
+
+// size 返回当前跟踪的 IP 数，主要供测试使用。
+func (l *loginLimiter) size() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.fails)
+}
+
+func (l *loginLimiter) unlock(ip string, st *failState) {
+	if st.strikes == 0 || l.policy.MaxLockout <= 0 {
+		delete(l.fails, ip)
+		return
+	}
+	st.count = 0
+	st.lockedAt = time.Time{}
+}
+
+// maybePurge 每个窗口最多清理一次：删除窗口已过且未锁定、退避等级也已遗忘的条目，
+// 避免大量一次性来源让映射无限增长。调用方需持有锁。
+func (l *loginLimiter) maybePurge(now time.Time) {
+	if now.Sub(l.lastPurge) < l.policy.Window {
+		return
+	}
+	l.lastPurge = now
+	l.purge(now)
+}
+
+func (l *loginLimiter) purge(now time.Time) {
+	for ip, st := range l.fails {
+		if !st.lockedAt.IsZero() {
+			if now.Before(st.lockedUntil) {
+				continue
+			}
+			l.unlock(ip, st)
+			if _, ok := l.fails[ip]; !ok {
+				continue
+			}
+		}
+		if now.Sub(st.firstAt) < l.policy.Window {
+			continue
+		}
+		if st.strikes > 0 && now.Sub(st.lockedUntil) < l.policy.MaxLockout {
+			continue
+		}
+		delete(l.fails, ip)
+	}
+}
+
+// loginPolicyFromConfig 由配置生成限流策略，未设置的字段取默认值。
+func loginPolicyFromConfig(cfg config.Config) loginPolicy {
+	p := loginPolicy{
+		MaxFails:       cfg.LoginMaxFails,
+		Window:         cfg.LoginWindow,
+		Lockout:        cfg.LoginLockout,
+		MaxLockout:     cfg.LoginMaxLockout,
+		GlobalMaxFails: cfg.LoginGlobalMaxFails,
+	}
+	if p.MaxFails <= 0 {
+		p.MaxFails = 5
+	}
+	if p.Window <= 0 {
+		p.Window = time.Minute
+	}
+	if p.Lockout <= 0 {
+		p.Lockout = time.Minute
+	}
+	if p.MaxLockout <= 0 {
+		p.MaxLockout = 24 * time.Hour
+	}
+	return p
+}
@@ -3,12 +3,16 @@ package server
 import (
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+
+	"minisnap/internal/config"
 )
 
 func TestLoginLimiterLocksAfterMaxFails(t *testing.T) {
-	l := newLoginLimiter(3, time.Minute, time.Minute)
+	l := newLoginLimiter(loginPolicy{MaxFails: 3, Window: time.Minute, Lockout: time.Minute})
 	now := time.Now()
 	ip := "10.0.0.1"
 
@@ -24,7 +28,7 @@ func TestLoginLimiterLocksAfterMaxFails(t *testing.T) {
 }
 
 func TestLoginLimiterUnlocksAfterExpiry(t *testing.T) {
-	l := newLoginLimiter(2, time.Minute, time.Minute)
+	l := newLoginLimiter(loginPolicy{MaxFails: 2, Window: time.Minute, Lockout: time.Minute})
 	now := time.Now()
 	ip := "10.0.0.2"
 
@@ -42,7 +46,7 @@ func TestLoginLimiterUnlocksAfterExpiry(t *testing.T) {
 }
 
 func TestLoginLimiterWindowReset(t *testing.T) {
-	l := newLoginLimiter(3, time.Minute, time.Minute)
+	l := newLoginLimiter(loginPolicy{MaxFails: 3, Window: time.Minute, Lockout: time.Minute})
 	ip := "10.0.0.3"
 
 	// 窗口内两次失败
@@ -56,7 +60,7 @@ func TestLoginLimiterWindowReset(t *testing.T) {
 }
 
 func TestLoginLimiterRecordSuccessClears(t *testing.T) {
-	l := newLoginLimiter(2, time.Minute, time.Minute)
+	l := newLoginLimiter(loginPolicy{MaxFails: 2, Window: time.Minute, Lockout: time.Minute})
 	ip := "10.0.0.4"
 
 	l.recordFailure(ip, time.Now())
@@ -68,7 +72,7 @@ func TestLoginLimiterRecordSuccessClears(t *testing.T) {
 }
 
 func TestLoginLimiterIsolatesByIP(t *testing.T) {
-	l := newLoginLimiter(2, time.Minute, time.Minute)
+	l := newLoginLimiter(loginPolicy{MaxFails: 2, Window: time.Minute, Lockout: time.Minute})
 	now := time.Now()
 
 	l.recordFailure("1.1.1.1", now)
@@ -81,27 +85,118 @@ func TestLoginLimiterIsolatesByIP(t *testing.T) {
 	}
 }
 
-func TestIPFromRequest(t *testing.T) {
-	tests := []struct {
-		name   string
-		xff    string
-		remote string
-		want   string
-	}{
-		{"direct", "", "203.0.113.5:1234", "203.0.113.5"},
-		{"xff single", "198.51.100.7", "10.0.0.1:99", "198.51.100.7"},
-		{"xff multi", "198.51.100.7, 10.0.0.1", "10.0.0.1:99", "198.51.100.7"},
-	}
-	for _, tc := range tests {
-		t.Run(tc.name, func(t *testing.T) {
-			req := httptest.NewRequest(http.MethodPost, "/login", nil)
-			req.RemoteAddr = tc.remote
-			if tc.xff != "" {
-				req.Header.Set("X-Forwarded-For", tc.xff)
-			}
-			if got := ipFromRequest(req); got != tc.want {
-				t.Fatalf("ipFromRequest = %q, want %q", got, tc.want)
-			}
-		})
+func TestLoginLimiterEscalatesRepeatLockouts(t *testing.T) {
+	l := newLoginLimiter(loginPolicy{MaxFails: 2, Window: time.Minute, Lockout: time.Minute, MaxLockout: 3 * time.Minute})
+	ip := "10.0.0.5"
+	now := time.Now()
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute}
+	for i, d := range want {
+		l.recordFailure(ip, now)
+		if !l.recordFailure(ip, now) {
+			t.Fatalf("round %d: expected lock", i)
+		}
+		if !l.isLocked(ip, now.Add(d-time.Second)) {
+			t.Fatalf("round %d: expected lock to last %v", i, d)
+		}
+		now = now.Add(d)
+		if l.isLocked(ip, now) {
+			t.Fatalf("round %d: expected unlock after %v", i, d)
+		}
+	}
+
+	// 成功登录清零退避等级
+	l.recordSuccess(ip)
+	l.recordFailure(ip, now)
+	l.recordFailure(ip, now)
+	if l.isLocked(ip, now.Add(time.Minute)) {
+		t.Fatalf("expected escalation reset after success")
+	}
+}
+
+func TestLoginLimiterGlobalCap(t *testing.T) {
+	l := newLoginLimiter(loginPolicy{MaxFails: 5, Window: time.Minute, Lockout: time.Minute, GlobalMaxFails: 3})
+	now := time.Now()
+	for i, ip := range []string{"1.1.1.1", "2.2.2.2", "3.3.3.3"} {
+		if l.globalLocked(now) {
+			t.Fatalf("global cap reached too early at %d", i)
+		}
+		l.recordFailure(ip, now)
+	}
+	if !l.globalLocked(now) {
+		t.Fatalf("expected global cap after 3 failures from distinct IPs")
+	}
+	if l.globalLocked(now.Add(time.Minute)) {
+		t.Fatalf("global cap should reset with the window")
+	}
+}
+
+// TestGlobalCapSparesPasskeys 验证全局上限只暂停密码登录，通行密钥登录不受影响。
+func TestGlobalCapSparesPasskeys(t *testing.T) {
+	srv := newLimitTestServer(t, config.Config{LoginGlobalMaxFails: 3})
+	for i, ip := range []string{"198.51.100.1:1", "198.51.100.2:1", "198.51.100.3:1"} {
+		if w := loginFrom(srv, "/login", "password=wrong", ip); w.Code != http.StatusOK {
+			t.Fatalf("failure %d: status %d", i, w.Code)
+		}
+	}
+	w := loginFrom(srv, "/login", "password=testpass", "198.51.100.4:1")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("password login under global cap: status %d, want 429", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Fatalf("429 page: Content-Type %q", ct)
+	}
+	// 没有进行中的仪式时返回 401，而不是被全局上限拒绝。
+	if w := loginFrom(srv, "/login/passkey", "{}", "198.51.100.4:1"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("passkey login under global cap: status %d, want 401", w.Code)
+	}
+}
+
+func loginFrom(srv *Server, path, body, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestLoginLimiterPurgesStaleEntries(t *testing.T) {
+	l := newLoginLimiter(loginPolicy{MaxFails: 5, Window: time.Minute, Lockout: time.Minute, MaxLockout: time.Hour})
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		l.recordFailure("198.51.100."+strconv.Itoa(i), now)
+	}
+	for i := 0; i < 5; i++ {
+		l.recordFailure("10.0.0.9", now)
+	}
+	if l.size() != 101 {
+		t.Fatalf("size = %d, want 101", l.size())
+	}
+
+	// 窗口过后一次性来源被清理，带退避等级的来源保留
+	l.isLocked("203.0.113.1", now.Add(2*time.Minute))
+	if l.size() != 1 {
+		t.Fatalf("size after purge = %d, want 1", l.size())
+	}
+	// 退避等级在 MaxLockout 后遗忘
+	l.isLocked("203.0.113.1", now.Add(2*time.Hour))
+	if l.size() != 0 {
+		t.Fatalf("size after forgetting = %d, want 0", l.size())
+	}
+}
+
+func TestLoginLimiterRelease(t *testing.T) {
+	l := newLoginLimiter(loginPolicy{MaxFails: 1, Window: time.Minute, Lockout: time.Minute})
+	now := time.Now()
+	l.recordFailure("10.0.0.6", now)
+	if got := l.locked(now); len(got) != 1 || got[0].IP != "10.0.0.6" {
+		t.Fatalf("locked = %+v", got)
+	}
+	if !l.release("10.0.0.6") {
+		t.Fatalf("expected release to succeed")
+	}
+	if l.isLocked("10.0.0.6", now) || l.release("10.0.0.6") {
+		t.Fatalf("expected IP to be fully released")
 	}
 }
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"minisnap/internal/content"
+	"minisnap/internal/metrics"
+)
+
+// serverMetrics 汇总服务暴露的全部 Prometheus 指标。
+type serverMetrics struct {
+	registry *metrics.Registry
+
+	requests        *metrics.CounterVec
+	requestDuration *metrics.HistogramVec
+	renderDuration  *metrics.HistogramVec
+	storeOps        *metrics.CounterVec
+	storeErrors     *metrics.CounterVec
+	loginFailures   *metrics.CounterVec
+	loginLockouts   *metrics.CounterVec
+}
+
+func newServerMetrics(s *Server) *serverMetrics {
+	reg := metrics.NewRegistry()
+	m := &serverMetrics{
+		registry: reg,
+		requests: reg.NewCounterVec("minisnap_http_requests_total",
+			"HTTP requests by route pattern and status code.", "route", "status"),
+		requestDuration: reg.NewHistogramVec("minisnap_http_request_duration_seconds",
+			"HTTP request latency by route pattern.", nil, "route"),
+		renderDuration: reg.NewHistogramVec("minisnap_render_duration_seconds",
+			"Time spent rendering and sanitizing entries by renderer type.",
+			[]float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}, "renderer"),
+		storeOps: reg.NewCounterVec("minisnap_store_operations_total",
+			"Content store operations by type.", "op"),
+		storeErrors: reg.NewCounterVec("minisnap_store_errors_total",
+			"Content store operations that failed, excluding not-found lookups.", "op"),
+		loginFailures: reg.NewCounterVec("minisnap_login_failures_total",
+			"Failed admin login attempts."),
+		loginLockouts: reg.NewCounterVec("minisnap_login_lockouts_total",
+			"Times a client IP was locked out by the login limiter."),
+	}
+
+	reg.NewGaugeFunc("minisnap_entries", "Number of entries in the content directory.", func() float64 {
+		n, err := s.store.Count()
+		if err != nil {
+			slog.Warn("count entries for metrics", "error", err)
+			return 0
+		}
+		return float64(n)
+	})
+	reg.NewGaugeFunc("minisnap_sessions_active", "Number of unexpired admin sessions.", func() float64 {
+		return float64(s.sessions.Count())
+	})
+
+	if s.renderCache != nil {
+		c := s.renderCache
+		reg.NewCounterFunc("minisnap_render_cache_hits_total", "Rendered-HTML cache hits.", func() float64 {
+			return float64(c.Stats().Hits)
+		})
+		reg.NewCounterFunc("minisnap_render_cache_misses_total", "Rendered-HTML cache misses.", func() float64 {
+			return float64(c.Stats().Misses)
+		})
+		reg.NewCounterFunc("minisnap_render_cache_evictions_total", "Rendered-HTML cache evictions.", func() float64 {
+			return float64(c.Stats().Evictions)
+		})
+		reg.NewGaugeFunc("minisnap_render_cache_entries", "Entries held in the rendered-HTML cache.", func() float64 {
+			return float64(c.Stats().Entries)
+		})
+	}
+
+	s.store.OnOperation(m.observeStore)
+	return m
+}
+
+func (m *serverMetrics) observeStore(op string, err error) {
+	m.storeOps.Inc(op)
+	if err != nil && !errors.Is(err, content.ErrEntryNotFound) {
+		m.storeErrors.Inc(op)
+	}
+}
+
+// render 渲染条目并记录渲染耗时。
+func (s *Server) render(entry content.Entry) (template.HTML, error) {
+	start := time.Now()
+	html, err := content.RenderHTML(entry)
+	s.metrics.renderDuration.Observe(time.Since(start).Seconds(), string(entry.Renderer))
+	return html, err
+}
+
+// MetricsHandler 返回 Prometheus 指标端点。配置了 MetricsToken 时要求 Bearer 认证。
+// 配置了 MetricsAddr 时由调用方挂载到独立监听地址；否则仅在配置了 MetricsToken 时
+// 挂载到主监听地址，不会未经认证地暴露在公网入口上。
+func (s *Server) MetricsHandler() http.Handler {
+	h := s.metrics.registry.Handler()
+	token := s.cfg.MetricsToken
+	if token == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+			s.renderError(w, http.StatusUnauthorized, "Unauthorized")
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+// TestMetricsEndpoint 验证 /metrics 暴露请求、存储、登录与会话指标，并按路由模式聚合。
+func TestMetricsEndpoint(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", RenderCacheEntries: 4, MetricsToken: "s3cret"}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererMarkdown, "# Hi", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil))
+	srv.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/missing", nil))
+
+	login := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("password=wrong"))
+	login.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	srv.ServeHTTP(httptest.NewRecorder(), login)
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics: status = %d", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`minisnap_http_requests_total{route="GET /{slug}",status="200"} 1`,
+		`minisnap_http_requests_total{route="GET /{slug}",status="404"} 1`,
+		`minisnap_http_requests_total{route="POST /login",status="200"} 1`,
+		`minisnap_http_request_duration_seconds_count{route="GET /{slug}"} 2`,
+		`minisnap_render_duration_seconds_count{renderer="markdown"} 1`,
+		`minisnap_store_operations_total{op="create"} 1`,
+		`minisnap_store_operations_total{op="get"} 2`,
+		`minisnap_entries 1`,
+		`minisnap_login_failures_total 1`,
+		`minisnap_sessions_active 0`,
+		`minisnap_render_cache_misses_total 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in metrics output", want)
+		}
+	}
+	// 未找到不计为存储错误
+	if strings.Contains(body, `minisnap_store_errors_total{op="get"}`) {
+		t.Errorf("not-found lookups must not count as store errors")
+	}
+	// 具体 slug 不得出现在标签中
+	if strings.Contains(body, entry.Slug) {
+		t.Errorf("metrics must not label by concrete slug")
+	}
+}
+
+// TestMetricsToken 验证配置 MetricsToken 后需 Bearer 认证。
+func TestMetricsToken(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", MetricsToken: "s3cret"}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("without token: status = %d, want 401", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("with token: status = %d, want 200", w.Code)
+	}
+}
+
+// TestMetricsSeparateAddr 验证配置 MetricsAddr 后主路由不再暴露 /metrics。
+func TestMetricsSeparateAddr(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", MetricsAddr: "127.0.0.1:9100"}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if strings.Contains(w.Body.String(), "minisnap_http_requests_total") {
+		t.Fatalf("main listener must not serve metrics when MetricsAddr is set")
+	}
+
+	w = httptest.NewRecorder()
+	srv.MetricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("metrics handler: status = %d", w.Code)
+	}
+}
+
+// TestMetricsDisabledWithoutToken 验证既未配置 MetricsAddr 也未配置 MetricsToken 时，
+// 主监听地址不提供未经认证的 /metrics。
+func TestMetricsDisabledWithoutToken(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code == http.StatusOK || strings.Contains(w.Body.String(), "minisnap_http_requests_total") {
+		t.Fatalf("main listener must not serve unauthenticated metrics, status = %d", w.Code)
+	}
+}
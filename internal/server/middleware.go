@@ -0,0 +1,138 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"minisnap/internal/logging"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// instrument 是最外层中间件：分配或沿用请求 ID，统计每个请求的路由、
+// 状态码与耗时，并输出一条结构化访问日志。
+func (s *Server) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		r = r.WithContext(logging.WithRequestID(r.Context(), id))
+
+		route := s.routePattern(r)
+		ip := s.proxies.clientIP(r)
+		ctx := context.WithValue(r.Context(), routeKey{}, route)
+		r = r.WithContext(withClientIP(ctx, ip))
+		// 在 handler 执行前判定登录态，避免登出请求被记为匿名。
+		user := ""
+		if _, ok := s.authenticated(r); ok {
+			user = adminUser
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		elapsed := time.Since(start)
+		status := rec.Status()
+
+		s.metrics.requestDuration.Observe(elapsed.Seconds(), route)
+		s.metrics.requests.Inc(route, strconv.Itoa(status))
+
+		slog.InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"route", route,
+			"path", r.URL.Path,
+			"status", status,
+			"bytes", rec.bytes,
+			"duration_ms", float64(elapsed.Microseconds())/1000,
+			"ip", ip,
+			"user", user,
+		)
+	})
+}
+
+// newRequestID 生成 16 字符的随机请求 ID。
+func newRequestID() string {
+	buf := make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// validRequestID 只接受长度适中、由可见安全字符组成的外部请求 ID，防止日志注入。
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-' || c == '_' || c == '.' || c == ':' || c == '/' || c == '+' || c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// statusRecorder 记录 handler 写出的状态码与字节数，供指标与日志中间件使用。
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(p []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(p)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap 让 http.ResponseController 能访问底层 ResponseWriter。
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// Status 返回最终状态码；handler 未显式写出时按 200 计。
+func (r *statusRecorder) Status() int {
+	if r.status == 0 {
+		return http.StatusOK
+	}
+	return r.status
+}
+
+type routeKey struct{}
+
+// routeFromContext 取出 instrument 中间件记录的路由模式。
+func routeFromContext(ctx context.Context) string {
+	route, _ := ctx.Value(routeKey{}).(string)
+	return route
+}
+
+// routePattern 返回请求将匹配的路由模式（如 "GET /{slug}"），
+// 用作指标标签可避免按具体 slug 产生无界的标签基数。
+func (s *Server) routePattern(r *http.Request) string {
+	if _, pattern := s.mux.Handler(r); pattern != "" {
+		return pattern
+	}
+	return "unmatched"
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+	"minisnap/internal/logging"
+)
+
+// captureLogs 把默认 logger 临时替换为写入缓冲区的 JSON logger。
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, "json", "debug")
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+	prev := slog.Default()
+	slog.SetDefault(logger)
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	sc := bufio.NewScanner(bytes.NewReader(buf.Bytes()))
+	for sc.Scan() {
+		var rec map[string]any
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			t.Fatalf("decode log line %q: %v", sc.Text(), err)
+		}
+		out = append(out, rec)
+	}
+	return out
+}
+
+// TestAccessLog 验证每个请求输出一条访问日志，并沿用合法的 X-Request-ID。
+func TestAccessLog(t *testing.T) {
+	buf := captureLogs(t)
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererMarkdown, "# Hi", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil)
+	req.RemoteAddr = "203.0.113.9:5555"
+	req.Header.Set("X-Request-ID", "abc-123")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if got := w.Header().Get("X-Request-ID"); got != "abc-123" {
+		t.Fatalf("X-Request-ID = %q, want propagated value", got)
+	}
+
+	recs := logRecords(t, buf)
+	if len(recs) != 1 {
+		t.Fatalf("expected exactly one access log line, got %d", len(recs))
+	}
+	rec := recs[0]
+	want := map[string]any{
+		"msg":        "request",
+		"method":     "GET",
+		"route":      "GET /{slug}",
+		"status":     float64(200),
+		"ip":         "203.0.113.9",
+		"user":       "",
+		"request_id": "abc-123",
+	}
+	for k, v := range want {
+		if rec[k] != v {
+			t.Errorf("%s = %v, want %v", k, rec[k], v)
+		}
+	}
+	if b, _ := rec["bytes"].(float64); b <= 0 {
+		t.Errorf("expected positive bytes, got %v", rec["bytes"])
+	}
+	if _, ok := rec["duration_ms"]; !ok {
+		t.Errorf("expected duration_ms field")
+	}
+}
+
+// TestRequestIDGeneratedAndThreaded 验证非法请求 ID 被替换，且 handler 内的错误日志携带同一请求 ID。
+func TestRequestIDGeneratedAndThreaded(t *testing.T) {
+	buf := captureLogs(t)
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	cookie, csrf := newTestSession(t, srv)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin", strings.NewReader("renderer=bogus&content=x&csrf_token="+csrf))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Request-ID", "bad id\nwith newline")
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	id := w.Header().Get("X-Request-ID")
+	if id == "" || strings.ContainsAny(id, " \n") {
+		t.Fatalf("expected generated request ID, got %q", id)
+	}
+
+	var sawError, sawAccess bool
+	for _, r := range logRecords(t, buf) {
+		switch r["msg"] {
+		case "create entry":
+			sawError = r["request_id"] == id
+		case "request":
+			sawAccess = r["request_id"] == id && r["user"] == adminUser
+		}
+	}
+	if !sawError {
+		t.Errorf("expected create entry error log with request_id %q", id)
+	}
+	if !sawAccess {
+		t.Errorf("expected access log with request_id %q and user %q", id, adminUser)
+	}
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/config"
+	"minisnap/internal/oidc"
+)
+
+const (
+	oidcCookieName = "minisnap_oidc"
+	// oidcFlowTimeout 为从跳转身份提供方到回调完成的最长时间。
+	oidcFlowTimeout = 10 * time.Minute
+)
+
+// oidcFlow 是一次进行中的单点登录，state / nonce / verifier 均只在服务端保存。
+type oidcFlow struct {
+	state       string
+	nonce       string
+	verifier    string
+	redirectURI string
+	next        string
+	expires     time.Time
+}
+
+// oidcFlows 按浏览器 cookie 保存待回调的登录，每个只能取用一次。
+type oidcFlows struct {
+	mu      sync.Mutex
+	pending map[string]oidcFlow
+}
+
+func newOIDCFlows() *oidcFlows {
+	return &oidcFlows{pending: make(map[string]oidcFlow)}
+}
+
+func (f *oidcFlows) begin(key string, flow oidcFlow) {
+	now := time.Now()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k, p := range f.pending {
+		if now.After(p.expires) {
+			delete(f.pending, k)
+		}
+	}
+	flow.expires = now.Add(oidcFlowTimeout)
+	f.pending[key] = flow
+}
+
+func (f *oidcFlows) take(key string) (oidcFlow, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.pending[key]
+	delete(f.pending, key)
+	if !ok || time.Now().After(p.expires) {
+		return oidcFlow{}, false
+	}
+	return p, true
+}
+
+// newOIDCClient 在配置了 OIDC_ISSUER 时创建客户端，否则返回 nil。
+func newOIDCClient(cfg config.Config) *oidc.Client {
+	if !cfg.OIDCEnabled() {
+		return nil
+	}
+	return &oidc.Client{Issuer: cfg.OIDCIssuer, ClientID: cfg.OIDCClientID, ClientSecret: cfg.OIDCClientSecret}
+}
+
+// oidcRedirectURI 返回回调地址。未配置 OIDC_REDIRECT_URL 时按请求推断。
+func (s *Server) oidcRedirectURI(r *http.Request) string {
+	if s.cfg.OIDCRedirectURL != "" {
+		return s.cfg.OIDCRedirectURL
+	}
+	return s.requestOrigin(r) + "/login/oidc/callback"
+}
+
+// startOIDC 生成 state、nonce 与 PKCE verifier，把浏览器引导至身份提供方。
+func (s *Server) startOIDC(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	state, err1 := oidc.NewState()
+	nonce, err2 := oidc.NewState()
+	verifier, err3 := oidc.NewVerifier()
+	if err1 != nil || err2 != nil || err3 != nil {
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Single sign-on", "Failed to start single sign-on.")
+		return
+	}
+	flow := oidcFlow{
+		state:       state,
+		nonce:       nonce,
+		verifier:    verifier,
+		redirectURI: s.oidcRedirectURI(r),
+		next:        safeNext(r.URL.Query().Get("next")),
+	}
+	authURL, err := s.oidc.AuthCodeURL(r.Context(), flow.redirectURI, state, nonce, verifier)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "oidc discovery", "error", err)
+		s.renderErrorPage(w, r, http.StatusBadGateway, "Single sign-on", "The identity provider is unavailable. Please try again later.")
+		return
+	}
+
+	token := newToken()
+	s.oidcFlows.begin(token, flow)
+	// 回调是身份提供方发起的跨站跳转，cookie 需为 Lax 才会随之发送。
+	http.SetCookie(w, &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    token,
+		Path:     "/login/oidc",
+		HttpOnly: true,
+		Secure:   s.secureCookie(r),
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   int(oidcFlowTimeout / time.Second),
+	})
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// oidcCallback 校验 state，用授权码换取 ID Token，通过白名单后建立会话。
+func (s *Server) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	if s.oidc == nil {
+		http.NotFound(w, r)
+		return
+	}
+	var flow oidcFlow
+	var ok bool
+	if cookie, err := r.Cookie(oidcCookieName); err == nil {
+		flow, ok = s.oidcFlows.take(cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: oidcCookieName, Path: "/login/oidc", MaxAge: -1, HttpOnly: true, Secure: s.secureCookie(r), SameSite: http.SameSiteLaxMode})
+
+	q := r.URL.Query()
+	if !ok || subtle.ConstantTimeCompare([]byte(q.Get("state")), []byte(flow.state)) != 1 {
+		s.renderErrorPage(w, r, http.StatusBadRequest, "Single sign-on", "The sign-in request expired or did not start here. Please try again.")
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		slog.WarnContext(r.Context(), "oidc authorization denied", "error", e)
+		s.renderErrorPage(w, r, http.StatusUnauthorized, "Single sign-on", "The identity provider did not approve the sign-in.")
+		return
+	}
+
+	claims, err := s.oidc.Exchange(r.Context(), flow.redirectURI, q.Get("code"), flow.verifier, flow.nonce)
+	if err != nil {
+		slog.WarnContext(r.Context(), "oidc login failed", "error", err)
+		s.metrics.loginFailures.Inc()
+		s.audit(r, audit.Event{Action: audit.LoginFailure, Detail: "oidc"})
+		s.renderErrorPage(w, r, http.StatusUnauthorized, "Single sign-on", "Sign-in could not be verified. Please try again.")
+		return
+	}
+	if !s.oidcAllowed(claims) {
+		slog.WarnContext(r.Context(), "oidc login not allowed", "sub", claims.Subject, "email", claims.Email)
+		s.metrics.loginFailures.Inc()
+		s.audit(r, audit.Event{Action: audit.LoginFailure, Actor: oidcActor(claims), Detail: "oidc: not allowed"})
+		s.renderErrorPage(w, r, http.StatusForbidden, "Single sign-on", "Your account is not allowed to access this site.")
+		return
+	}
+
+	// 身份提供方自身维持登录态，重新登录几乎无感，因此只建立普通会话。
+	actor := oidcActor(claims)
+	s.setSessionAs(w, r, false, actor)
+	s.audit(r, audit.Event{Action: audit.LoginSuccess, Actor: actor, Detail: "oidc"})
+	slog.InfoContext(r.Context(), "oidc login", "sub", claims.Subject, "email", claims.Email)
+	// 会话 cookie 为 SameSite=Strict，跨站跳转链中的下一次请求不会携带它；
+	// 由本站页面再发起一次导航，保证落地页能读到会话。
+	s.renderTemplate(w, r, "login.tmpl", map[string]any{
+		"Title":    "Signed in",
+		"Step":     "continue",
+		"Continue": flow.next,
+	})
+}
+
+// oidcAllowed 按邮箱或分组白名单授权。邮箱比较不区分大小写，且不接受明确未验证的邮箱。
+func (s *Server) oidcAllowed(c *oidc.Claims) bool {
+	if c.Email != "" && (c.EmailVerified == nil || *c.EmailVerified) {
+		for _, e := range s.cfg.OIDCAllowedEmails {
+			if strings.EqualFold(e, c.Email) {
+				return true
+			}
+		}
+	}
+	for _, g := range c.Groups {
+		for _, allowed := range s.cfg.OIDCAllowedGroups {
+			if g == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// oidcActor 为审计记录中的单点登录身份：优先使用邮箱，否则使用 subject。
+func oidcActor(c *oidc.Claims) string {
+	if c.Email != "" {
+		return "oidc:" + strings.ToLower(c.Email)
+	}
+	return "oidc:" + c.Subject
+}
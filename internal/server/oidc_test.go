@@ -0,0 +1,191 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+	"minisnap/internal/oidc/oidctest"
+)
+
+func newOIDCTestServer(t *testing.T, p *oidctest.Provider, emails, groups []string) *Server {
+	t.Helper()
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{
+		AdminPassword:     "testpass",
+		OIDCIssuer:        p.Issuer,
+		OIDCClientID:      p.ClientID,
+		OIDCClientSecret:  p.ClientSecret,
+		OIDCAllowedEmails: emails,
+		OIDCAllowedGroups: groups,
+	}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	return srv
+}
+
+// oidcRoundTrip 从 /login/oidc 出发，经替身提供方自动批准后返回回调响应。
+func oidcRoundTrip(t *testing.T, srv *Server, next string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login/oidc?next="+url.QueryEscape(next), nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("start: status %d", w.Code)
+	}
+	var flowCookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcCookieName {
+			flowCookie = c
+		}
+	}
+	if flowCookie == nil || flowCookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("expected lax %s cookie", oidcCookieName)
+	}
+	callback := followProvider(t, w.Header().Get("Location"))
+
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	req.AddCookie(flowCookie)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func followProvider(t *testing.T, authURL string) *url.URL {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	resp.Body.Close()
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || callback.Path != "/login/oidc/callback" {
+		t.Fatalf("unexpected provider redirect %q", resp.Header.Get("Location"))
+	}
+	return callback
+}
+
+func TestOIDCLogin(t *testing.T) {
+	p := oidctest.New("minisnap", "secret")
+	defer p.Close()
+	p.Claims["email"] = "Alice@Example.com"
+	srv := newOIDCTestServer(t, p, []string{"alice@example.com"}, nil)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if !strings.Contains(w.Body.String(), `href="/login/oidc?next=`) {
+		t.Fatalf("expected SSO link on login page")
+	}
+
+	w = oidcRoundTrip(t, srv, "/admin/library")
+	if w.Code != http.StatusOK || !hasCookie(w, sessionCookieName) {
+		t.Fatalf("callback: status %d, session %v", w.Code, hasCookie(w, sessionCookieName))
+	}
+	if !strings.Contains(w.Body.String(), `url=/admin/library`) {
+		t.Fatalf("expected continue redirect to next, got %s", w.Body.String())
+	}
+}
+
+func TestOIDCAllowlist(t *testing.T) {
+	p := oidctest.New("minisnap", "secret")
+	defer p.Close()
+	srv := newOIDCTestServer(t, p, []string{"alice@example.com"}, []string{"editors"})
+
+	cases := []struct {
+		name   string
+		claims map[string]any
+		want   int
+	}{
+		{"other email", map[string]any{"email": "mallory@example.com"}, http.StatusForbidden},
+		{"unverified email", map[string]any{"email": "alice@example.com", "email_verified": false}, http.StatusForbidden},
+		{"allowed group", map[string]any{"email": "bob@example.com", "groups": []string{"staff", "editors"}}, http.StatusOK},
+		{"other group", map[string]any{"groups": []string{"staff"}}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		p.Claims = tc.claims
+		w := oidcRoundTrip(t, srv, "")
+		if w.Code != tc.want || hasCookie(w, sessionCookieName) != (tc.want == http.StatusOK) {
+			t.Errorf("%s: status %d, session %v", tc.name, w.Code, hasCookie(w, sessionCookieName))
+		}
+	}
+}
+
+func TestOIDCCallbackRequiresMatchingFlow(t *testing.T) {
+	p := oidctest.New("minisnap", "secret")
+	defer p.Close()
+	p.Claims["email"] = "alice@example.com"
+	srv := newOIDCTestServer(t, p, []string{"alice@example.com"}, nil)
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login/oidc", nil))
+	flowCookie := w.Result().Cookies()[0]
+	callback := followProvider(t, w.Header().Get("Location"))
+
+	// 没有发起登录的浏览器不能完成回调（防登录 CSRF）
+	req := httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || hasCookie(w, sessionCookieName) {
+		t.Fatalf("missing cookie: status %d", w.Code)
+	}
+
+	// state 不符
+	q := callback.Query()
+	q.Set("state", "forged")
+	req = httptest.NewRequest(http.MethodGet, "/login/oidc/callback?"+q.Encode(), nil)
+	req.AddCookie(flowCookie)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest || hasCookie(w, sessionCookieName) {
+		t.Fatalf("forged state: status %d", w.Code)
+	}
+
+	// 流程已被取用，原回调也不能再用
+	req = httptest.NewRequest(http.MethodGet, callback.RequestURI(), nil)
+	req.AddCookie(flowCookie)
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("consumed flow: status %d", w.Code)
+	}
+}
+
+func TestOIDCProviderErrors(t *testing.T) {
+	p := oidctest.New("minisnap", "secret")
+	p.Deny = "access_denied"
+	srv := newOIDCTestServer(t, p, []string{"alice@example.com"}, nil)
+	if w := oidcRoundTrip(t, srv, ""); w.Code != http.StatusUnauthorized || hasCookie(w, sessionCookieName) {
+		t.Fatalf("denied: status %d", w.Code)
+	}
+
+	// 身份提供方不可达时给出提示，而不是跳转
+	p.Close()
+	down := newOIDCTestServer(t, p, []string{"alice@example.com"}, nil)
+	w := httptest.NewRecorder()
+	down.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login/oidc", nil))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("provider down: status %d", w.Code)
+	}
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if strings.Contains(w.Body.String(), "/login/oidc") {
+		t.Fatalf("SSO link shown without OIDC configured")
+	}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login/oidc", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,342 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/webauthn"
+)
+
+const (
+	passkeyCookieName = "minisnap_passkey"
+	// maxPasskeyBody 限制通行密钥 JSON 请求体大小。
+	maxPasskeyBody = 64 << 10
+	// passkeyUserID 是单一后台账号在认证器中的稳定用户句柄。
+	passkeyUserID = "minisnap-admin"
+)
+
+// ceremony 是一次进行中的 WebAuthn 注册或登录。
+type ceremony struct {
+	challenge []byte
+	next      string
+	remember  bool
+	expires   time.Time
+}
+
+// ceremonies 保存待完成的挑战，每个挑战只能取用一次。
+type ceremonies struct {
+	mu      sync.Mutex
+	pending map[string]ceremony
+}
+
+func newCeremonies() *ceremonies {
+	return &ceremonies{pending: make(map[string]ceremony)}
+}
+
+func (c *ceremonies) begin(key string, p ceremony) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, old := range c.pending {
+		if now.After(old.expires) {
+			delete(c.pending, k)
+		}
+	}
+	p.expires = now.Add(webauthn.ChallengeTimeout)
+	c.pending[key] = p
+}
+
+func (c *ceremonies) take(key string) (ceremony, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pending[key]
+	delete(c.pending, key)
+	if !ok || time.Now().After(p.expires) {
+		return ceremony{}, false
+	}
+	return p, true
+}
+
+// relyingParty 返回本站的依赖方信息。未配置 WEBAUTHN_ORIGIN 时按请求推断来源。
+func (s *Server) relyingParty(r *http.Request) webauthn.RelyingParty {
+	origin := s.cfg.WebAuthnOrigin
+	if origin == "" {
+		origin = s.requestOrigin(r)
+	}
+	id := origin
+	if u, err := url.Parse(origin); err == nil {
+		id = u.Hostname()
+	}
+	return webauthn.RelyingParty{ID: id, Name: "minisnap", Origin: origin}
+}
+
+// binaryField 接收前端以 base64url 编码的二进制字段。
+type binaryField []byte
+
+func (b *binaryField) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = raw
+	return nil
+}
+
+type passkeyResponse struct {
+	Name              string      `json:"name"`
+	ID                binaryField `json:"id"`
+	ClientDataJSON    binaryField `json:"clientDataJSON"`
+	AttestationObject binaryField `json:"attestationObject"`
+	AuthenticatorData binaryField `json:"authenticatorData"`
+	Signature         binaryField `json:"signature"`
+}
+
+func decodePasskeyResponse(w http.ResponseWriter, r *http.Request) (passkeyResponse, error) {
+	var resp passkeyResponse
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxPasskeyBody)).Decode(&resp)
+	return resp, err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func jsonError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// hasPasskeys 报告是否已注册通行密钥，决定登录页是否显示通行密钥入口。
+func (s *Server) hasPasskeys() bool {
+	creds, err := s.passkeys.List()
+	return err == nil && len(creds) > 0
+}
+
+// passkeyLoginOptions 发起通行密钥登录，挑战与 next 绑定到一次性 cookie。
+func (s *Server) passkeyLoginOptions(w http.ResponseWriter, r *http.Request) {
+	creds, err := s.passkeys.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list passkeys", "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to load passkeys")
+		return
+	}
+	if len(creds) == 0 {
+		jsonError(w, http.StatusNotFound, "No passkeys registered")
+		return
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, "Failed to start passkey login")
+		return
+	}
+
+	token := newToken()
+	s.ceremonies.begin("login:"+token, ceremony{
+		challenge: challenge,
+		next:      safeNext(r.URL.Query().Get("next")),
+		remember:  r.URL.Query().Get("remember") != "",
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     passkeyCookieName,
+		Value:    token,
+		Path:     "/login/passkey",
+		HttpOnly: true,
+		Secure:   s.secureCookie(r),
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(webauthn.ChallengeTimeout / time.Second),
+	})
+	writeJSON(w, http.StatusOK, map[string]any{"publicKey": s.relyingParty(r).RequestOptions(challenge, creds)})
+}
+
+// passkeyLogin 校验断言，成功后建立会话。通行密钥要求用户验证，本身即满足多因素。
+func (s *Server) passkeyLogin(w http.ResponseWriter, r *http.Request) {
+	clientIP := s.clientIP(r)
+	// 不检查全局上限：通行密钥无法被猜测，全局暂停期间管理员仍可借此登录。
+	if s.loginLim.isLocked(clientIP, time.Now()) {
+		jsonError(w, http.StatusTooManyRequests, "Too many failed attempts, please try again later")
+		return
+	}
+
+	var pending ceremony
+	var ok bool
+	if cookie, err := r.Cookie(passkeyCookieName); err == nil {
+		pending, ok = s.ceremonies.take("login:" + cookie.Value)
+	}
+	http.SetCookie(w, &http.Cookie{Name: passkeyCookieName, Path: "/login/passkey", MaxAge: -1, HttpOnly: true, Secure: s.secureCookie(r), SameSite: http.SameSiteStrictMode})
+	if !ok {
+		jsonError(w, http.StatusUnauthorized, "Passkey login expired, please try again")
+		return
+	}
+
+	fail := func(msg string, err error) {
+		slog.WarnContext(r.Context(), "passkey login failed", "error", err)
+		s.loginFailed(r, "passkey")
+		jsonError(w, http.StatusUnauthorized, msg)
+	}
+
+	resp, err := decodePasskeyResponse(w, r)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid passkey response")
+		return
+	}
+	cred, err := s.passkeys.Get(resp.ID)
+	if err != nil {
+		fail("Unknown passkey", err)
+		return
+	}
+	count, err := s.relyingParty(r).VerifyAssertion(pending.challenge, cred, resp.ClientDataJSON, resp.AuthenticatorData, resp.Signature)
+	if err != nil {
+		fail("Passkey verification failed", err)
+		return
+	}
+	if err := s.passkeys.Touch(cred.ID, count, time.Now()); err != nil {
+		slog.ErrorContext(r.Context(), "update passkey", "error", err)
+	}
+
+	s.loginLim.recordSuccess(clientIP)
+	s.setSession(w, r, pending.remember)
+	s.audit(r, audit.Event{Action: audit.LoginSuccess, Actor: adminUser, Detail: "passkey " + cred.Name})
+	slog.InfoContext(r.Context(), "passkey login", "passkey", cred.Name)
+	writeJSON(w, http.StatusOK, map[string]string{"redirect": pending.next})
+}
+
+type passkeysTemplateData struct {
+	Title     string
+	Passkeys  []passkeyItem
+	Notice    string
+	CSRFToken string
+}
+
+type passkeyItem struct {
+	ID        string
+	Name      string
+	CreatedAt string
+	LastUsed  string
+}
+
+// showPasskeys 列出已注册的通行密钥。
+func (s *Server) showPasskeys(w http.ResponseWriter, r *http.Request) {
+	creds, err := s.passkeys.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list passkeys", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Passkeys", "Failed to load passkeys.")
+		return
+	}
+	items := make([]passkeyItem, 0, len(creds))
+	for _, c := range creds {
+		item := passkeyItem{
+			ID:        c.EncodedID(),
+			Name:      c.Name,
+			CreatedAt: c.CreatedAt.Local().Format("2006-01-02 15:04"),
+			LastUsed:  "—",
+		}
+		if !c.LastUsedAt.IsZero() {
+			item.LastUsed = c.LastUsedAt.Local().Format("2006-01-02 15:04")
+		}
+		items = append(items, item)
+	}
+	var notice string
+	if r.URL.Query().Get("removed") != "" {
+		notice = "Passkey removed."
+	}
+	s.renderTemplate(w, r, "passkeys.tmpl", passkeysTemplateData{
+		Title:     "Passkeys",
+		Passkeys:  items,
+		Notice:    notice,
+		CSRFToken: s.csrfToken(r),
+	})
+}
+
+// passkeyRegisterOptions 为当前会话发起通行密钥注册。
+func (s *Server) passkeyRegisterOptions(w http.ResponseWriter, r *http.Request) {
+	token, _ := s.authenticated(r)
+	creds, err := s.passkeys.List()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list passkeys", "error", err)
+		jsonError(w, http.StatusInternalServerError, "Failed to load passkeys")
+		return
+	}
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		jsonError(w, http.StatusInternalServerError, "Failed to start registration")
+		return
+	}
+	s.ceremonies.begin("register:"+token, ceremony{challenge: challenge})
+	opts := s.relyingParty(r).CreationOptions(challenge, []byte(passkeyUserID), adminUser, creds)
+	writeJSON(w, http.StatusOK, map[string]any{"publicKey": opts})
+}
+
+// registerPasskey 校验注册响应并保存新凭据。
+func (s *Server) registerPasskey(w http.ResponseWriter, r *http.Request) {
+	token, _ := s.authenticated(r)
+	pending, ok := s.ceremonies.take("register:" + token)
+	if !ok {
+		jsonError(w, http.StatusBadRequest, "Registration expired, please try again")
+		return
+	}
+	resp, err := decodePasskeyResponse(w, r)
+	if err != nil {
+		jsonError(w, http.StatusBadRequest, "Invalid passkey response")
+		return
+	}
+	cred, err := s.relyingParty(r).VerifyRegistration(pending.challenge, resp.ClientDataJSON, resp.AttestationObject)
+	if err != nil {
+		slog.WarnContext(r.Context(), "passkey registration failed", "error", err)
+		jsonError(w, http.StatusBadRequest, "Passkey verification failed")
+		return
+	}
+	cred.Name = strings.TrimSpace(resp.Name)
+	if cred.Name == "" {
+		cred.Name = "Passkey"
+	}
+	if len([]rune(cred.Name)) > 64 {
+		cred.Name = string([]rune(cred.Name)[:64])
+	}
+	if err := s.passkeys.Add(*cred); err != nil {
+		slog.ErrorContext(r.Context(), "save passkey", "error", err)
+		jsonError(w, http.StatusConflict, "Failed to save passkey")
+		return
+	}
+	slog.InfoContext(r.Context(), "passkey registered", "passkey", cred.Name)
+	s.audit(r, audit.Event{Action: audit.PasskeyAdd, Detail: cred.Name})
+	writeJSON(w, http.StatusCreated, map[string]string{"id": cred.EncodedID(), "name": cred.Name})
+}
+
+// deletePasskey 删除一个通行密钥。
+func (s *Server) deletePasskey(w http.ResponseWriter, r *http.Request) {
+	id, err := webauthn.DecodeID(r.PathValue("id"))
+	if err != nil {
+		s.renderErrorPage(w, r, http.StatusBadRequest, "Passkeys", "Invalid passkey id.")
+		return
+	}
+	var name string
+	if cred, err := s.passkeys.Get(id); err == nil {
+		name = cred.Name
+	}
+	if err := s.passkeys.Remove(id); err != nil {
+		if errors.Is(err, webauthn.ErrNotFound) {
+			s.renderErrorPage(w, r, http.StatusNotFound, "Passkeys", "Passkey not found.")
+			return
+		}
+		slog.ErrorContext(r.Context(), "remove passkey", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Passkeys", "Failed to remove passkey.")
+		return
+	}
+	slog.InfoContext(r.Context(), "passkey removed")
+	s.audit(r, audit.Event{Action: audit.PasskeyDelete, Detail: name})
+	http.Redirect(w, r, "/admin/passkeys?removed=1", http.StatusSeeOther)
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"minisnap/internal/webauthn/webauthntest"
+)
+
+var b64url = base64.RawURLEncoding
+
+// postJSON 发送 JSON 请求并解码响应体。
+func postJSON(t *testing.T, srv *Server, path string, body any, csrf string, cookies ...*http.Cookie) (*httptest.ResponseRecorder, map[string]any) {
+	t.Helper()
+	var raw []byte
+	if body != nil {
+		var err error
+		if raw, err = json.Marshal(body); err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+	}
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(raw))
+	req.Header.Set("Content-Type", "application/json")
+	if csrf != "" {
+		req.Header.Set(csrfHeaderName, csrf)
+	}
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	var out map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &out)
+	return w, out
+}
+
+func optionsChallenge(t *testing.T, out map[string]any) []byte {
+	t.Helper()
+	pk, _ := out["publicKey"].(map[string]any)
+	challenge, err := b64url.DecodeString(pk["challenge"].(string))
+	if err != nil {
+		t.Fatalf("decode challenge: %v", err)
+	}
+	return challenge
+}
+
+// registerTestPasskey 通过后台接口为软件认证器注册通行密钥。
+func registerTestPasskey(t *testing.T, srv *Server, name string) *webauthntest.Authenticator {
+	t.Helper()
+	cookie, csrf := newTestSession(t, srv)
+	auth := webauthntest.New("example.com", "http://example.com")
+
+	w, out := postJSON(t, srv, "/admin/passkeys/options", nil, csrf, cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("register options: status %d: %s", w.Code, w.Body.String())
+	}
+	clientData, attestation := auth.Register(optionsChallenge(t, out))
+	w, _ = postJSON(t, srv, "/admin/passkeys", map[string]string{
+		"name":              name,
+		"id":                b64url.EncodeToString(auth.CredentialID),
+		"clientDataJSON":    b64url.EncodeToString(clientData),
+		"attestationObject": b64url.EncodeToString(attestation),
+	}, csrf, cookie)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("register: status %d: %s", w.Code, w.Body.String())
+	}
+	return auth
+}
+
+func passkeyLoginOptions(t *testing.T, srv *Server, next string) ([]byte, *http.Cookie) {
+	t.Helper()
+	w, out := postJSON(t, srv, "/login/passkey/options?next="+url.QueryEscape(next), nil, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("login options: status %d: %s", w.Code, w.Body.String())
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == passkeyCookieName {
+			return optionsChallenge(t, out), c
+		}
+	}
+	t.Fatalf("expected %s cookie", passkeyCookieName)
+	return nil, nil
+}
+
+func assertion(auth *webauthntest.Authenticator, challenge []byte) map[string]string {
+	clientData, authData, sig := auth.Assert(challenge)
+	return map[string]string{
+		"id":                b64url.EncodeToString(auth.CredentialID),
+		"clientDataJSON":    b64url.EncodeToString(clientData),
+		"authenticatorData": b64url.EncodeToString(authData),
+		"signature":         b64url.EncodeToString(sig),
+	}
+}
+
+func TestPasskeyRegisterAndLogin(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+
+	// 未注册时登录页不显示通行密钥入口
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if strings.Contains(w.Body.String(), "data-passkey-login") {
+		t.Fatalf("passkey button shown without registered passkeys")
+	}
+
+	auth := registerTestPasskey(t, srv, "Laptop")
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if !strings.Contains(w.Body.String(), "data-passkey-login") {
+		t.Fatalf("expected passkey button on login page")
+	}
+
+	challenge, cookie := passkeyLoginOptions(t, srv, "/admin/library")
+	w, out := postJSON(t, srv, "/login/passkey", assertion(auth, challenge), "", cookie)
+	if w.Code != http.StatusOK || out["redirect"] != "/admin/library" {
+		t.Fatalf("login: status %d, body %s", w.Code, w.Body.String())
+	}
+	if !hasCookie(w, sessionCookieName) {
+		t.Fatalf("expected session cookie after passkey login")
+	}
+
+	creds, err := srv.passkeys.List()
+	if err != nil || len(creds) != 1 {
+		t.Fatalf("list passkeys: %v, %d", err, len(creds))
+	}
+	if creds[0].Name != "Laptop" || creds[0].SignCount != auth.SignCount || creds[0].LastUsedAt.IsZero() {
+		t.Fatalf("unexpected stored credential: %+v", creds[0])
+	}
+}
+
+func TestPasskeyLoginRejectsReplayAndForeignChallenge(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	auth := registerTestPasskey(t, srv, "Key")
+
+	// 没有登录 cookie 的断言无法对应挑战
+	challenge, cookie := passkeyLoginOptions(t, srv, "")
+	if w, _ := postJSON(t, srv, "/login/passkey", assertion(auth, challenge), ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("missing cookie: status %d", w.Code)
+	}
+	// 挑战只能使用一次
+	if w, _ := postJSON(t, srv, "/login/passkey", assertion(auth, challenge), "", cookie); w.Code != http.StatusOK {
+		t.Fatalf("first use: status %d", w.Code)
+	}
+	if w, _ := postJSON(t, srv, "/login/passkey", assertion(auth, challenge), "", cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused challenge: status %d", w.Code)
+	}
+
+	// 为另一个挑战签名的断言被拒绝
+	_, cookie = passkeyLoginOptions(t, srv, "")
+	other := []byte("0123456789abcdef0123456789abcdef")
+	w, _ := postJSON(t, srv, "/login/passkey", assertion(auth, other), "", cookie)
+	if w.Code != http.StatusUnauthorized || hasCookie(w, sessionCookieName) {
+		t.Fatalf("wrong challenge: status %d", w.Code)
+	}
+
+	// 未经用户验证的断言被拒绝
+	auth.Flags = 0x01
+	challenge, cookie = passkeyLoginOptions(t, srv, "")
+	if w, _ := postJSON(t, srv, "/login/passkey", assertion(auth, challenge), "", cookie); w.Code != http.StatusUnauthorized {
+		t.Fatalf("no user verification: status %d", w.Code)
+	}
+}
+
+func TestPasskeyNextIsSanitized(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	auth := registerTestPasskey(t, srv, "Key")
+	challenge, cookie := passkeyLoginOptions(t, srv, "//evil.example/")
+	_, out := postJSON(t, srv, "/login/passkey", assertion(auth, challenge), "", cookie)
+	if out["redirect"] != "/admin" {
+		t.Fatalf("redirect = %v, want /admin", out["redirect"])
+	}
+}
+
+func TestPasskeyRegistrationRequiresAuthAndCSRF(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, _ := newTestSession(t, srv)
+
+	if w, _ := postJSON(t, srv, "/admin/passkeys/options", nil, ""); w.Code != http.StatusSeeOther && w.Code != http.StatusFound && w.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated options: status %d", w.Code)
+	}
+	if w, _ := postJSON(t, srv, "/admin/passkeys/options", nil, "bogus", cookie); w.Code != http.StatusForbidden {
+		t.Fatalf("bad csrf: status %d", w.Code)
+	}
+}
+
+func TestPasskeyDelete(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	registerTestPasskey(t, srv, "Old key")
+	cookie, csrf := newTestSession(t, srv)
+
+	creds, _ := srv.passkeys.List()
+	id := creds[0].EncodedID()
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/passkeys", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Old key") || !strings.Contains(w.Body.String(), id) {
+		t.Fatalf("passkeys page: status %d", w.Code)
+	}
+
+	w = postForm(srv, "/admin/passkeys/"+id+"/delete", url.Values{"csrf_token": {csrf}}, cookie, "")
+	if w.Code != http.StatusSeeOther {
+		t.Fatalf("delete: status %d", w.Code)
+	}
+	if creds, _ := srv.passkeys.List(); len(creds) != 0 {
+		t.Fatalf("expected passkey removed, got %d", len(creds))
+	}
+	w = postForm(srv, "/admin/passkeys/"+id+"/delete", url.Values{"csrf_token": {csrf}}, cookie, "")
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("delete missing: status %d", w.Code)
+	}
+}
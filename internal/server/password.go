@@ -0,0 +1,125 @@
+package server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/config"
+	"minisnap/internal/password"
+)
+
+// adminCredential 保存当前有效的后台密码。后台修改密码后新哈希写入内容目录，
+// 此后优先于环境变量中的 ADMIN_PASSWORD_HASH / ADMIN_PASSWORD。
+type adminCredential struct {
+	mu    sync.RWMutex
+	path  string
+	hash  string // 非空时按哈希校验
+	plain string // 仅在未配置任何哈希时使用
+}
+
+func loadAdminCredential(cfg config.Config, path string) (*adminCredential, error) {
+	c := &adminCredential{path: path, hash: cfg.AdminPasswordHash, plain: cfg.AdminPassword}
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		stored := strings.TrimSpace(string(raw))
+		if err := password.Check(stored); err != nil {
+			return nil, fmt.Errorf("stored password hash %s: %w", path, err)
+		}
+		c.hash = stored
+	case !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("read stored password hash: %w", err)
+	}
+	return c, nil
+}
+
+// verify 校验密码；明文配置使用恒定时间比较，避免基于响应时间的侧信道。
+func (c *adminCredential) verify(pw string) bool {
+	c.mu.RLock()
+	hash, plain := c.hash, c.plain
+	c.mu.RUnlock()
+	if hash == "" {
+		return subtle.ConstantTimeCompare([]byte(pw), []byte(plain)) == 1
+	}
+	ok, err := password.Verify(hash, pw)
+	if err != nil {
+		slog.Error("verify password hash", "error", err)
+	}
+	return ok
+}
+
+// set 哈希新密码并持久化到内容目录。
+func (c *adminCredential) set(pw string) error {
+	hash, err := password.Hash(pw)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o700); err != nil {
+		return fmt.Errorf("create auth dir: %w", err)
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(hash+"\n"), 0o600); err != nil {
+		return fmt.Errorf("write password hash: %w", err)
+	}
+	if err := os.Rename(tmp, c.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write password hash: %w", err)
+	}
+	c.mu.Lock()
+	c.hash = hash
+	c.mu.Unlock()
+	return nil
+}
+
+// changePassword 校验当前密码后设置新密码，并注销包括当前会话在内的所有会话。
+func (s *Server) changePassword(w http.ResponseWriter, r *http.Request) {
+	clientIP := s.clientIP(r)
+	if now := time.Now(); s.loginLim.isLocked(clientIP, now) {
+		s.renderSecurity(w, r, http.StatusTooManyRequests, securityTemplateData{PasswordError: "Too many failed attempts, please try again later"})
+		return
+	}
+
+	current := r.FormValue("current_password")
+	next := r.FormValue("new_password")
+	if !s.credential.verify(current) {
+		// 计入登录失败，防止借助被盗会话暴力猜测当前密码
+		s.loginFailed(r, "password change")
+		s.renderSecurity(w, r, http.StatusUnprocessableEntity, securityTemplateData{PasswordError: "Current password is incorrect"})
+		return
+	}
+
+	var problem string
+	switch {
+	case len([]rune(next)) < password.MinLength:
+		problem = fmt.Sprintf("New password must be at least %d characters", password.MinLength)
+	case next != r.FormValue("confirm_password"):
+		problem = "New passwords do not match"
+	case next == current:
+		problem = "New password must differ from the current one"
+	}
+	if problem != "" {
+		s.renderSecurity(w, r, http.StatusUnprocessableEntity, securityTemplateData{PasswordError: problem})
+		return
+	}
+
+	if err := s.credential.set(next); err != nil {
+		slog.ErrorContext(r.Context(), "change password", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Security", "Failed to save the new password.")
+		return
+	}
+	// 先记录再注销，审计记录才能带上当前会话的操作者
+	s.audit(r, audit.Event{Action: audit.PasswordChange})
+	n := s.sessions.RemoveAll()
+	s.clearSession(w, r, "")
+	slog.InfoContext(r.Context(), "admin password changed", "sessions_revoked", n)
+	http.Redirect(w, r, "/login?changed=1", http.StatusSeeOther)
+}
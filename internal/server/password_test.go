@@ -0,0 +1,79 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+	"minisnap/internal/password"
+)
+
+func TestLoginWithPasswordHash(t *testing.T) {
+	hash, err := password.Hash("hashed-secret")
+	if err != nil {
+		t.Fatalf("hash: %v", err)
+	}
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPasswordHash: hash}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	if w := postForm(srv, "/login", url.Values{"password": {hash}}, &http.Cookie{Name: "x", Value: "y"}, ""); hasCookie(w, sessionCookieName) {
+		t.Fatalf("hash itself must not be accepted as password: status %d", w.Code)
+	}
+	loginWith(t, srv, url.Values{"password": {"hashed-secret"}}, "test")
+}
+
+func TestChangePassword(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	current := loginWith(t, srv, url.Values{"password": {"testpass"}}, "a")
+	other := loginWith(t, srv, url.Values{"password": {"testpass"}}, "b")
+	csrf := srv.sessions.CSRFToken(current.Value)
+
+	for name, form := range map[string]url.Values{
+		"wrong current": {"current_password": {"nope"}, "new_password": {"new-password-1"}, "confirm_password": {"new-password-1"}},
+		"too short":     {"current_password": {"testpass"}, "new_password": {"short"}, "confirm_password": {"short"}},
+		"mismatch":      {"current_password": {"testpass"}, "new_password": {"new-password-1"}, "confirm_password": {"new-password-2"}},
+	} {
+		form.Set(csrfFieldName, csrf)
+		w := postForm(srv, "/admin/security/password", form, current, "")
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `class="error"`) {
+			t.Fatalf("%s: status %d", name, w.Code)
+		}
+	}
+
+	form := url.Values{
+		csrfFieldName:      {csrf},
+		"current_password": {"testpass"},
+		"new_password":     {"new-password-1"},
+		"confirm_password": {"new-password-1"},
+	}
+	w := postForm(srv, "/admin/security/password", form, current, "")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?changed=1" {
+		t.Fatalf("change: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	// 所有会话（包括当前会话）都被注销
+	for _, c := range []*http.Cookie{current, other} {
+		if w := getWithCookie(srv, "/admin", c); w.Code != http.StatusFound {
+			t.Fatalf("session survived password change: status %d", w.Code)
+		}
+	}
+	if w := postForm(srv, "/login", url.Values{"password": {"testpass"}}, &http.Cookie{Name: "x", Value: "y"}, ""); hasCookie(w, sessionCookieName) {
+		t.Fatalf("old password still accepted: status %d", w.Code)
+	}
+	loginWith(t, srv, url.Values{"password": {"new-password-1"}}, "a")
+
+	// 新密码持久化到内容目录，重启后覆盖环境变量中的密码
+	restarted, err := New(config.Config{AdminPassword: "testpass"}, srv.store, "../../templates")
+	if err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+	loginWith(t, restarted, url.Values{"password": {"new-password-1"}}, "a")
+}
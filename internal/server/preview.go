@@ -0,0 +1,59 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"minisnap/internal/content"
+)
+
+// previewFrameRoute 为编辑器实时预览框的路由，其 CSP 允许被本站页面嵌入。
+const previewFrameRoute = "GET /admin/preview/frame"
+
+// previewResult 是实时预览接口的 JSON 响应，HTML 为已消毒的渲染结果。
+type previewResult struct {
+	HTML string `json:"html"`
+}
+
+// renderPreview 渲染编辑器中尚未保存的内容并以 JSON 返回，供分栏预览在输入停顿后刷新。
+// 与 POST /admin/preview 使用同一套渲染与消毒流程，请求体同样为表单编码。
+func (s *Server) renderPreview(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		if isTooLarge(err) {
+			jsonError(w, http.StatusRequestEntityTooLarge, s.tooLargeMessage(r))
+			return
+		}
+		jsonError(w, http.StatusBadRequest, "Invalid form data.")
+		return
+	}
+
+	renderer := content.RendererType(r.FormValue("renderer"))
+	if renderer != content.RendererMarkdown && renderer != content.RendererHTML {
+		jsonError(w, http.StatusBadRequest, "Invalid renderer type.")
+		return
+	}
+	raw := r.FormValue("content")
+	if int64(len(raw)) > s.maxEntrySize() {
+		jsonError(w, http.StatusRequestEntityTooLarge, entryTooLargeMessage(s.maxEntrySize()))
+		return
+	}
+
+	html, err := s.render(content.Entry{Renderer: renderer, Raw: raw})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "render live preview", "error", err)
+		jsonError(w, http.StatusInternalServerError, "Render failed.")
+		return
+	}
+	writeJSON(w, http.StatusOK, previewResult{HTML: string(html)})
+}
+
+// previewFrame 输出实时预览框的空白文档。编辑页以同源、禁止脚本的沙箱 iframe 加载它，
+// 再把 renderPreview 的结果写入其中，条目自带的样式因此不会影响编辑器本身。
+func (s *Server) previewFrame(w http.ResponseWriter, r *http.Request) {
+	// 该文档只供本站编辑页嵌入，由 CSP frame-ancestors 'self' 约束。
+	w.Header().Set("X-Frame-Options", "SAMEORIGIN")
+	w.Header().Set("Cache-Control", "no-store")
+	s.renderTemplate(w, r, "livepreview.tmpl", map[string]any{
+		"Title": "Live preview",
+	})
+}
@@ -1,6 +1,7 @@
 package server
 
 import (
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -67,7 +68,7 @@ func TestPreviewEntry(t *testing.T) {
 			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 			// Mock authentication by setting session
-			server.setSession(httptest.NewRecorder())
+			server.setSession(httptest.NewRecorder(), req, false)
 
 			// Create response recorder
 			w := httptest.NewRecorder()
@@ -97,3 +98,92 @@ func TestPreviewEntry(t *testing.T) {
 		})
 	}
 }
+
+func postPreview(srv *Server, form url.Values, cookie *http.Cookie, csrf string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/admin/preview/render", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(csrfHeaderName, csrf)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+// TestLivePreview 验证实时预览接口返回经消毒的 JSON 渲染结果，并以 JSON 报告错误。
+func TestLivePreview(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	w := postPreview(srv, url.Values{"renderer": {"markdown"}, "content": {"# Title\n<script>alert(1)</script>"}}, cookie, csrf)
+	var res previewResult
+	if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &res) != nil {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(res.HTML, "<h1") || strings.Contains(res.HTML, "<script") {
+		t.Fatalf("unexpected preview html: %s", res.HTML)
+	}
+
+	w = postPreview(srv, url.Values{"renderer": {"pdf"}, "content": {"x"}}, cookie, csrf)
+	var body map[string]string
+	if w.Code != http.StatusBadRequest || json.Unmarshal(w.Body.Bytes(), &body) != nil || body["error"] == "" {
+		t.Fatalf("invalid renderer: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	// 与其他后台写操作一样要求 CSRF token。
+	if w := postPreview(srv, url.Values{"renderer": {"markdown"}, "content": {"x"}}, cookie, ""); w.Code != http.StatusForbidden {
+		t.Fatalf("missing token: status %d, want 403", w.Code)
+	}
+}
+
+func TestLivePreviewSizeLimit(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass", MaxEntrySize: 16}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	cookie, csrf := newTestSession(t, srv)
+
+	w := postPreview(srv, url.Values{"renderer": {"markdown"}, "content": {strings.Repeat("a", 17)}}, cookie, csrf)
+	var body map[string]string
+	if w.Code != http.StatusRequestEntityTooLarge || json.Unmarshal(w.Body.Bytes(), &body) != nil || !strings.Contains(body["error"], "entry size limit") {
+		t.Fatalf("status %d, body %s", w.Code, w.Body.String())
+	}
+}
+
+// TestLivePreviewFrame 验证预览框只允许本站嵌入，编辑页以沙箱 iframe 加载它。
+func TestLivePreviewFrame(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie, _ := newTestSession(t, srv)
+
+	w := getWithCookie(srv, "/admin/preview/frame", cookie)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "data-preview-body") {
+		t.Fatalf("frame: status %d", w.Code)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "frame-ancestors 'self'") || !strings.Contains(csp, "img-src *") {
+		t.Errorf("frame CSP = %q", csp)
+	}
+	if got := w.Header().Get("X-Frame-Options"); got != "SAMEORIGIN" {
+		t.Errorf("X-Frame-Options = %q, want SAMEORIGIN", got)
+	}
+
+	// 未登录时与其他后台页面一样跳转登录。
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/preview/frame", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("anonymous frame: status %d, want 302", w.Code)
+	}
+
+	editor := getWithCookie(srv, "/admin", cookie).Body.String()
+	for _, want := range []string{`src="/admin/preview/frame"`, `sandbox="allow-same-origin allow-popups`, "data-layout-option=\"split\"", "preview."} {
+		if !strings.Contains(editor, want) {
+			t.Errorf("editor missing %q", want)
+		}
+	}
+	if strings.Contains(editor, "allow-scripts") {
+		t.Errorf("preview frame must not allow scripts")
+	}
+}
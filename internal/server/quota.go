@@ -0,0 +1,122 @@
+package server
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// usageTTL 为存储用量缓存的最长有效期。审计日志等状态文件写入时不会主动失效缓存，
+// 到期后重新统计。
+const usageTTL = time.Minute
+
+// nearQuotaPercent 为内容库提示“接近配额”的用量百分比。
+const nearQuotaPercent = 90
+
+// errQuotaExceeded 表示写入后内容目录将超出 StorageQuota。
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
+// storageUsage 统计内容目录占用的字节数并缓存结果；条目或附件变更后调用 invalidate。
+type storageUsage struct {
+	dir string
+
+	mu      sync.Mutex
+	bytes   int64
+	counted time.Time
+	stale   bool
+}
+
+func newStorageUsage(dir string) *storageUsage {
+	return &storageUsage{dir: dir, stale: true}
+}
+
+// Bytes 返回内容目录下所有普通文件的大小之和。
+func (u *storageUsage) Bytes() (int64, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.stale && time.Since(u.counted) < usageTTL {
+		return u.bytes, nil
+	}
+	var total int64
+	err := filepath.WalkDir(u.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 统计期间被删除的临时文件忽略即可。
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		total += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	u.bytes, u.counted, u.stale = total, time.Now(), false
+	return total, nil
+}
+
+func (u *storageUsage) invalidate() {
+	u.mu.Lock()
+	u.stale = true
+	u.mu.Unlock()
+}
+
+// storageWrites 是会改变内容目录占用的存储操作，完成后失效用量缓存。
+var storageWrites = map[string]bool{"create": true, "update": true, "delete": true, "restore": true, "purge": true, "publish": true, "autosave": true}
+
+// checkQuota 在写入 extra 字节前检查配额；extra 不为正（内容未增长）时总是放行。
+func (s *Server) checkQuota(extra int64) error {
+	if s.cfg.StorageQuota <= 0 || extra <= 0 {
+		return nil
+	}
+	used, err := s.usage.Bytes()
+	if err != nil {
+		return err
+	}
+	if used+extra > s.cfg.StorageQuota {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// quotaMessage 说明配额已满时的当前用量。
+func (s *Server) quotaMessage() string {
+	used, _ := s.usage.Bytes()
+	return "Storage quota exceeded: " + formatSize(used) + " of " + formatSize(s.cfg.StorageQuota) +
+		" used. Delete entries or empty the trash to free space."
+}
+
+// storageSummary 是内容库页头展示的存储用量。
+type storageSummary struct {
+	Used    string
+	Quota   string // 未设置配额时为空
+	Percent int
+	Near    bool
+}
+
+func (s *Server) storageSummary() (storageSummary, error) {
+	used, err := s.usage.Bytes()
+	if err != nil {
+		return storageSummary{}, err
+	}
+	sum := storageSummary{Used: formatSize(used)}
+	if quota := s.cfg.StorageQuota; quota > 0 {
+		sum.Quota = formatSize(quota)
+		sum.Percent = int(min(100, used*100/quota))
+		sum.Near = sum.Percent >= nearQuotaPercent
+	}
+	return sum, nil
+}
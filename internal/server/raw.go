@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime"
+	"net/http"
+
+	"minisnap/internal/content"
+)
+
+// loadPublicEntry 读取路径中的 slug 对应的条目，并套用与阅读页一致的访问控制。
+// 草稿发布前对所有人视为不存在。条目不可见时直接写出错误响应并返回 false。
+func (s *Server) loadPublicEntry(w http.ResponseWriter, r *http.Request) (content.Entry, bool) {
+	entry, err := s.store.Get(r.PathValue("slug"))
+	if err != nil || entry.Draft {
+		s.renderError(w, http.StatusNotFound, "Not Found")
+		return content.Entry{}, false
+	}
+	return entry, true
+}
+
+// rawEntry 以纯文本返回条目源码，便于 curl 或编辑器直接拉取。
+// 无论渲染器为何都按 text/plain 输出，避免原始 HTML 在本站源下被浏览器执行。
+func (s *Server) rawEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.loadPublicEntry(w, r)
+	if !ok {
+		return
+	}
+	s.serveSource(w, r, entry)
+}
+
+// downloadEntry 以附件形式下发条目源码，文件名按渲染器选择扩展名。
+func (s *Server) downloadEntry(w http.ResponseWriter, r *http.Request) {
+	entry, ok := s.loadPublicEntry(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": sourceFilename(entry),
+	}))
+	s.serveSource(w, r, entry)
+}
+
+// serveSource 写出条目源码。http.ServeContent 负责 Range 与基于
+// Last-Modified / ETag 的条件请求。
+func (s *Server) serveSource(w http.ResponseWriter, r *http.Request, entry content.Entry) {
+	h := w.Header()
+	h.Set("Content-Type", "text/plain; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", s.pageCacheControl(false))
+	h.Set("ETag", sourceETag(entry))
+	http.ServeContent(w, r, "", entry.UpdatedAt, bytes.NewReader([]byte(entry.Raw)))
+}
+
+// sourceETag 基于源码内容生成强校验 ETag。
+func sourceETag(entry content.Entry) string {
+	sum := sha256.Sum256([]byte(entry.Raw))
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func sourceFilename(entry content.Entry) string {
+	switch entry.Renderer {
+	case content.RendererHTML:
+		return entry.Slug + ".html"
+	default:
+		return entry.Slug + ".md"
+	}
+}
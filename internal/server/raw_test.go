@@ -0,0 +1,142 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+// TestRawEntry 验证 /{slug}/raw 以纯文本返回源码，即便渲染器为 HTML。
+func TestRawEntry(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass"}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	entry, err := store.Create(content.RendererHTML, "<script>alert(1)</script>", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/"+entry.Slug+"/raw", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+	if w.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Errorf("expected nosniff header")
+	}
+	if w.Body.String() != entry.Raw {
+		t.Errorf("body = %q, want raw source", w.Body.String())
+	}
+	if w.Header().Get("Content-Disposition") != "" {
+		t.Errorf("raw endpoint must not force download")
+	}
+
+	// 携带 ETag 的条件请求应返回 304
+	etag := w.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected ETag header")
+	}
+	req2 := httptest.NewRequest(http.MethodGet, "/"+entry.Slug+"/raw", nil)
+	req2.Header.Set("If-None-Match", etag)
+	w2 := httptest.NewRecorder()
+	srv.ServeHTTP(w2, req2)
+	if w2.Code != http.StatusNotModified {
+		t.Errorf("conditional GET: status = %d, want 304", w2.Code)
+	}
+}
+
+// TestDownloadEntry 验证 /{slug}/download 以附件形式下发，并按渲染器选择扩展名。
+func TestDownloadEntry(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass"}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	md, err := store.Create(content.RendererMarkdown, "# Hi", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+	html, err := store.Create(content.RendererHTML, "<p>Hi</p>", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	cases := []struct {
+		slug string
+		want string
+	}{
+		{md.Slug, `attachment; filename=` + md.Slug + `.md`},
+		{html.Slug, `attachment; filename=` + html.Slug + `.html`},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/"+c.slug+"/download", nil)
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("%s: status = %d, want 200", c.slug, w.Code)
+		}
+		if got := w.Header().Get("Content-Disposition"); got != c.want {
+			t.Errorf("%s: Content-Disposition = %q, want %q", c.slug, got, c.want)
+		}
+	}
+
+	// 不存在的条目返回 404
+	req := httptest.NewRequest(http.MethodGet, "/missing/download", nil)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing entry: status = %d, want 404", w.Code)
+	}
+	if strings.Contains(w.Header().Get("Content-Disposition"), "attachment") {
+		t.Errorf("404 must not carry attachment header")
+	}
+}
+
+// TestTrashedEntryNotReachableByEncodedPath 验证 %2F 解码进 {slug} 后无法借路径读到回收站条目。
+func TestTrashedEntryNotReachableByEncodedPath(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass", SandboxHTML: true}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererHTML, "<p>secret trashed content</p>", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+	if err := store.Delete(entry.Slug); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	for _, suffix := range []string{"", "/raw", "/download", "/frame"} {
+		path := "/.trash%2F" + entry.Slug + suffix
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "secret trashed content") {
+			t.Errorf("GET %s: status %d, want 404 without content", path, w.Code)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net"
+	"net/http"
+)
+
+// HTTPSRedirectHandler 把明文 HTTP 请求永久重定向到 HTTPS 上的同一路径。
+// httpsAddr 为 HTTPS 监听地址（如 ":8443"），端口为 443 或缺省时省略端口。
+func HTTPSRedirectHandler(httpsAddr string) http.Handler {
+	_, port, err := net.SplitHostPort(httpsAddr)
+	if err != nil || port == "443" {
+		port = ""
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		if port != "" {
+			host = net.JoinHostPort(host, port)
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusMovedPermanently)
+	})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+func TestHTTPSRedirectHandler(t *testing.T) {
+	cases := []struct {
+		httpsAddr string
+		host      string
+		target    string
+		want      string
+	}{
+		{":443", "snap.example.com", "/abc?x=1", "https://snap.example.com/abc?x=1"},
+		{":443", "snap.example.com:80", "/admin", "https://snap.example.com/admin"},
+		{":8443", "snap.example.com:8080", "/", "https://snap.example.com:8443/"},
+		{"0.0.0.0:8443", "[::1]:8080", "/login", "https://[::1]:8443/login"},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.target, nil)
+		req.Host = c.host
+		w := httptest.NewRecorder()
+		HTTPSRedirectHandler(c.httpsAddr).ServeHTTP(w, req)
+		if w.Code != http.StatusMovedPermanently {
+			t.Errorf("%s%s: status = %d, want 301", c.host, c.target, w.Code)
+		}
+		if got := w.Header().Get("Location"); got != c.want {
+			t.Errorf("%s%s: Location = %q, want %q", c.host, c.target, got, c.want)
+		}
+	}
+}
+
+// TestSessionCookieSecureWithTLS 验证配置原生 TLS 后会话 cookie 带 Secure。
+func TestSessionCookieSecureWithTLS(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	for _, tc := range []struct {
+		cfg    config.Config
+		secure bool
+	}{
+		{config.Config{AdminPassword: "testpass"}, false},
+		{config.Config{AdminPassword: "testpass", TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}, true},
+	} {
+		srv, err := New(tc.cfg, store, "../../templates")
+		if err != nil {
+			t.Fatalf("new server: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("password=testpass"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, req)
+		cookies := w.Result().Cookies()
+		if len(cookies) == 0 {
+			t.Fatalf("expected session cookie")
+		}
+		if cookies[0].Secure != tc.secure {
+			t.Errorf("TLS=%v: Secure = %v, want %v", tc.cfg.TLSEnabled(), cookies[0].Secure, tc.secure)
+		}
+	}
+}
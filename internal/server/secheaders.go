@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 默认 CSP。{nonce} 在每个请求中替换为随机 nonce，模板内联脚本通过
+// nonce="{{ nonce }}" 获得执行权限。样式必须允许 'unsafe-inline'：
+// 原始 HTML 条目保留了 <style> 块与 style 属性，无法逐一加 nonce。
+const (
+	// defaultCSP 适用于后台与登录页：只加载本站资源。
+	defaultCSP = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'unsafe-inline'; " +
+		"img-src 'self' data:; object-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"
+	// defaultViewCSP 适用于渲染用户内容的阅读页与预览：条目可引用外部图片与媒体，
+	// 但脚本仍仅限本站与带 nonce 的内联脚本。
+	defaultViewCSP = "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'unsafe-inline'; " +
+		"img-src * data:; media-src *; font-src * data:; object-src 'none'; base-uri 'none'; form-action 'self'; frame-ancestors 'none'"
+	// sourceCSP 适用于源码端点：纯文本不需要任何能力，额外启用 sandbox。
+	sourceCSP = "default-src 'none'; frame-ancestors 'none'; sandbox"
+)
+
+// viewRoutes 是渲染用户内容的路由，默认使用 ViewContentSecurityPolicy。
+var viewRoutes = []string{"GET /{slug}", "POST /admin/preview"}
+
+type nonceKey struct{}
+
+// cspNonce 取出当前请求的 CSP nonce。
+func cspNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(nonceKey{}).(string)
+	return nonce
+}
+
+// buildRoutePolicies 按路由模式生成 CSP 表；未列出的路由使用 defaultPolicy。
+func (s *Server) buildRoutePolicies() {
+	s.defaultPolicy = s.cfg.ContentSecurityPolicy
+	if s.defaultPolicy == "" {
+		s.defaultPolicy = defaultCSP
+	}
+	view := s.cfg.ViewContentSecurityPolicy
+	if view == "" {
+		view = defaultViewCSP
+		if s.contentOrigin != "" {
+			// 允许阅读页嵌入独立内容域名上的 iframe
+			view += "; frame-src 'self' " + s.contentOrigin
+		}
+	}
+	s.routePolicies = map[string]string{
+		"GET /{slug}/raw":               sourceCSP,
+		"GET /{slug}/download":          sourceCSP,
+		"GET /-/files/{hash}":           sourceCSP,
+		"GET /-/files/{hash}/{variant}": sourceCSP,
+		"GET /{slug}/frame":             s.frameCSP(),
+	}
+	for _, route := range viewRoutes {
+		s.routePolicies[route] = view
+	}
+	// 实时预览框与阅读页使用相同的内容策略，但允许被本站编辑页嵌入。
+	s.routePolicies[previewFrameRoute] = strings.Replace(view, "frame-ancestors 'none'", "frame-ancestors 'self'", 1)
+}
+
+// SetRoutePolicy 为指定路由模式（如 "GET /{slug}"）设置 CSP，空串表示不发送 CSP。
+// 须在开始处理请求前调用。
+func (s *Server) SetRoutePolicy(pattern, policy string) {
+	s.routePolicies[pattern] = policy
+}
+
+// securityHeaders 为所有响应设置安全相关头部：按路由选择的 CSP（带每请求 nonce）、
+// nosniff、Referrer-Policy、防嵌套，以及 TLS 下的 HSTS。
+func (s *Server) securityHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce := newNonce()
+		policy, ok := s.routePolicies[routeFromContext(r.Context())]
+		if !ok {
+			policy = s.defaultPolicy
+		}
+
+		h := w.Header()
+		if policy != "" {
+			h.Set("Content-Security-Policy", strings.ReplaceAll(policy, "{nonce}", nonce))
+		}
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("Referrer-Policy", "same-origin")
+		h.Set("X-Frame-Options", "DENY")
+		if s.cfg.HSTSMaxAge > 0 && s.proxies.isTLS(r) {
+			h.Set("Strict-Transport-Security", "max-age="+strconv.Itoa(int(s.cfg.HSTSMaxAge/time.Second))+"; includeSubDomains")
+		}
+
+		ctx := context.WithValue(r.Context(), nonceKey{}, nonce)
+		next.ServeHTTP(&cspWriter{ResponseWriter: w}, r.WithContext(ctx))
+	})
+}
+
+// cspWriter 在 304 响应中去掉 CSP 头。浏览器会用 304 的头部更新缓存副本，
+// 若带上新 nonce，会与缓存页面里的旧 nonce 不一致而导致内联脚本被拦截。
+type cspWriter struct {
+	http.ResponseWriter
+}
+
+func (w *cspWriter) WriteHeader(code int) {
+	if code == http.StatusNotModified {
+		w.Header().Del("Content-Security-Policy")
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *cspWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func newNonce() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
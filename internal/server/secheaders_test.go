@@ -0,0 +1,192 @@
+package server
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+// TestSecurityHeadersNonce 验证 CSP nonce 每请求随机，且与页面内联脚本的 nonce 一致。
+func TestSecurityHeadersNonce(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererHTML, "<p style=\"color:red\">x</p>", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	var nonces []string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil))
+		csp := w.Header().Get("Content-Security-Policy")
+		start := strings.Index(csp, "'nonce-")
+		if start < 0 {
+			t.Fatalf("expected nonce in CSP, got %q", csp)
+		}
+		nonce := csp[start+len("'nonce-"):]
+		nonce = nonce[:strings.IndexByte(nonce, '\'')]
+		if !strings.Contains(w.Body.String(), `<script nonce="`+nonce+`">`) {
+			t.Errorf("inline script does not carry the header nonce %q", nonce)
+		}
+		if !strings.Contains(csp, "img-src *") {
+			t.Errorf("view page should use the view policy, got %q", csp)
+		}
+		if !strings.Contains(csp, "frame-ancestors 'none'") {
+			t.Errorf("expected frame-ancestors in CSP, got %q", csp)
+		}
+		nonces = append(nonces, nonce)
+	}
+	if nonces[0] == nonces[1] {
+		t.Errorf("nonce must differ between requests")
+	}
+}
+
+// TestTemplateNonceConcurrent 验证并发渲染时每个页面只携带本请求的 nonce。
+func TestTemplateNonceConcurrent(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+			csp := w.Header().Get("Content-Security-Policy")
+			start := strings.Index(csp, "'nonce-")
+			if start < 0 {
+				t.Errorf("expected nonce in CSP, got %q", csp)
+				return
+			}
+			nonce := csp[start+len("'nonce-"):]
+			nonce = nonce[:strings.IndexByte(nonce, '\'')]
+			body := w.Body.String()
+			if n := strings.Count(body, `nonce="`); n == 0 || n != strings.Count(body, `nonce="`+nonce+`"`) {
+				t.Errorf("page carries a nonce other than %q", nonce)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// TestSecurityHeadersPerRoute 验证不同路由使用不同策略，且可按路由覆盖。
+func TestSecurityHeadersPerRoute(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", ContentSecurityPolicy: "default-src 'self'; script-src 'nonce-{nonce}'"}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererMarkdown, "# x", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.HasPrefix(csp, "default-src 'self'; script-src 'nonce-") {
+		t.Errorf("login should use configured default policy, got %q", csp)
+	}
+	for header, want := range map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"Referrer-Policy":        "same-origin",
+		"X-Frame-Options":        "DENY",
+	} {
+		if got := w.Header().Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Slug+"/raw", nil))
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.Contains(csp, "sandbox") {
+		t.Errorf("raw endpoint should be sandboxed, got %q", csp)
+	}
+
+	srv.SetRoutePolicy("GET /login", "")
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if csp := w.Header().Get("Content-Security-Policy"); csp != "" {
+		t.Errorf("expected per-route override to disable CSP, got %q", csp)
+	}
+}
+
+// TestSecurityHeadersHSTS 验证仅在 HTTPS 下发送 HSTS。
+func TestSecurityHeadersHSTS(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	cfg := config.Config{AdminPassword: "testpass", HSTSMaxAge: 24 * time.Hour}
+	srv, err := New(cfg, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/login", nil))
+	if got := w.Header().Get("Strict-Transport-Security"); got != "" {
+		t.Errorf("plain HTTP must not send HSTS, got %q", got)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/login", nil)
+	req.TLS = &tls.ConnectionState{}
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=86400; includeSubDomains" {
+		t.Errorf("HSTS = %q", got)
+	}
+}
+
+// TestNotModifiedOmitsCSP 验证 304 响应不携带 CSP，避免缓存页面与新 nonce 不匹配。
+func TestNotModifiedOmitsCSP(t *testing.T) {
+	store, err := content.NewStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass"}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	entry, err := store.Create(content.RendererMarkdown, "# x", "")
+	if err != nil {
+		t.Fatalf("create entry: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil))
+	req := httptest.NewRequest(http.MethodGet, "/"+entry.Slug, nil)
+	req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+	w = httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Fatalf("status = %d, want 304", w.Code)
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); csp != "" {
+		t.Errorf("304 must not carry CSP, got %q", csp)
+	}
+}
@@ -1,29 +1,86 @@
 package server
 
 import (
-	"crypto/subtle"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"net/url"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"minisnap/internal/attach"
+	"minisnap/internal/audit"
 	"minisnap/internal/config"
 	"minisnap/internal/content"
+	"minisnap/internal/oidc"
+	"minisnap/internal/totp"
+	"minisnap/internal/webauthn"
 )
 
 // Server 负责注册 HTTP 路由并处理请求。
 type Server struct {
-	cfg       config.Config
-	store     *content.Store
+	cfg   config.Config
+	store *content.Store
+	// files 保存编辑器上传的附件，位于内容目录的 .files 下。
+	files *attach.Store
+	// usage 缓存内容目录的占用字节数，用于 StorageQuota 检查与内容库展示。
+	usage     *storageUsage
 	mux       *http.ServeMux
 	templates *template.Template
-	sessions  *sessionStore
-	loginLim  *loginLimiter
+	// tplPattern 为加载模板所用的 glob，供就绪检查核对模板清单。
+	tplPattern string
+	// tplPool 缓存按请求绑定 nonce 的模板克隆；templates 本身从不执行，以便克隆。
+	tplPool  sync.Pool
+	sessions *sessionStore
+	loginLim *loginLimiter
+	// credential 为当前有效的后台密码（哈希或明文配置）。
+	credential *adminCredential
+	proxies    proxyList
+	// auditLog 记录登录、内容变更与设置修改，写入内容目录下的 JSONL 文件。
+	auditLog *audit.Log
+
+	// totp 保存加密的二次验证状态，mfa 跟踪密码已通过、等待验证码的登录。
+	totp *totp.Store
+	mfa  *mfaChallenges
+	// passkeys 保存已注册的通行密钥，ceremonies 保存进行中的注册/登录挑战。
+	passkeys   *webauthn.Store
+	ceremonies *ceremonies
+	// oidc 为单点登录客户端，未配置时为 nil；oidcFlows 保存等待回调的登录。
+	oidc      *oidc.Client
+	oidcFlows *oidcFlows
+
+	// renderCache 缓存阅读页的已消毒 HTML；为 nil 时每次请求都重新渲染。
+	renderCache *content.RenderCache
+	metrics     *serverMetrics
+	handler     http.Handler // mux 外层包裹中间件后的入口
+
+	// 按路由模式选择的 CSP，未命中时使用 defaultPolicy。
+	routePolicies map[string]string
+	defaultPolicy string
+	// 按路由模式设置的请求体上限，未命中时使用 maxFormBody。
+	bodyLimits map[string]int64
+
+	// contentOrigin / contentHost 为承载原始 HTML 条目的独立源，未配置时为空。
+	contentOrigin string
+	contentHost   string
+
+	// draining 在优雅关停开始后置位，/readyz 据此返回 503。
+	draining atomic.Bool
+	// probe 缓存 /readyz 的内容目录读写探测结果。
+	probe contentProbe
+
+	// pageVersion 汇总模板源码与静态资源哈希，参与阅读页 ETag 计算，
+	// 保证升级模板或样式后客户端缓存随之失效。
+	pageVersion string
 }
 
 type entryListItem struct {
@@ -33,6 +90,7 @@ type entryListItem struct {
 	PublishedAt string
 	UpdatedAt   string
 	WasUpdated  bool
+	Draft       bool
 }
 
 type adminTemplateData struct {
@@ -44,6 +102,14 @@ type adminTemplateData struct {
 	PublishedAt  string
 	UpdatedAt    string
 	SelectedSlug string
+	// Draft 为 true 时正在编辑尚未发布的草稿。
+	Draft bool
+	// Autosave 为尚未保存的自动保存，没有时为 nil。
+	Autosave *autosaveNotice
+	// Attachments 为条目内容引用的附件，UploadLimit 为单个附件的大小上限说明。
+	Attachments []attachmentItem
+	UploadLimit string
+	CSRFToken   string
 }
 
 type libraryTemplateData struct {
@@ -53,6 +119,15 @@ type libraryTemplateData struct {
 	TotalEntries  int
 	FilteredCount int
 	HasFilter     bool
+	// Status 为草稿筛选（draft / published），空表示全部；DraftCount 为草稿总数。
+	Status     string
+	DraftCount int
+	// Trashed 与 Published 为刚移入回收站与刚发布的条目，用于提示。
+	Trashed   string
+	Published string
+	// Storage 为内容目录的存储用量与配额，统计失败时为 nil。
+	Storage   *storageSummary
+	CSRFToken string
 }
 
 // New 创建一个 Server 并加载模板。
@@ -61,49 +136,136 @@ func New(cfg config.Config, store *content.Store, tplDir string) (*Server, error
 		return nil, errors.New("store is required")
 	}
 
-	tpls, err := template.ParseGlob(filepath.Join(tplDir, "*.tmpl"))
+	pattern := filepath.Join(tplDir, "*.tmpl")
+	tpls, err := template.New("").Funcs(template.FuncMap{
+		"asset": assetURL,
+		// nonce 在渲染时按请求绑定，见 renderTemplate。
+		"nonce": func() string { return "" },
+	}).ParseGlob(pattern)
 	if err != nil {
 		return nil, fmt.Errorf("parse templates: %w", err)
 	}
+	pageVersion, err := templateVersion(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("hash templates: %w", err)
+	}
 
 	s := &Server{
-		cfg:       cfg,
-		store:     store,
-		templates: tpls,
-		mux:       http.NewServeMux(),
-		sessions:  newSessionStore(),
-		loginLim:  newLoginLimiter(5, time.Minute, time.Minute),
+		cfg:         cfg,
+		store:       store,
+		templates:   tpls,
+		tplPattern:  pattern,
+		mux:         http.NewServeMux(),
+		sessions:    newSessionStore(cfg.SessionTTL, cfg.SessionRememberTTL),
+		loginLim:    newLoginLimiter(loginPolicyFromConfig(cfg)),
+		proxies:     proxyList(cfg.TrustedProxies),
+		auditLog:    audit.New(audit.Path(store.Dir())),
+		usage:       newStorageUsage(store.Dir()),
+		mfa:         newMFAChallenges(),
+		passkeys:    webauthn.NewStore(authStatePath(store.Dir(), "passkeys.json")),
+		ceremonies:  newCeremonies(),
+		oidc:        newOIDCClient(cfg),
+		oidcFlows:   newOIDCFlows(),
+		pageVersion: pageVersion,
+	}
+	if s.credential, err = loadAdminCredential(cfg, authStatePath(store.Dir(), "password")); err != nil {
+		return nil, err
+	}
+	if s.files, err = attach.NewStore(store.Dir()); err != nil {
+		return nil, err
+	}
+	store.OnOperation(func(op string, err error) {
+		if err == nil && storageWrites[op] {
+			s.usage.invalidate()
+		}
+	})
+	if s.totp, err = s.newTOTPStore(); err != nil {
+		return nil, fmt.Errorf("init totp store: %w", err)
+	}
+	if cfg.RenderCacheEntries > 0 {
+		s.renderCache = content.NewRenderCache(cfg.RenderCacheEntries, s.render)
+		store.OnChange(s.renderCache.Invalidate)
+	}
+	if err := s.configureIsolation(); err != nil {
+		return nil, err
 	}
+	if s.isolateHTML() {
+		// 隔离方式决定阅读页输出，需参与 ETag 计算。
+		s.pageVersion += "+frame:" + s.contentOrigin
+	}
+	s.metrics = newServerMetrics(s)
+	s.buildRoutePolicies()
+	s.buildBodyLimits()
 	s.registerRoutes()
+	s.handler = s.instrument(s.securityHeaders(s.guardContentHost(s.limitBodies(s.mux))))
 	return s, nil
 }
 
 // ServeHTTP 实现 http.Handler。
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	s.handler.ServeHTTP(w, r)
 }
 
 func (s *Server) registerRoutes() {
 	s.mux.HandleFunc("GET /", s.redirectAdmin)
 	s.mux.HandleFunc("GET /healthz", s.health)
+	s.mux.HandleFunc("GET /readyz", s.ready)
+	// 主监听地址上的 /metrics 必须带 Bearer 认证；未配置 MetricsToken 时只能经 MetricsAddr 访问。
+	if s.cfg.MetricsAddr == "" && s.cfg.MetricsToken != "" {
+		s.mux.Handle("GET /metrics", s.MetricsHandler())
+	}
 
 	// 静态资源（base.css / theme.js）：用 /-/ 前缀避免与 GET /{slug} 冲突。
 	// /-/static/base.css 是多段路径，不会被单段通配 {slug} 匹配。
-	staticServer := http.FileServer(http.FS(assetsSubFS))
-	s.mux.Handle("GET /-/static/", http.StripPrefix("/-/static/", staticServer))
+	s.mux.HandleFunc("GET /-/static/", s.serveStatic)
+	s.mux.HandleFunc("GET /-/files/{hash}", s.serveFile)
+	s.mux.HandleFunc("GET /-/files/{hash}/{variant}", s.serveFileVariant)
 
 	s.mux.HandleFunc("GET /login", s.showLogin)
 	s.mux.HandleFunc("POST /login", s.handleLogin)
-	s.mux.HandleFunc("POST /logout", s.requireAuth(s.handleLogout))
+	s.mux.HandleFunc("POST /login/totp", s.handleLoginTOTP)
+	s.mux.HandleFunc("POST /login/passkey/options", s.passkeyLoginOptions)
+	s.mux.HandleFunc("POST /login/passkey", s.passkeyLogin)
+	s.mux.HandleFunc("GET /login/oidc", s.startOIDC)
+	s.mux.HandleFunc("GET /login/oidc/callback", s.oidcCallback)
+	s.mux.HandleFunc("POST /logout", s.requireAuth(s.requireCSRF(s.handleLogout)))
 
 	s.mux.HandleFunc("GET /admin/library", s.requireAuth(s.showLibrary))
+	s.mux.HandleFunc("POST /admin/library/bulk", s.requireAuth(s.requireCSRF(s.bulkLibrary)))
 	s.mux.HandleFunc("GET /admin", s.requireAuth(s.showEditor))
-	s.mux.HandleFunc("POST /admin", s.requireAuth(s.createEntry))
-	s.mux.HandleFunc("POST /admin/preview", s.requireAuth(s.previewEntry))
+	s.mux.HandleFunc("POST /admin", s.requireAuth(s.requireCSRF(s.createEntry)))
+	s.mux.HandleFunc("POST /admin/preview", s.requireAuth(s.requireCSRF(s.previewEntry)))
+	s.mux.HandleFunc("POST /admin/preview/render", s.requireAuth(s.requireCSRF(s.renderPreview)))
+	s.mux.HandleFunc(previewFrameRoute, s.requireAuth(s.previewFrame))
+	s.mux.HandleFunc(autosaveRoute, s.requireAuth(s.requireCSRF(s.autosaveEntry)))
+	s.mux.HandleFunc("POST /admin/autosave/discard", s.requireAuth(s.requireCSRF(s.discardAutosave)))
+	s.mux.HandleFunc("POST /admin/files", s.requireAuth(s.requireCSRF(s.uploadFile)))
+	s.mux.HandleFunc("GET /admin/security", s.requireAuth(s.showSecurity))
+	s.mux.HandleFunc("POST /admin/security/totp/enable", s.requireAuth(s.requireCSRF(s.enableTOTP)))
+	s.mux.HandleFunc("POST /admin/security/totp/disable", s.requireAuth(s.requireCSRF(s.disableTOTP)))
+	s.mux.HandleFunc("POST /admin/security/password", s.requireAuth(s.requireCSRF(s.changePassword)))
+	s.mux.HandleFunc("POST /admin/security/totp/recovery", s.requireAuth(s.requireCSRF(s.regenerateRecoveryCodes)))
+	s.mux.HandleFunc("GET /admin/passkeys", s.requireAuth(s.showPasskeys))
+	s.mux.HandleFunc("POST /admin/passkeys/options", s.requireAuth(s.requireCSRF(s.passkeyRegisterOptions)))
+	s.mux.HandleFunc("POST /admin/passkeys", s.requireAuth(s.requireCSRF(s.registerPasskey)))
+	s.mux.HandleFunc("POST /admin/passkeys/{id}/delete", s.requireAuth(s.requireCSRF(s.deletePasskey)))
+	s.mux.HandleFunc("GET /admin/sessions", s.requireAuth(s.showSessions))
+	s.mux.HandleFunc("POST /admin/sessions/{id}/revoke", s.requireAuth(s.requireCSRF(s.revokeSession)))
+	s.mux.HandleFunc("POST /admin/sessions/revoke-all", s.requireAuth(s.requireCSRF(s.revokeAllSessions)))
+	s.mux.HandleFunc("GET /admin/trash", s.requireAuth(s.showTrash))
+	s.mux.HandleFunc("POST /admin/trash/{slug}/restore", s.requireAuth(s.requireCSRF(s.restoreEntry)))
+	s.mux.HandleFunc("POST /admin/trash/{slug}/delete", s.requireAuth(s.requireCSRF(s.purgeEntry)))
+	s.mux.HandleFunc("GET /admin/audit", s.requireAuth(s.showAudit))
+	s.mux.HandleFunc("GET /admin/lockouts", s.requireAuth(s.showLockouts))
+	s.mux.HandleFunc("POST /admin/lockouts/unlock", s.requireAuth(s.requireCSRF(s.unlockIP)))
 
 	s.mux.HandleFunc("GET /{slug}/edit", s.requireAuth(s.showEdit))
-	s.mux.HandleFunc("POST /{slug}/edit", s.requireAuth(s.updateEntry))
-	s.mux.HandleFunc("POST /{slug}/delete", s.requireAuth(s.deleteEntry))
+	s.mux.HandleFunc("POST /{slug}/edit", s.requireAuth(s.requireCSRF(s.updateEntry)))
+	s.mux.HandleFunc("POST /{slug}/delete", s.requireAuth(s.requireCSRF(s.deleteEntry)))
+	s.mux.HandleFunc("POST /{slug}/publish", s.requireAuth(s.requireCSRF(s.publishEntry)))
+	s.mux.HandleFunc("GET /{slug}/raw", s.rawEntry)
+	s.mux.HandleFunc("GET /{slug}/download", s.downloadEntry)
+	s.mux.HandleFunc("GET /{slug}/frame", s.frameEntry)
 
 	s.mux.HandleFunc("GET /{slug}", s.showEntry)
 }
@@ -119,7 +281,16 @@ func (s *Server) health(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) requireAuth(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if _, ok := s.authenticated(r); !ok {
+		var sess session
+		var renewed, ok bool
+		if cookie, err := r.Cookie(sessionCookieName); err == nil {
+			sess, renewed, ok = s.sessions.Touch(cookie.Value, time.Now())
+			// 持久 cookie 随服务端有效期一起顺延
+			if ok && renewed && sess.remember {
+				s.writeSessionCookie(w, r, cookie.Value, sess.expires)
+			}
+		}
+		if !ok {
 			nextURL := url.QueryEscape(r.URL.RequestURI())
 			http.Redirect(w, r, "/login?next="+nextURL, http.StatusFound)
 			return
@@ -139,25 +310,46 @@ func (s *Server) authenticated(r *http.Request) (string, bool) {
 	return cookie.Value, true
 }
 
-func (s *Server) setSession(w http.ResponseWriter) {
-	token, expires := s.sessions.Create()
+// setSession 以 admin 身份建立会话并记录登录来源。勾选“记住我”时下发持久 cookie，
+// 否则为浏览器会话 cookie。
+func (s *Server) setSession(w http.ResponseWriter, r *http.Request, remember bool) {
+	s.setSessionAs(w, r, remember, adminUser)
+}
+
+// setSessionAs 与 setSession 相同，但记录指定的登录身份。
+func (s *Server) setSessionAs(w http.ResponseWriter, r *http.Request, remember bool, actor string) {
+	token, expires := s.sessions.Create(sessionMeta{
+		IP:        s.clientIP(r),
+		UserAgent: r.UserAgent(),
+		Remember:  remember,
+		Actor:     actor,
+	})
+	if !remember {
+		expires = time.Time{}
+	}
+	s.writeSessionCookie(w, r, token, expires)
+}
+
+func (s *Server) writeSessionCookie(w http.ResponseWriter, r *http.Request, token string, expires time.Time) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     sessionCookieName,
 		Value:    token,
 		Path:     "/",
 		HttpOnly: true,
+		Secure:   s.secureCookie(r),
 		SameSite: http.SameSiteStrictMode,
 		Expires:  expires,
 	})
 }
 
-func (s *Server) clearSession(w http.ResponseWriter, token string) {
+func (s *Server) clearSession(w http.ResponseWriter, r *http.Request, token string) {
 	s.sessions.Remove(token)
 	http.SetCookie(w, &http.Cookie{
 		Name:     sessionCookieName,
 		Value:    "",
 		Path:     "/",
 		HttpOnly: true,
+		Secure:   s.secureCookie(r),
 		SameSite: http.SameSiteStrictMode,
 		Expires:  time.Unix(0, 0),
 	})
@@ -170,15 +362,22 @@ func (s *Server) showLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	next := r.URL.Query().Get("next")
-	s.renderTemplate(w, "login.tmpl", map[string]any{
-		"Title": "Login",
-		"Next":  next,
+	var notice string
+	if r.URL.Query().Get("changed") != "" {
+		notice = "Password changed. Please sign in again."
+	}
+	s.renderTemplate(w, r, "login.tmpl", map[string]any{
+		"Title":    "Login",
+		"Next":     next,
+		"Notice":   notice,
+		"Passkeys": s.hasPasskeys(),
+		"SSO":      s.oidc != nil,
 	})
 }
 
 func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseForm(); err != nil {
-		s.renderTemplate(w, "login.tmpl", map[string]any{
+		s.renderTemplate(w, r, "login.tmpl", map[string]any{
 			"Title": "Login",
 			"Error": "Invalid form data",
 			"Next":  r.FormValue("next"),
@@ -186,12 +385,11 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	clientIP := ipFromRequest(r)
+	clientIP := s.clientIP(r)
 
-	// 登录限流：锁定期间直接拒绝，不校验密码。
-	if s.loginLim.isLocked(clientIP, time.Now()) {
-		w.WriteHeader(http.StatusTooManyRequests)
-		s.renderTemplate(w, "login.tmpl", map[string]any{
+	// 登录限流：锁定期间（或全局失败次数超限时）直接拒绝，不校验密码。
+	if now := time.Now(); s.loginLim.isLocked(clientIP, now) || s.loginLim.globalLocked(now) {
+		s.renderTemplateStatus(w, r, http.StatusTooManyRequests, "login.tmpl", map[string]any{
 			"Title": "Login",
 			"Error": "Too many failed attempts, please try again later",
 			"Next":  r.FormValue("next"),
@@ -199,81 +397,128 @@ func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	password := r.FormValue("password")
-	// 恒定时间比较，避免基于响应时间的侧信道猜测密码。
-	if subtle.ConstantTimeCompare([]byte(password), []byte(s.cfg.AdminPassword)) != 1 {
-		s.loginLim.recordFailure(clientIP, time.Now())
-		s.renderTemplate(w, "login.tmpl", map[string]any{
-			"Title": "Login",
-			"Error": "Incorrect password",
-			"Next":  r.FormValue("next"),
+	if !s.credential.verify(r.FormValue("password")) {
+		s.loginFailed(r, "password")
+		s.renderTemplate(w, r, "login.tmpl", map[string]any{
+			"Title":    "Login",
+			"Error":    "Incorrect password",
+			"Next":     r.FormValue("next"),
+			"Passkeys": s.hasPasskeys(),
+			"SSO":      s.oidc != nil,
 		})
 		return
 	}
 
-	s.loginLim.recordSuccess(clientIP)
-	s.setSession(w)
-	next := r.FormValue("next")
-	if next == "" {
-		next = "/admin"
-	} else if !strings.HasPrefix(next, "/") {
-		next = "/admin"
+	next := safeNext(r.FormValue("next"))
+
+	remember := r.FormValue("remember") != ""
+
+	// 启用二次验证时，密码只是第一步；失败计数在验证码通过后才清零。
+	if s.totp.Enabled() {
+		// 状态无法解密时验证码永远无法通过，直接说明原因与恢复方法，而不是进入第二步。
+		if _, err := s.totp.Load(); errors.Is(err, totp.ErrUndecryptable) {
+			slog.ErrorContext(r.Context(), "load totp state", "error", err)
+			s.renderTemplateStatus(w, r, http.StatusServiceUnavailable, "login.tmpl", map[string]any{
+				"Title": "Login",
+				"Error": errTOTPUnreadable,
+				"Next":  r.FormValue("next"),
+			})
+			return
+		}
+		s.startSecondFactor(w, r, next, remember)
+		return
 	}
+
+	s.loginLim.recordSuccess(clientIP)
+	s.setSession(w, r, remember)
+	s.audit(r, audit.Event{Action: audit.LoginSuccess, Actor: adminUser, Detail: "password"})
 	http.Redirect(w, r, next, http.StatusFound)
 }
 
+// safeNext 只接受站内路径作为登录后的跳转目标，其余情况回到后台首页。
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/admin"
+	}
+	return next
+}
+
 func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
 	token, ok := s.authenticated(r)
 	if ok {
-		s.clearSession(w, token)
+		s.audit(r, audit.Event{Action: audit.Logout})
+		s.clearSession(w, r, token)
 	}
 	http.Redirect(w, r, "/login", http.StatusFound)
 }
 
 func (s *Server) showEditor(w http.ResponseWriter, r *http.Request) {
 	data := s.buildEditorData(nil)
-	s.renderTemplate(w, "admin.tmpl", data)
+	s.applyAutosave(r, &data, content.NewEntryAutosave)
+	data.CSRFToken = s.csrfToken(r)
+	s.renderTemplate(w, r, "admin.tmpl", data)
 }
 
 func (s *Server) createEntry(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		s.renderError(w, http.StatusBadRequest, "Invalid form data")
+	if !s.parseForm(w, r) {
 		return
 	}
 
 	renderer := content.RendererType(r.FormValue("renderer"))
 	raw := r.FormValue("content")
 	description := r.FormValue("description")
+	if !s.checkEntrySize(w, r, raw, int64(len(raw)+len(description))) {
+		return
+	}
 
-	entry, err := s.store.Create(renderer, raw, description)
+	// 勾选“保存为草稿”时条目在发布前不公开。
+	create := s.store.Create
+	if r.FormValue("draft") != "" {
+		create = s.store.CreateDraft
+	}
+	entry, err := create(renderer, raw, description)
 	if err != nil {
-		slog.Error("create entry", "error", err)
+		slog.ErrorContext(r.Context(), "create entry", "error", err)
 		s.renderError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	s.audit(r, audit.Event{Action: audit.EntryCreate, Slug: entry.Slug, Hash: audit.ContentHash(entry.Raw), Detail: string(entry.Renderer)})
+	if err := s.store.DiscardAutosave(content.NewEntryAutosave); err != nil {
+		slog.WarnContext(r.Context(), "discard autosave", "key", content.NewEntryAutosave, "error", err)
+	}
 
 	viewURL := fmt.Sprintf("/%s", entry.Slug)
 	editURL := fmt.Sprintf("/%s/edit", entry.Slug)
 	wasUpdated := !entry.UpdatedAt.IsZero() && !entry.UpdatedAt.Equal(entry.CreatedAt)
 
-	s.renderTemplate(w, "saved.tmpl", map[string]any{
-		"Title":       "Entry Saved",
+	title := "Entry Saved"
+	if entry.Draft {
+		title = "Draft Saved"
+	}
+	s.renderTemplate(w, r, "saved.tmpl", map[string]any{
+		"Title":       title,
 		"ViewURL":     viewURL,
 		"EditURL":     editURL,
+		"PublishURL":  fmt.Sprintf("/%s/publish", entry.Slug),
 		"PublishedAt": formatTime(entry.CreatedAt),
 		"UpdatedAt":   formatTime(entry.UpdatedAt),
 		"WasUpdated":  wasUpdated,
+		"Draft":       entry.Draft,
+		"CSRFToken":   s.csrfToken(r),
 	})
 }
 
 func (s *Server) previewEntry(w http.ResponseWriter, r *http.Request) {
-	if err := r.ParseForm(); err != nil {
-		s.renderError(w, http.StatusBadRequest, "Invalid form data")
+	if !s.parseForm(w, r) {
 		return
 	}
 
 	renderer := content.RendererType(r.FormValue("renderer"))
 	raw := r.FormValue("content")
+	if int64(len(raw)) > s.maxEntrySize() {
+		s.renderTooLarge(w, r, entryTooLargeMessage(s.maxEntrySize()))
+		return
+	}
 
 	// Validate renderer type
 	if renderer != content.RendererMarkdown && renderer != content.RendererHTML {
@@ -287,14 +532,14 @@ func (s *Server) previewEntry(w http.ResponseWriter, r *http.Request) {
 		Raw:      raw,
 	}
 
-	html, err := content.RenderHTML(tempEntry)
+	html, err := s.render(tempEntry)
 	if err != nil {
-		slog.Error("render preview", "error", err)
+		slog.ErrorContext(r.Context(), "render preview", "error", err)
 		s.renderError(w, http.StatusInternalServerError, "Render Failed")
 		return
 	}
 
-	s.renderTemplate(w, "preview.tmpl", map[string]any{
+	s.renderTemplate(w, r, "preview.tmpl", map[string]any{
 		"Title":            "Preview",
 		"HTML":             html,
 		"GeneratedAt":      formatTime(time.Now()),
@@ -303,27 +548,45 @@ func (s *Server) previewEntry(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) showEntry(w http.ResponseWriter, r *http.Request) {
-	slug := r.PathValue("slug")
-	entry, err := s.store.Get(slug)
-	if err != nil {
-		s.renderError(w, http.StatusNotFound, "Not Found")
+	entry, ok := s.loadPublicEntry(w, r)
+	if !ok {
 		return
 	}
+	slug := entry.Slug
 
-	html, err := content.RenderHTML(entry)
-	if err != nil {
-		slog.Error("render entry", "slug", slug, "error", err)
-		s.renderError(w, http.StatusInternalServerError, "Render Failed")
+	// 已登录访客（管理员）在阅读页可见编辑入口；普通访客不可见。
+	_, canEdit := s.authenticated(r)
+
+	// 条件请求命中时直接返回 304，跳过 Markdown 渲染与消毒。
+	etag := s.pageETag(entry, canEdit)
+	if notModified(r, etag, entry.UpdatedAt) {
+		setValidators(w, etag, entry.UpdatedAt, s.pageCacheControl(canEdit))
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
-	// 已登录访客（管理员）在阅读页可见编辑入口；普通访客不可见。
-	_, canEdit := s.authenticated(r)
+	// 原始 HTML 条目开启隔离时放进沙箱 iframe，外层阅读页仍在主站源。
+	var html template.HTML
+	frameURL := ""
+	if entry.Renderer == content.RendererHTML && s.isolateHTML() {
+		frameURL = s.frameURL(slug)
+	} else {
+		var err error
+		html, err = s.renderEntry(entry)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "render entry", "slug", slug, "error", err)
+			s.renderError(w, http.StatusInternalServerError, "Render Failed")
+			return
+		}
+	}
 
-	s.renderTemplate(w, "view.tmpl", map[string]any{
+	setValidators(w, etag, entry.UpdatedAt, s.pageCacheControl(canEdit))
+	s.renderTemplate(w, r, "view.tmpl", map[string]any{
 		"Title":            entry.Slug,
 		"Slug":             entry.Slug,
 		"HTML":             html,
+		"FrameURL":         frameURL,
+		"FrameSandbox":     frameSandbox,
 		"PublishedAt":      formatTime(entry.CreatedAt),
 		"UpdatedAt":        formatTime(entry.UpdatedAt),
 		"WasUpdated":       !entry.UpdatedAt.IsZero() && !entry.UpdatedAt.Equal(entry.CreatedAt),
@@ -332,6 +595,14 @@ func (s *Server) showEntry(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// renderEntry 渲染已发布条目，启用缓存时优先复用缓存产物。
+func (s *Server) renderEntry(entry content.Entry) (template.HTML, error) {
+	if s.renderCache != nil {
+		return s.renderCache.Render(entry)
+	}
+	return s.render(entry)
+}
+
 func (s *Server) showEdit(w http.ResponseWriter, r *http.Request) {
 	slug := r.PathValue("slug")
 	entry, err := s.store.Get(slug)
@@ -341,36 +612,65 @@ func (s *Server) showEdit(w http.ResponseWriter, r *http.Request) {
 	}
 
 	data := s.buildEditorData(&entry)
-	s.renderTemplate(w, "admin.tmpl", data)
+	s.applyAutosave(r, &data, entry.Slug)
+	data.CSRFToken = s.csrfToken(r)
+	s.renderTemplate(w, r, "admin.tmpl", data)
 }
 
 func (s *Server) updateEntry(w http.ResponseWriter, r *http.Request) {
 	slug := r.PathValue("slug")
-	if err := r.ParseForm(); err != nil {
-		s.renderError(w, http.StatusBadRequest, "Invalid form data")
+	if !s.parseForm(w, r) {
 		return
 	}
 
 	renderer := content.RendererType(r.FormValue("renderer"))
 	raw := r.FormValue("content")
 	description := r.FormValue("description")
+	// 配额只约束内容增长的部分，已超额时仍可把条目改短。
+	growth := int64(len(raw) + len(description))
+	if old, err := s.store.Get(slug); err == nil {
+		growth -= int64(len(old.Raw) + len(old.Description))
+	}
+	if !s.checkEntrySize(w, r, raw, growth) {
+		return
+	}
 
 	entry, err := s.store.Update(slug, renderer, raw, description)
 	if err != nil {
-		slog.Error("update entry", "slug", slug, "error", err)
+		slog.ErrorContext(r.Context(), "update entry", "slug", slug, "error", err)
 		s.renderError(w, http.StatusBadRequest, err.Error())
 		return
 	}
+	s.audit(r, audit.Event{Action: audit.EntryUpdate, Slug: entry.Slug, Hash: audit.ContentHash(entry.Raw), Detail: string(entry.Renderer)})
+	if err := s.store.DiscardAutosave(entry.Slug); err != nil {
+		slog.WarnContext(r.Context(), "discard autosave", "key", entry.Slug, "error", err)
+	}
+
+	title := "Entry Updated"
+	if entry.Draft {
+		title = "Draft Saved"
+		// 草稿编辑页的“发布”按钮在保存后随即发布。
+		if r.FormValue("publish") != "" {
+			var ok bool
+			if entry, ok = s.publishDraft(w, r, entry.Slug); !ok {
+				return
+			}
+			title = "Entry Published"
+		}
+	}
 
 	viewURL := fmt.Sprintf("/%s", entry.Slug)
 	wasUpdated := !entry.UpdatedAt.IsZero() && !entry.UpdatedAt.Equal(entry.CreatedAt)
-	s.renderTemplate(w, "saved.tmpl", map[string]any{
-		"Title":       "Entry Updated",
+	s.renderTemplate(w, r, "saved.tmpl", map[string]any{
+		"Title":       title,
 		"ViewURL":     viewURL,
 		"EditURL":     fmt.Sprintf("/%s/edit", entry.Slug),
+		"PublishURL":  fmt.Sprintf("/%s/publish", entry.Slug),
 		"PublishedAt": formatTime(entry.CreatedAt),
 		"UpdatedAt":   formatTime(entry.UpdatedAt),
 		"WasUpdated":  wasUpdated,
+		"Draft":       entry.Draft,
+		"CSRFToken":   s.csrfToken(r),
 	})
 }
 
@@ -381,43 +681,67 @@ func (s *Server) deleteEntry(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 删除只是移入回收站；记录删除前的内容摘要，便于事后核对被删的是哪个版本。
+	var hash string
+	if entry, err := s.store.Get(slug); err == nil {
+		hash = audit.ContentHash(entry.Raw)
+	}
 	if err := s.store.Delete(slug); err != nil {
 		if errors.Is(err, content.ErrEntryNotFound) {
 			s.renderError(w, http.StatusNotFound, "Not Found")
 			return
 		}
-		slog.Error("delete entry", "slug", slug, "error", err)
+		slog.ErrorContext(r.Context(), "delete entry", "slug", slug, "error", err)
 		s.renderError(w, http.StatusInternalServerError, "Delete Failed")
 		return
 	}
+	s.audit(r, audit.Event{Action: audit.EntryDelete, Slug: slug, Hash: hash})
 
-	http.Redirect(w, r, "/admin/library", http.StatusFound)
+	http.Redirect(w, r, "/admin/library?trashed="+url.QueryEscape(slug), http.StatusFound)
 }
 
 func (s *Server) showLibrary(w http.ResponseWriter, r *http.Request) {
 	search := strings.TrimSpace(r.URL.Query().Get("q"))
-	items, total, err := s.buildEntryList(search)
+	status := r.URL.Query().Get("status")
+	if status != "draft" && status != "published" {
+		status = ""
+	}
+	items, total, drafts, err := s.buildEntryList(search, status)
 	if err != nil {
-		slog.Error("list entries", "error", err)
+		slog.ErrorContext(r.Context(), "list entries", "error", err)
 		s.renderError(w, http.StatusInternalServerError, "Failed to load entries")
 		return
 	}
 
-	s.renderTemplate(w, "library.tmpl", libraryTemplateData{
+	var storage *storageSummary
+	if sum, err := s.storageSummary(); err != nil {
+		slog.ErrorContext(r.Context(), "measure storage", "error", err)
+	} else {
+		storage = &sum
+	}
+
+	s.renderTemplate(w, r, "library.tmpl", libraryTemplateData{
 		Title:         "Content Library",
 		Entries:       items,
 		SearchTerm:    search,
 		TotalEntries:  total,
 		FilteredCount: len(items),
-		HasFilter:     search != "",
+		HasFilter:     search != "" || status != "",
+		Status:        status,
+		DraftCount:    drafts,
+		Trashed:       r.URL.Query().Get("trashed"),
+		Published:     r.URL.Query().Get("published"),
+		Storage:       storage,
+		CSRFToken:     s.csrfToken(r),
 	})
 }
 
 func (s *Server) buildEditorData(entry *content.Entry) adminTemplateData {
 	data := adminTemplateData{
-		Title:    "Create New Entry",
-		Action:   "/admin",
-		Renderer: content.RendererMarkdown,
+		Title:       "Create New Entry",
+		Action:      "/admin",
+		Renderer:    content.RendererMarkdown,
+		UploadLimit: formatSize(s.uploadMaxSize()),
 	}
 
 	if entry == nil {
@@ -432,19 +756,30 @@ func (s *Server) buildEditorData(entry *content.Entry) adminTemplateData {
 	data.PublishedAt = formatTime(entry.CreatedAt)
 	data.UpdatedAt = formatTime(entry.UpdatedAt)
 	data.SelectedSlug = entry.Slug
+	data.Draft = entry.Draft
+	data.Attachments = s.entryAttachments(entry.Raw)
 	return data
 }
 
-func (s *Server) buildEntryList(searchTerm string) ([]entryListItem, int, error) {
+// buildEntryList 按搜索词与草稿状态（draft / published，空表示全部）筛选条目，
+// 同时返回条目总数与草稿总数。
+func (s *Server) buildEntryList(searchTerm, status string) ([]entryListItem, int, int, error) {
 	entries, err := s.store.List()
 	if err != nil {
-		return nil, 0, err
+		return nil, 0, 0, err
 	}
 
 	total := len(entries)
+	drafts := 0
 	search := strings.ToLower(strings.TrimSpace(searchTerm))
 	items := make([]entryListItem, 0, total)
 	for _, entry := range entries {
+		if entry.Draft {
+			drafts++
+		}
+		if (status == "draft" && !entry.Draft) || (status == "published" && entry.Draft) {
+			continue
+		}
 		if search != "" {
 			if !strings.Contains(strings.ToLower(entry.Slug), search) &&
 				!strings.Contains(strings.ToLower(entry.Raw), search) &&
@@ -463,10 +798,11 @@ func (s *Server) buildEntryList(searchTerm string) ([]entryListItem, int, error)
 			PublishedAt: formatTime(entry.CreatedAt),
 			UpdatedAt:   formatTime(entry.UpdatedAt),
 			WasUpdated:  !entry.UpdatedAt.IsZero() && !entry.UpdatedAt.Equal(entry.CreatedAt),
+			Draft:       entry.Draft,
 		})
 	}
 
-	return items, total, nil
+	return items, total, drafts, nil
 }
 
 func summarize(raw string, limit int) string {
@@ -482,12 +818,58 @@ func summarize(raw string, limit int) string {
 	return string(runes[:limit]) + "…"
 }
 
-func (s *Server) renderTemplate(w http.ResponseWriter, name string, data any) {
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	if err := s.templates.ExecuteTemplate(w, name, data); err != nil {
+// boundTemplates 是模板集的一份克隆，模板函数 nonce 返回其 nonce 字段。
+// html/template 在克隆首次执行时完成转义，克隆放回池中复用，避免每个请求重新转义。
+type boundTemplates struct {
+	tpl   *template.Template
+	nonce string
+}
+
+// boundTemplates 从池中取出一份模板克隆，池为空时从未执行过的原始模板集克隆。
+func (s *Server) boundTemplates() (*boundTemplates, error) {
+	if b, ok := s.tplPool.Get().(*boundTemplates); ok {
+		return b, nil
+	}
+	tpl, err := s.templates.Clone()
+	if err != nil {
+		return nil, err
+	}
+	b := &boundTemplates{}
+	b.tpl = tpl.Funcs(template.FuncMap{
+		"nonce": func() string { return b.nonce },
+	})
+	return b, nil
+}
+
+// renderTemplate 以 200 渲染模板，模板内联脚本通过 {{ nonce }} 取得当前请求的 CSP nonce。
+func (s *Server) renderTemplate(w http.ResponseWriter, r *http.Request, name string, data any) {
+	s.renderTemplateStatus(w, r, http.StatusOK, name, data)
+}
+
+// renderTemplateStatus 以指定状态码渲染模板。先渲染到缓冲区，
+// 响应头（含 Content-Type）在 WriteHeader 之前设置，渲染失败时仍能返回 500。
+func (s *Server) renderTemplateStatus(w http.ResponseWriter, r *http.Request, status int, name string, data any) {
+	b, err := s.boundTemplates()
+	if err != nil {
+		slog.Error("clone templates", "error", err)
+		http.Error(w, "Template Error", http.StatusInternalServerError)
+		return
+	}
+	b.nonce = cspNonce(r.Context())
+	defer func() {
+		b.nonce = ""
+		s.tplPool.Put(b)
+	}()
+
+	var buf bytes.Buffer
+	if err := b.tpl.ExecuteTemplate(&buf, name, data); err != nil {
 		slog.Error("render template", "name", name, "error", err)
 		http.Error(w, "Template Error", http.StatusInternalServerError)
+		return
 	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = buf.WriteTo(w)
 }
 
 func (s *Server) renderError(w http.ResponseWriter, status int, message string) {
@@ -495,6 +877,25 @@ func (s *Server) renderError(w http.ResponseWriter, status int, message string)
 	_, _ = w.Write([]byte(message))
 }
 
+// templateVersion 对模板源码与静态资源版本做摘要。
+func templateVersion(pattern string) (string, error) {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	h.Write([]byte(assetVersion))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return "", err
+		}
+		h.Write([]byte(filepath.Base(f)))
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil)[:8]), nil
+}
+
 func formatTime(t time.Time) string {
 	if t.IsZero() {
 		return ""
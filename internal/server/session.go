@@ -3,57 +3,183 @@ package server
 import (
 	"crypto/rand"
 	"encoding/base64"
+	"sort"
 	"sync"
 	"time"
 )
 
 const (
 	sessionCookieName = "minisnap_session"
-	sessionTTL        = 24 * time.Hour
+	// defaultSessionTTL 为普通会话的闲置超时，defaultRememberTTL 为勾选“记住我”后的闲置超时。
+	defaultSessionTTL  = 12 * time.Hour
+	defaultRememberTTL = 30 * 24 * time.Hour
+	// sessionTouchInterval 限制顺延有效期（及续发 cookie）的频率。
+	sessionTouchInterval = time.Minute
+	// maxUserAgentLen 为会话记录的 User-Agent 长度上限。
+	maxUserAgentLen = 256
+
+	// adminUser 是唯一的后台账号名，用于日志与审计中的操作者字段。
+	adminUser = "admin"
 )
 
 type session struct {
-	expires time.Time
+	id       string // 公开标识，用于会话列表与撤销，不暴露 token 本身
+	created  time.Time
+	lastSeen time.Time
+	expires  time.Time
+	remember bool
+	ip       string
+	agent    string
+	actor    string // 登录身份，用于审计日志
+	csrf     string // 与会话绑定的 CSRF token
+	// pendingTOTP 为尚未确认的二次验证密钥，确认后才写入持久化存储。
+	pendingTOTP []byte
+}
+
+// sessionMeta 是登录时记录的客户端信息。
+type sessionMeta struct {
+	IP        string
+	UserAgent string
+	Remember  bool
+	// Actor 为登录身份，密码与通行密钥登录为 admin，单点登录为身份提供方的邮箱。
+	Actor string
+}
+
+// sessionInfo 是会话列表中展示的一项。
+type sessionInfo struct {
+	ID        string
+	IP        string
+	UserAgent string
+	Created   time.Time
+	LastSeen  time.Time
+	Expires   time.Time
+	Remember  bool
+	Current   bool
 }
 
 type sessionStore struct {
 	mu       sync.RWMutex
 	sessions map[string]session
+	// ttl / rememberTTL 为两类会话的闲置超时，每次活动都会顺延。
+	ttl         time.Duration
+	rememberTTL time.Duration
 }
 
-func newSessionStore() *sessionStore {
-	return &sessionStore{sessions: make(map[string]session)}
+func newSessionStore(ttl, rememberTTL time.Duration) *sessionStore {
+	if ttl <= 0 {
+		ttl = defaultSessionTTL
+	}
+	if rememberTTL <= 0 {
+		rememberTTL = defaultRememberTTL
+	}
+	return &sessionStore{sessions: make(map[string]session), ttl: ttl, rememberTTL: rememberTTL}
 }
 
-func (s *sessionStore) Create() (string, time.Time) {
+func (s *sessionStore) lifetime(remember bool) time.Duration {
+	if remember {
+		return s.rememberTTL
+	}
+	return s.ttl
+}
+
+func (s *sessionStore) Create(meta sessionMeta) (string, time.Time) {
 	token := newToken()
-	expires := time.Now().Add(sessionTTL)
+	now := time.Now()
+	expires := now.Add(s.lifetime(meta.Remember))
+	agent := meta.UserAgent
+	if len(agent) > maxUserAgentLen {
+		agent = agent[:maxUserAgentLen]
+	}
 
 	s.mu.Lock()
-	s.sessions[token] = session{expires: expires}
+	for t, sess := range s.sessions {
+		if now.After(sess.expires) {
+			delete(s.sessions, t)
+		}
+	}
+	s.sessions[token] = session{
+		id:       newToken()[:16],
+		created:  now,
+		lastSeen: now,
+		expires:  expires,
+		remember: meta.Remember,
+		ip:       meta.IP,
+		agent:    agent,
+		actor:    meta.Actor,
+		csrf:     newToken(),
+	}
 	s.mu.Unlock()
 
 	return token, expires
 }
 
+// Validate 只校验会话是否有效，不顺延有效期。访问日志、CSRF 等旁路查询使用它，
+// 以免抢先消耗节流窗口，使 requireAuth 中的 Touch 无法续发持久 cookie。
 func (s *sessionStore) Validate(token string) bool {
 	if token == "" {
 		return false
 	}
-
 	s.mu.RLock()
+	defer s.mu.RUnlock()
 	sess, ok := s.sessions[token]
-	s.mu.RUnlock()
+	return ok && !time.Now().After(sess.expires)
+}
+
+// Touch 校验会话并顺延闲置超时。renewed 表示本次确实顺延了有效期
+// （按 sessionTouchInterval 节流），持久 cookie 需随之续发。
+func (s *sessionStore) Touch(token string, now time.Time) (sess session, renewed, ok bool) {
+	if token == "" {
+		return session{}, false, false
+	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok = s.sessions[token]
 	if !ok {
-		return false
+		return session{}, false, false
 	}
-	if time.Now().After(sess.expires) {
-		s.Remove(token)
-		return false
+	if now.After(sess.expires) {
+		delete(s.sessions, token)
+		return session{}, false, false
+	}
+	if now.Sub(sess.lastSeen) >= sessionTouchInterval {
+		sess.lastSeen = now
+		sess.expires = now.Add(s.lifetime(sess.remember))
+		s.sessions[token] = sess
+		renewed = true
 	}
+	return sess, renewed, true
+}
 
-	return true
+// CSRFToken 返回会话绑定的 CSRF token；会话不存在时返回空串。
+func (s *sessionStore) CSRFToken(token string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sessions[token].csrf
+}
+
+// Actor 返回会话的登录身份；会话不存在时返回空串。
+func (s *sessionStore) Actor(token string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sessions[token].actor
+}
+
+// PendingTOTP 返回会话中待确认的 TOTP 密钥。
+func (s *sessionStore) PendingTOTP(token string) []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sessions[token].pendingTOTP
+}
+
+// SetPendingTOTP 设置（secret 为 nil 时清除）会话中待确认的 TOTP 密钥。
+func (s *sessionStore) SetPendingTOTP(token string, secret []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[token]; ok {
+		sess.pendingTOTP = secret
+		s.sessions[token] = sess
+	}
 }
 
 func (s *sessionStore) Remove(token string) {
@@ -65,6 +191,67 @@ func (s *sessionStore) Remove(token string) {
 	s.mu.Unlock()
 }
 
+// RemoveID 按公开标识撤销会话，返回被撤销会话的 token。
+func (s *sessionStore) RemoveID(id string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for token, sess := range s.sessions {
+		if sess.id == id {
+			delete(s.sessions, token)
+			return token, true
+		}
+	}
+	return "", false
+}
+
+// RemoveAll 撤销全部会话，返回撤销数量。
+func (s *sessionStore) RemoveAll() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.sessions)
+	s.sessions = make(map[string]session)
+	return n
+}
+
+// List 返回未过期的会话，最近活动的在前；current 对应的会话标记为当前设备。
+func (s *sessionStore) List(current string) []sessionInfo {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	out := make([]sessionInfo, 0, len(s.sessions))
+	for token, sess := range s.sessions {
+		if now.After(sess.expires) {
+			continue
+		}
+		out = append(out, sessionInfo{
+			ID:        sess.id,
+			IP:        sess.ip,
+			UserAgent: sess.agent,
+			Created:   sess.created,
+			LastSeen:  sess.lastSeen,
+			Expires:   sess.expires,
+			Remember:  sess.remember,
+			Current:   token == current,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// Count 返回未过期的会话数。
+func (s *sessionStore) Count() int {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := 0
+	for _, sess := range s.sessions {
+		if now.Before(sess.expires) {
+			n++
+		}
+	}
+	return n
+}
+
 func newToken() string {
 	buf := make([]byte, 32)
 	if _, err := rand.Read(buf); err != nil {
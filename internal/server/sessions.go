@@ -0,0 +1,133 @@
+package server
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"minisnap/internal/audit"
+)
+
+type sessionsTemplateData struct {
+	Title     string
+	Sessions  []sessionItem
+	Notice    string
+	CSRFToken string
+}
+
+type sessionItem struct {
+	ID        string
+	Device    string
+	UserAgent string
+	IP        string
+	Created   string
+	LastSeen  string
+	Expires   string
+	Remember  bool
+	Current   bool
+}
+
+// showSessions 列出当前有效的后台会话。
+func (s *Server) showSessions(w http.ResponseWriter, r *http.Request) {
+	current, _ := s.authenticated(r)
+	var items []sessionItem
+	for _, sess := range s.sessions.List(current) {
+		items = append(items, sessionItem{
+			ID:        sess.ID,
+			Device:    describeUserAgent(sess.UserAgent),
+			UserAgent: sess.UserAgent,
+			IP:        sess.IP,
+			Created:   formatTime(sess.Created),
+			LastSeen:  formatTime(sess.LastSeen),
+			Expires:   formatTime(sess.Expires),
+			Remember:  sess.Remember,
+			Current:   sess.Current,
+		})
+	}
+
+	var notice string
+	switch {
+	case r.URL.Query().Get("revoked") != "":
+		notice = "Session revoked."
+	case r.URL.Query().Get("missing") != "":
+		notice = "That session has already ended."
+	}
+
+	s.renderTemplate(w, r, "sessions.tmpl", sessionsTemplateData{
+		Title:     "Sessions",
+		Sessions:  items,
+		Notice:    notice,
+		CSRFToken: s.csrfToken(r),
+	})
+}
+
+// revokeSession 撤销单个会话；撤销当前会话等同于登出。
+func (s *Server) revokeSession(w http.ResponseWriter, r *http.Request) {
+	current, _ := s.authenticated(r)
+	actor := s.actor(r)
+	token, ok := s.sessions.RemoveID(r.PathValue("id"))
+	if !ok {
+		http.Redirect(w, r, "/admin/sessions?missing=1", http.StatusSeeOther)
+		return
+	}
+	slog.InfoContext(r.Context(), "session revoked", "current", token == current)
+	s.audit(r, audit.Event{Action: audit.SessionRevoke, Actor: actor, Detail: r.PathValue("id")})
+	if token == current {
+		s.clearSession(w, r, current)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/admin/sessions?revoked=1", http.StatusSeeOther)
+}
+
+// revokeAllSessions 撤销包括当前会话在内的全部会话。
+func (s *Server) revokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	current, _ := s.authenticated(r)
+	actor := s.actor(r)
+	n := s.sessions.RemoveAll()
+	slog.InfoContext(r.Context(), "all sessions revoked", "count", n)
+	s.audit(r, audit.Event{Action: audit.SessionsRevoked, Actor: actor, Detail: fmt.Sprintf("%d sessions", n)})
+	s.clearSession(w, r, current)
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// describeUserAgent 把 User-Agent 粗略归纳为“浏览器 · 系统”，便于辨认设备。
+func describeUserAgent(ua string) string {
+	if ua == "" {
+		return "Unknown device"
+	}
+	browser := "Unknown browser"
+	for _, b := range []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+	} {
+		if strings.Contains(ua, b.token) {
+			browser = b.name
+			break
+		}
+	}
+	system := ""
+	for _, o := range []struct{ token, name string }{
+		{"iPhone", "iOS"},
+		{"iPad", "iPadOS"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	} {
+		if strings.Contains(ua, o.token) {
+			system = o.name
+			break
+		}
+	}
+	if system == "" {
+		return browser
+	}
+	return browser + " · " + system
+}
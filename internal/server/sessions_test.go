@@ -0,0 +1,195 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func loginWith(t *testing.T, srv *Server, form url.Values, userAgent string) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", userAgent)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: status %d", w.Code)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			return c
+		}
+	}
+	t.Fatalf("expected session cookie")
+	return nil
+}
+
+func getWithCookie(srv *Server, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestRememberMeChoosesCookieLifetime(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+
+	short := loginWith(t, srv, url.Values{"password": {"testpass"}}, "test")
+	if !short.Expires.IsZero() || short.MaxAge != 0 {
+		t.Fatalf("session without remember me should be a browser-session cookie, got expires %v", short.Expires)
+	}
+
+	long := loginWith(t, srv, url.Values{"password": {"testpass"}, "remember": {"1"}}, "test")
+	if until := time.Until(long.Expires); until < defaultRememberTTL-time.Minute || until > defaultRememberTTL+time.Minute {
+		t.Fatalf("remembered cookie expires in %v, want ~%v", until, defaultRememberTTL)
+	}
+
+	for _, sess := range srv.sessions.List("") {
+		want := defaultSessionTTL
+		if sess.Remember {
+			want = defaultRememberTTL
+		}
+		if got := sess.Expires.Sub(sess.LastSeen); got != want {
+			t.Errorf("remember=%v: ttl %v, want %v", sess.Remember, got, want)
+		}
+	}
+}
+
+func TestSessionSlidingExpiry(t *testing.T) {
+	store := newSessionStore(time.Hour, 0)
+	token, _ := store.Create(sessionMeta{IP: "192.0.2.1"})
+	start := time.Now()
+
+	// 节流期内不顺延
+	if _, renewed, ok := store.Touch(token, start.Add(10*time.Second)); !ok || renewed {
+		t.Fatalf("touch within interval: ok %v renewed %v", ok, renewed)
+	}
+	// 活动会顺延闲置超时，超过原定的一小时仍然有效
+	sess, renewed, ok := store.Touch(token, start.Add(50*time.Minute))
+	if !ok || !renewed || sess.expires.Before(start.Add(109*time.Minute)) {
+		t.Fatalf("touch: ok %v renewed %v expires %v", ok, renewed, sess.expires)
+	}
+	if _, _, ok := store.Touch(token, start.Add(100*time.Minute)); !ok {
+		t.Fatalf("session should still be valid after sliding")
+	}
+	// 闲置超过 TTL 后失效
+	if _, _, ok := store.Touch(token, start.Add(4*time.Hour)); ok {
+		t.Fatalf("idle session should expire")
+	}
+	if store.Count() != 0 {
+		t.Fatalf("expired session should be removed")
+	}
+}
+
+// TestRememberedCookieRenewedAfterTouchInterval 验证经完整中间件链的请求在节流间隔后
+// 续发持久 cookie：访问日志与 CSRF 的会话查询不得抢先顺延会话。
+func TestRememberedCookieRenewedAfterTouchInterval(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	cookie := loginWith(t, srv, url.Values{"password": {"testpass"}, "remember": {"1"}}, "test")
+
+	// 模拟距上次活动已超过节流间隔。
+	srv.sessions.mu.Lock()
+	sess := srv.sessions.sessions[cookie.Value]
+	sess.lastSeen = sess.lastSeen.Add(-2 * sessionTouchInterval)
+	sess.expires = sess.expires.Add(-2 * sessionTouchInterval)
+	srv.sessions.sessions[cookie.Value] = sess
+	srv.sessions.mu.Unlock()
+
+	w := getWithCookie(srv, "/admin", cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("admin: status %d", w.Code)
+	}
+	var renewed *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			renewed = c
+		}
+	}
+	if renewed == nil || renewed.Value != cookie.Value || time.Until(renewed.Expires) < defaultRememberTTL-time.Minute {
+		t.Fatalf("expected a renewed persistent cookie, got %+v", renewed)
+	}
+
+	// 节流期内不再续发。
+	w = getWithCookie(srv, "/admin", cookie)
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			t.Fatalf("cookie reissued within the touch interval")
+		}
+	}
+}
+
+func TestSessionsPageAndRevoke(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	laptop := loginWith(t, srv, url.Values{"password": {"testpass"}}, "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) Gecko/20100101 Firefox/125.0")
+	phone := loginWith(t, srv, url.Values{"password": {"testpass"}}, "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) Version/17.0 Mobile/15E148 Safari/604.1")
+
+	w := getWithCookie(srv, "/admin/sessions", laptop)
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.Contains(body, "Firefox · macOS") || !strings.Contains(body, "Safari · iOS") {
+		t.Fatalf("sessions page: status %d, body %s", w.Code, body)
+	}
+	if strings.Count(body, "This device") != 1 || !strings.Contains(body, "192.0.2.1") {
+		t.Fatalf("expected current device marker and IP")
+	}
+
+	var phoneID string
+	for _, sess := range srv.sessions.List(phone.Value) {
+		if sess.Current {
+			phoneID = sess.ID
+		}
+	}
+	csrf := srv.sessions.CSRFToken(laptop.Value)
+	w = postForm(srv, "/admin/sessions/"+phoneID+"/revoke", url.Values{"csrf_token": {csrf}}, laptop, "")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/sessions?revoked=1" {
+		t.Fatalf("revoke: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := getWithCookie(srv, "/admin", phone); w.Code != http.StatusFound {
+		t.Fatalf("revoked session still works: status %d", w.Code)
+	}
+	if w := getWithCookie(srv, "/admin", laptop); w.Code != http.StatusOK {
+		t.Fatalf("current session should survive: status %d", w.Code)
+	}
+	// 已撤销的会话再次撤销
+	w = postForm(srv, "/admin/sessions/"+phoneID+"/revoke", url.Values{"csrf_token": {csrf}}, laptop, "")
+	if w.Header().Get("Location") != "/admin/sessions?missing=1" {
+		t.Fatalf("revoke missing: location %q", w.Header().Get("Location"))
+	}
+}
+
+func TestLogOutEverywhere(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	a := loginWith(t, srv, url.Values{"password": {"testpass"}}, "a")
+	b := loginWith(t, srv, url.Values{"password": {"testpass"}, "remember": {"1"}}, "b")
+
+	w := postForm(srv, "/admin/sessions/revoke-all", url.Values{"csrf_token": {srv.sessions.CSRFToken(a.Value)}}, a, "")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login" {
+		t.Fatalf("revoke all: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	for _, c := range []*http.Cookie{a, b} {
+		if w := getWithCookie(srv, "/admin", c); w.Code != http.StatusFound {
+			t.Fatalf("session survived log out everywhere: status %d", w.Code)
+		}
+	}
+	if srv.sessions.Count() != 0 {
+		t.Fatalf("expected no sessions, got %d", srv.sessions.Count())
+	}
+}
+
+func TestDescribeUserAgent(t *testing.T) {
+	cases := map[string]string{
+		"": "Unknown device",
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36 Edg/124.0": "Edge · Windows",
+		"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0 Safari/537.36":                     "Chrome · Linux",
+		"curl/8.5.0": "curl",
+	}
+	for ua, want := range cases {
+		if got := describeUserAgent(ua); got != want {
+			t.Errorf("describeUserAgent(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/content"
+)
+
+// trashPurgeInterval 为后台自动清理过期回收站条目的周期。
+const trashPurgeInterval = time.Hour
+
+// systemActor 为非管理员触发的操作（如到期自动清理）在审计日志中的操作者。
+const systemActor = "system"
+
+type trashTemplateData struct {
+	Title     string
+	Entries   []trashItem
+	Retention string
+	Notice    string
+	CSRFToken string
+}
+
+type trashItem struct {
+	Slug        string
+	Renderer    content.RendererType
+	Description string
+	DeletedAt   string
+	PurgeAt     string
+}
+
+// showTrash 列出回收站中的条目，先清理已过保留期的条目。
+func (s *Server) showTrash(w http.ResponseWriter, r *http.Request) {
+	s.purgeExpiredTrash(r.Context())
+	trashed, err := s.store.Trash()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "list trash", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Trash", "Failed to load the trash.")
+		return
+	}
+
+	data := trashTemplateData{Title: "Trash", CSRFToken: s.csrfToken(r)}
+	if s.cfg.TrashRetention > 0 {
+		data.Retention = formatRetention(s.cfg.TrashRetention)
+	}
+	for _, item := range trashed {
+		description := strings.TrimSpace(item.Description)
+		if description == "" {
+			description = summarize(item.Raw, 140)
+		}
+		ti := trashItem{
+			Slug:        item.Slug,
+			Renderer:    item.Renderer,
+			Description: description,
+			DeletedAt:   formatTime(item.DeletedAt),
+		}
+		if s.cfg.TrashRetention > 0 {
+			ti.PurgeAt = formatTime(item.DeletedAt.Add(s.cfg.TrashRetention))
+		}
+		data.Entries = append(data.Entries, ti)
+	}
+
+	q := r.URL.Query()
+	switch {
+	case q.Get("restored") != "":
+		data.Notice = "Restored " + q.Get("restored")
+	case q.Get("purged") != "":
+		data.Notice = "Permanently deleted " + q.Get("purged")
+	case q.Get("missing") != "":
+		data.Notice = q.Get("missing") + " is no longer in the trash"
+	case q.Get("conflict") != "":
+		data.Notice = "Cannot restore " + q.Get("conflict") + ": an entry with the same slug already exists"
+	}
+	s.renderTemplate(w, r, "trash.tmpl", data)
+}
+
+// restoreEntry 把条目从回收站恢复为已发布内容。
+func (s *Server) restoreEntry(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	entry, err := s.store.Restore(slug)
+	switch {
+	case errors.Is(err, content.ErrEntryNotFound):
+		http.Redirect(w, r, "/admin/trash?missing="+url.QueryEscape(slug), http.StatusSeeOther)
+		return
+	case errors.Is(err, content.ErrEntryExists):
+		// 同名条目已存在，保留回收站中的副本，由管理员决定如何处理。
+		http.Redirect(w, r, "/admin/trash?conflict="+url.QueryEscape(slug), http.StatusSeeOther)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "restore entry", "slug", slug, "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Trash", "Failed to restore the entry.")
+		return
+	}
+	s.audit(r, audit.Event{Action: audit.EntryRestore, Slug: slug, Hash: audit.ContentHash(entry.Raw)})
+	http.Redirect(w, r, "/admin/trash?restored="+url.QueryEscape(slug), http.StatusSeeOther)
+}
+
+// purgeEntry 从回收站永久删除条目，无法恢复。
+func (s *Server) purgeEntry(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	item, err := s.store.Purge(slug)
+	switch {
+	case errors.Is(err, content.ErrEntryNotFound):
+		http.Redirect(w, r, "/admin/trash?missing="+url.QueryEscape(slug), http.StatusSeeOther)
+		return
+	case err != nil:
+		slog.ErrorContext(r.Context(), "purge entry", "slug", slug, "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Trash", "Failed to delete the entry.")
+		return
+	}
+	s.audit(r, audit.Event{Action: audit.EntryPurge, Slug: slug, Hash: audit.ContentHash(item.Raw)})
+	s.cleanupOrphanFiles(r.Context())
+	http.Redirect(w, r, "/admin/trash?purged="+url.QueryEscape(slug), http.StatusSeeOther)
+}
+
+// RunTrashPurge 立即并按 trashPurgeInterval 周期清理过期的回收站条目，直到 ctx 结束。
+// 未配置保留期时直接返回。
+func (s *Server) RunTrashPurge(ctx context.Context) {
+	if s.cfg.TrashRetention <= 0 {
+		return
+	}
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+	for {
+		s.purgeExpiredTrash(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeExpiredTrash 永久删除超过保留期的回收站条目，并逐条写入审计日志。
+func (s *Server) purgeExpiredTrash(ctx context.Context) {
+	if s.cfg.TrashRetention <= 0 {
+		return
+	}
+	purged, err := s.store.PurgeBefore(time.Now().Add(-s.cfg.TrashRetention))
+	if err != nil {
+		slog.ErrorContext(ctx, "purge expired trash", "error", err)
+	}
+	for _, item := range purged {
+		e := audit.Event{Action: audit.EntryPurge, Actor: systemActor, Slug: item.Slug, Hash: audit.ContentHash(item.Raw), Detail: "retention expired"}
+		if err := s.auditLog.Append(e); err != nil {
+			slog.ErrorContext(ctx, "write audit log", "action", e.Action, "error", err)
+		}
+	}
+	if len(purged) > 0 {
+		slog.InfoContext(ctx, "purged expired trash", "count", len(purged))
+		s.cleanupOrphanFiles(ctx)
+	}
+}
+
+// formatRetention 以天或小时展示保留期。
+func formatRetention(d time.Duration) string {
+	if d >= 24*time.Hour && d%(24*time.Hour) == 0 {
+		days := int(d / (24 * time.Hour))
+		if days == 1 {
+			return "1 day"
+		}
+		return strconv.Itoa(days) + " days"
+	}
+	return d.String()
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/config"
+	"minisnap/internal/content"
+)
+
+func TestDeleteMovesEntryToTrash(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+
+	w := postForm(srv, "/"+entry.Slug+"/delete", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/library?trashed="+entry.Slug {
+		t.Fatalf("delete: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := getWithCookie(srv, "/"+entry.Slug, cookie); w.Code != http.StatusNotFound {
+		t.Fatalf("trashed entry should not be public: status %d", w.Code)
+	}
+	if w := getWithCookie(srv, "/admin/library?trashed="+entry.Slug, cookie); !strings.Contains(w.Body.String(), "Moved "+entry.Slug+" to the trash") {
+		t.Fatalf("library should confirm the move to trash")
+	}
+
+	w = getWithCookie(srv, "/admin/trash", cookie)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "/admin/trash/"+entry.Slug+"/restore") {
+		t.Fatalf("trash page: status %d, body %s", w.Code, w.Body.String())
+	}
+
+	w = postForm(srv, "/admin/trash/"+entry.Slug+"/restore", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/trash?restored="+entry.Slug {
+		t.Fatalf("restore: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := getWithCookie(srv, "/"+entry.Slug, cookie); w.Code != http.StatusOK {
+		t.Fatalf("restored entry should be public again: status %d", w.Code)
+	}
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.EntryRestore, Slug: entry.Slug}); len(got) != 1 {
+		t.Fatalf("expected restore audit event, got %+v", got)
+	}
+}
+
+// TestRestoreConflict 验证同名条目已存在时恢复被拒绝，并回到回收站给出提示。
+func TestRestoreConflict(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+	data, err := os.ReadFile(filepath.Join(srv.store.Dir(), entry.Slug+".json"))
+	if err != nil {
+		t.Fatalf("read entry: %v", err)
+	}
+	if err := srv.store.Delete(entry.Slug); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	// 模拟回收站条目的 slug 又被占用。
+	if err := os.WriteFile(filepath.Join(srv.store.Dir(), entry.Slug+".json"), data, 0o644); err != nil {
+		t.Fatalf("write entry: %v", err)
+	}
+
+	w := postForm(srv, "/admin/trash/"+entry.Slug+"/restore", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/trash?conflict="+entry.Slug {
+		t.Fatalf("restore conflict: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if w := getWithCookie(srv, "/admin/trash?conflict="+entry.Slug, cookie); !strings.Contains(w.Body.String(), "Cannot restore "+entry.Slug) {
+		t.Fatalf("trash page should explain the conflict")
+	}
+	if trash, _ := srv.store.Trash(); len(trash) != 1 {
+		t.Fatalf("conflicting entry should stay in the trash, got %d", len(trash))
+	}
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.EntryRestore}); len(got) != 0 {
+		t.Fatalf("failed restore must not be audited: %+v", got)
+	}
+}
+
+func TestPurgeFromTrash(t *testing.T) {
+	srv, entry := newCSRFTestServer(t)
+	cookie, csrf := newTestSession(t, srv)
+	if err := srv.store.Delete(entry.Slug); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	w := postForm(srv, "/admin/trash/"+entry.Slug+"/delete", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/admin/trash?purged="+entry.Slug {
+		t.Fatalf("purge: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	w = postForm(srv, "/admin/trash/"+entry.Slug+"/restore", url.Values{csrfFieldName: {csrf}}, cookie, "")
+	if w.Header().Get("Location") != "/admin/trash?missing="+entry.Slug {
+		t.Fatalf("restore after purge: location %q", w.Header().Get("Location"))
+	}
+	got := auditEvents(t, srv, audit.Filter{Action: audit.EntryPurge})
+	if len(got) != 1 || got[0].Actor != adminUser || got[0].Hash != audit.ContentHash(entry.Raw) {
+		t.Fatalf("unexpected purge audit events: %+v", got)
+	}
+}
+
+func TestTrashRetention(t *testing.T) {
+	dir := t.TempDir()
+	store, err := content.NewStore(dir)
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	srv, err := New(config.Config{AdminPassword: "testpass", TrashRetention: 24 * time.Hour}, store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	old, _ := store.Create(content.RendererMarkdown, "old", "")
+	fresh, _ := store.Create(content.RendererMarkdown, "fresh", "")
+	for _, e := range []content.Entry{old, fresh} {
+		if err := store.Delete(e.Slug); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+	}
+	// 把其中一条的删除时间改到保留期之前
+	path := filepath.Join(dir, ".trash", old.Slug+".json")
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read trash file: %v", err)
+	}
+	stale := strings.Replace(string(raw), `"deleted_at": "`+time.Now().UTC().Format("2006"), `"deleted_at": "2000`, 1)
+	if err := os.WriteFile(path, []byte(stale), 0o644); err != nil {
+		t.Fatalf("write trash file: %v", err)
+	}
+
+	srv.purgeExpiredTrash(context.Background())
+
+	trash, err := store.Trash()
+	if err != nil || len(trash) != 1 || trash[0].Slug != fresh.Slug {
+		t.Fatalf("expected only the fresh entry to remain: %v %+v", err, trash)
+	}
+	got := auditEvents(t, srv, audit.Filter{Action: audit.EntryPurge})
+	if len(got) != 1 || got[0].Slug != old.Slug || got[0].Actor != systemActor {
+		t.Fatalf("unexpected purge audit events: %+v", got)
+	}
+}
@@ -0,0 +1,414 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/totp"
+)
+
+const (
+	mfaCookieName = "minisnap_mfa"
+	// mfaChallengeTTL 为密码通过后输入验证码的时限。
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaMaxAttempts 为单次登录挑战允许的验证码错误次数，超过需重新输入密码。
+	mfaMaxAttempts = 5
+
+	totpIssuer = "minisnap"
+
+	// totpStateFile 为加密的二次验证状态；totpKeyFile 为未配置 SECRET_KEY 时随机生成的加密密钥。
+	totpStateFile = "totp.json"
+	totpKeyFile   = "secret.key"
+)
+
+// errTOTPUnreadable 为二次验证状态无法解密时展示给用户的说明。
+const errTOTPUnreadable = "Two-factor authentication is enabled, but its data cannot be decrypted with the configured key. Run `minisnap totp-reset` on the server to turn it off, then sign in again."
+
+var errInvalidCode = errors.New("invalid verification code")
+
+// authStatePath 返回内容目录中保存认证相关状态的文件路径。
+func authStatePath(contentDir, name string) string {
+	return filepath.Join(contentDir, ".auth", name)
+}
+
+// mfaChallenge 是密码已验证、等待第二步验证码的登录。
+type mfaChallenge struct {
+	expires  time.Time
+	next     string
+	remember bool
+	attempts int
+}
+
+type mfaChallenges struct {
+	mu      sync.Mutex
+	pending map[string]*mfaChallenge
+}
+
+func newMFAChallenges() *mfaChallenges {
+	return &mfaChallenges{pending: make(map[string]*mfaChallenge)}
+}
+
+func (c *mfaChallenges) create(next string, remember bool) string {
+	token := newToken()
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for t, ch := range c.pending {
+		if now.After(ch.expires) {
+			delete(c.pending, t)
+		}
+	}
+	c.pending[token] = &mfaChallenge{expires: now.Add(mfaChallengeTTL), next: next, remember: remember}
+	return token
+}
+
+func (c *mfaChallenges) get(token string) (*mfaChallenge, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.pending[token]
+	if !ok || time.Now().After(ch.expires) {
+		delete(c.pending, token)
+		return nil, false
+	}
+	return ch, true
+}
+
+// fail 记录一次验证码错误，次数耗尽时作废挑战并返回 false。
+func (c *mfaChallenges) fail(token string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	ch, ok := c.pending[token]
+	if !ok {
+		return false
+	}
+	ch.attempts++
+	if ch.attempts >= mfaMaxAttempts {
+		delete(c.pending, token)
+		return false
+	}
+	return true
+}
+
+func (c *mfaChallenges) remove(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, token)
+}
+
+// newTOTPStore 打开保存在内容目录中的加密 TOTP 状态。密钥取 SECRET_KEY，未配置时使用
+// .auth 下随机生成的密钥；密钥不从管理员密码派生，修改密码或改用哈希配置都不影响已启用的二次验证。
+func (s *Server) newTOTPStore() (*totp.Store, error) {
+	key := []byte(s.cfg.SecretKey)
+	if len(key) == 0 {
+		var err error
+		if key, err = totp.LoadOrCreateKey(authStatePath(s.store.Dir(), totpKeyFile)); err != nil {
+			return nil, fmt.Errorf("load totp key: %w", err)
+		}
+	}
+	store, err := totp.NewStore(authStatePath(s.store.Dir(), totpStateFile), key)
+	if err != nil {
+		return nil, err
+	}
+
+	// 启动时即检查状态能否解密，密钥不匹配时在日志中给出恢复方法。
+	switch _, err := store.Load(); {
+	case errors.Is(err, totp.ErrUndecryptable):
+		slog.Error("two-factor state cannot be decrypted; password logins fail until it is reset with `minisnap totp-reset`",
+			"path", authStatePath(s.store.Dir(), totpStateFile))
+	case err != nil && !errors.Is(err, totp.ErrNotEnrolled):
+		slog.Error("check two-factor state", "error", err)
+	}
+	return store, nil
+}
+
+// ResetTwoFactor 删除内容目录中的二次验证状态并写入审计日志，供 totp-reset 子命令在
+// 密钥丢失、状态无法解密时恢复密码登录。返回 false 表示原本就未启用。
+func ResetTwoFactor(contentDir string) (bool, error) {
+	err := os.Remove(authStatePath(contentDir, totpStateFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("remove totp state: %w", err)
+	}
+	e := audit.Event{Action: audit.TOTPDisable, Actor: systemActor, Detail: "totp-reset command"}
+	if err := audit.New(audit.Path(contentDir)).Append(e); err != nil {
+		return true, fmt.Errorf("write audit log: %w", err)
+	}
+	return true, nil
+}
+
+// startSecondFactor 在密码通过后发起验证码挑战，渲染登录页的第二步。
+func (s *Server) startSecondFactor(w http.ResponseWriter, r *http.Request, next string, remember bool) {
+	token := s.mfa.create(next, remember)
+	http.SetCookie(w, &http.Cookie{
+		Name:     mfaCookieName,
+		Value:    token,
+		Path:     "/login",
+		HttpOnly: true,
+		Secure:   s.secureCookie(r),
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   int(mfaChallengeTTL / time.Second),
+	})
+	s.renderTemplate(w, r, "login.tmpl", map[string]any{
+		"Title": "Two-factor authentication",
+		"Step":  "totp",
+	})
+}
+
+func (s *Server) clearMFACookie(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     mfaCookieName,
+		Value:    "",
+		Path:     "/login",
+		HttpOnly: true,
+		Secure:   s.secureCookie(r),
+		SameSite: http.SameSiteStrictMode,
+		MaxAge:   -1,
+	})
+}
+
+// handleLoginTOTP 处理登录第二步：校验验证码或恢复码。
+func (s *Server) handleLoginTOTP(w http.ResponseWriter, r *http.Request) {
+	renderStep := func(status int, msg string) {
+		s.renderTemplateStatus(w, r, status, "login.tmpl", map[string]any{
+			"Title": "Two-factor authentication",
+			"Step":  "totp",
+			"Error": msg,
+		})
+	}
+
+	cookie, err := r.Cookie(mfaCookieName)
+	var ch *mfaChallenge
+	if err == nil {
+		ch, _ = s.mfa.get(cookie.Value)
+	}
+	if ch == nil {
+		s.clearMFACookie(w, r)
+		s.renderTemplateStatus(w, r, http.StatusUnauthorized, "login.tmpl", map[string]any{
+			"Title": "Login",
+			"Error": "Verification expired, please sign in again",
+		})
+		return
+	}
+
+	clientIP := s.clientIP(r)
+	// 密码已在上一步通过，且每个挑战的尝试次数有限，这里只检查该 IP 的锁定。
+	if s.loginLim.isLocked(clientIP, time.Now()) {
+		renderStep(http.StatusTooManyRequests, "Too many failed attempts, please try again later")
+		return
+	}
+
+	code := r.FormValue("code")
+	usedRecovery := false
+	err = s.totp.Update(func(st *totp.State) error {
+		if st.Verify(code, time.Now()) {
+			return nil
+		}
+		if st.UseRecoveryCode(code) {
+			usedRecovery = true
+			return nil
+		}
+		return errInvalidCode
+	})
+	if err != nil && !errors.Is(err, errInvalidCode) {
+		// 状态无法读取不是验证码错误，不计入失败次数，明确提示恢复方法。
+		slog.ErrorContext(r.Context(), "verify totp", "error", err)
+		msg := "Two-factor data could not be read, please try again later"
+		if errors.Is(err, totp.ErrUndecryptable) {
+			msg = errTOTPUnreadable
+		}
+		renderStep(http.StatusServiceUnavailable, msg)
+		return
+	}
+	if err != nil {
+		s.loginFailed(r, "totp")
+		if !s.mfa.fail(cookie.Value) {
+			s.clearMFACookie(w, r)
+			s.renderTemplateStatus(w, r, http.StatusUnauthorized, "login.tmpl", map[string]any{
+				"Title": "Login",
+				"Error": "Too many invalid codes, please sign in again",
+			})
+			return
+		}
+		renderStep(http.StatusUnauthorized, "Invalid verification code")
+		return
+	}
+	if usedRecovery {
+		slog.WarnContext(r.Context(), "recovery code used for login")
+	}
+
+	s.mfa.remove(cookie.Value)
+	s.clearMFACookie(w, r)
+	s.loginLim.recordSuccess(clientIP)
+	s.setSession(w, r, ch.remember)
+	method := "password+totp"
+	if usedRecovery {
+		method = "password+recovery code"
+	}
+	s.audit(r, audit.Event{Action: audit.LoginSuccess, Actor: adminUser, Detail: method})
+	http.Redirect(w, r, ch.next, http.StatusFound)
+}
+
+type securityTemplateData struct {
+	Title          string
+	Enabled        bool
+	RemainingCodes int
+	QRCode         template.HTML
+	Secret         string
+	RecoveryCodes  []string
+	Error          string
+	Notice         string
+	PasswordError  string
+	CSRFToken      string
+}
+
+// showSecurity 展示二次验证状态；未启用时生成待确认的密钥与二维码。
+func (s *Server) showSecurity(w http.ResponseWriter, r *http.Request) {
+	s.renderSecurity(w, r, http.StatusOK, securityTemplateData{})
+}
+
+func (s *Server) renderSecurity(w http.ResponseWriter, r *http.Request, status int, data securityTemplateData) {
+	data.Title = "Security"
+	data.CSRFToken = s.csrfToken(r)
+
+	st, err := s.totp.Load()
+	switch {
+	case err == nil:
+		data.Enabled = true
+		data.RemainingCodes = len(st.RecoveryCodes)
+	case errors.Is(err, totp.ErrNotEnrolled):
+		token, _ := s.authenticated(r)
+		secret := s.sessions.PendingTOTP(token)
+		if secret == nil {
+			if secret, err = totp.GenerateSecret(); err != nil {
+				slog.ErrorContext(r.Context(), "generate totp secret", "error", err)
+				s.renderErrorPage(w, r, http.StatusInternalServerError, "Security", "Failed to generate a secret.")
+				return
+			}
+			s.sessions.SetPendingTOTP(token, secret)
+		}
+		svg, err := totp.QRCodeSVG(totp.URI(totpIssuer, adminUser, secret))
+		if err != nil {
+			slog.ErrorContext(r.Context(), "render totp qr code", "error", err)
+		}
+		data.QRCode = template.HTML(svg) // 由本地编码器生成，只含固定的 SVG 元素
+		data.Secret = totp.EncodeSecret(secret)
+	default:
+		slog.ErrorContext(r.Context(), "load totp state", "error", err)
+		msg := "Two-factor settings could not be read."
+		if errors.Is(err, totp.ErrUndecryptable) {
+			msg = errTOTPUnreadable
+		}
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Security", msg)
+		return
+	}
+
+	s.renderTemplateStatus(w, r, status, "security.tmpl", data)
+}
+
+// enableTOTP 用待确认密钥校验一次验证码后启用二次验证，并展示恢复码。
+func (s *Server) enableTOTP(w http.ResponseWriter, r *http.Request) {
+	token, _ := s.authenticated(r)
+	secret := s.sessions.PendingTOTP(token)
+	if secret == nil || s.totp.Enabled() {
+		http.Redirect(w, r, "/admin/security", http.StatusSeeOther)
+		return
+	}
+	st := &totp.State{Secret: secret, EnabledAt: time.Now().UTC()}
+	if !st.Verify(r.FormValue("code"), time.Now()) {
+		s.renderSecurity(w, r, http.StatusUnprocessableEntity, securityTemplateData{Error: "Invalid verification code"})
+		return
+	}
+	plain, hashes, err := totp.NewRecoveryCodes()
+	if err == nil {
+		st.RecoveryCodes = hashes
+		err = s.totp.Save(st)
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "enable totp", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Security", "Failed to save two-factor settings.")
+		return
+	}
+	s.sessions.SetPendingTOTP(token, nil)
+	slog.InfoContext(r.Context(), "two-factor authentication enabled")
+	s.audit(r, audit.Event{Action: audit.TOTPEnable})
+	s.renderSecurity(w, r, http.StatusOK, securityTemplateData{
+		Notice:        "Two-factor authentication is on. Save these recovery codes now; they will not be shown again.",
+		RecoveryCodes: plain,
+	})
+}
+
+// disableTOTP 需提供当前验证码或恢复码才能关闭二次验证。
+func (s *Server) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.verifyCurrentFactor(r.FormValue("code")); err != nil {
+		s.renderSecurityError(w, r, err)
+		return
+	}
+	if err := s.totp.Remove(); err != nil {
+		slog.ErrorContext(r.Context(), "disable totp", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Security", "Failed to disable two-factor authentication.")
+		return
+	}
+	slog.InfoContext(r.Context(), "two-factor authentication disabled")
+	s.audit(r, audit.Event{Action: audit.TOTPDisable})
+	s.renderSecurity(w, r, http.StatusOK, securityTemplateData{Notice: "Two-factor authentication is off."})
+}
+
+// regenerateRecoveryCodes 作废旧恢复码并生成新的一组。
+func (s *Server) regenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	plain, hashes, err := totp.NewRecoveryCodes()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "generate recovery codes", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Security", "Failed to generate recovery codes.")
+		return
+	}
+	code := r.FormValue("code")
+	err = s.totp.Update(func(st *totp.State) error {
+		if !st.Verify(code, time.Now()) {
+			return errInvalidCode
+		}
+		st.RecoveryCodes = hashes
+		return nil
+	})
+	if err != nil {
+		s.renderSecurityError(w, r, err)
+		return
+	}
+	slog.InfoContext(r.Context(), "recovery codes regenerated")
+	s.audit(r, audit.Event{Action: audit.TOTPRecovery})
+	s.renderSecurity(w, r, http.StatusOK, securityTemplateData{
+		Notice:        "New recovery codes generated; the previous ones no longer work.",
+		RecoveryCodes: plain,
+	})
+}
+
+// verifyCurrentFactor 校验并消耗一个验证码或恢复码。
+func (s *Server) verifyCurrentFactor(code string) error {
+	return s.totp.Update(func(st *totp.State) error {
+		if st.Verify(code, time.Now()) || st.UseRecoveryCode(code) {
+			return nil
+		}
+		return errInvalidCode
+	})
+}
+
+func (s *Server) renderSecurityError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, errInvalidCode):
+		s.renderSecurity(w, r, http.StatusUnprocessableEntity, securityTemplateData{Error: "Invalid verification code"})
+	case errors.Is(err, totp.ErrNotEnrolled):
+		http.Redirect(w, r, "/admin/security", http.StatusSeeOther)
+	default:
+		slog.ErrorContext(r.Context(), "update totp state", "error", err)
+		s.renderErrorPage(w, r, http.StatusInternalServerError, "Security", "Two-factor settings could not be updated.")
+	}
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"minisnap/internal/audit"
+	"minisnap/internal/config"
+	"minisnap/internal/password"
+	"minisnap/internal/totp"
+)
+
+// enrollTOTP 通过后台页面完成启用流程，返回密钥与恢复码。
+func enrollTOTP(t *testing.T, srv *Server) ([]byte, []string) {
+	t.Helper()
+	cookie, csrf := newTestSession(t, srv)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/security", nil)
+	req.AddCookie(cookie)
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "<svg") {
+		t.Fatalf("security page: status %d, missing QR code", w.Code)
+	}
+	secret := srv.sessions.PendingTOTP(cookie.Value)
+	if secret == nil {
+		t.Fatalf("expected pending secret in session")
+	}
+	if !strings.Contains(w.Body.String(), totp.EncodeSecret(secret)) {
+		t.Fatalf("expected manual entry key on page")
+	}
+
+	// 错误验证码不会启用
+	w = postForm(srv, "/admin/security/totp/enable", url.Values{"csrf_token": {csrf}, "code": {"000000"}}, cookie, "")
+	if w.Code != http.StatusUnprocessableEntity || srv.totp.Enabled() {
+		t.Fatalf("invalid code: status %d, enabled %v", w.Code, srv.totp.Enabled())
+	}
+
+	w = postForm(srv, "/admin/security/totp/enable", url.Values{"csrf_token": {csrf}, "code": {totp.Code(secret, time.Now())}}, cookie, "")
+	if w.Code != http.StatusOK || !srv.totp.Enabled() {
+		t.Fatalf("enable: status %d, enabled %v", w.Code, srv.totp.Enabled())
+	}
+	codes := regexp.MustCompile(`<li>([a-z2-7]{5}-[a-z2-7]{5})</li>`).FindAllStringSubmatch(w.Body.String(), -1)
+	if len(codes) != totp.RecoveryCodeCount {
+		t.Fatalf("expected %d recovery codes on page, got %d", totp.RecoveryCodeCount, len(codes))
+	}
+	var plain []string
+	for _, m := range codes {
+		plain = append(plain, m[1])
+	}
+	return secret, plain
+}
+
+func passwordStep(t *testing.T, srv *Server) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("password=testpass&next=%2Fadmin%2Flibrary"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `action="/login/totp"`) {
+		t.Fatalf("password step: status %d, expected code form", w.Code)
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == sessionCookieName {
+			t.Fatalf("session must not be issued before the second factor")
+		}
+		if c.Name == mfaCookieName {
+			return c
+		}
+	}
+	t.Fatalf("expected %s cookie", mfaCookieName)
+	return nil
+}
+
+func codeStep(srv *Server, mfa *http.Cookie, code string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/login/totp", strings.NewReader(url.Values{"code": {code}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if mfa != nil {
+		req.AddCookie(mfa)
+	}
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	return w
+}
+
+func TestTOTPLoginFlow(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	secret, recovery := enrollTOTP(t, srv)
+
+	mfa := passwordStep(t, srv)
+	if w := codeStep(srv, mfa, "000000"); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong code: status %d, want 401", w.Code)
+	}
+	// 启用时已用掉当前时间步，登录需使用下一步的验证码
+	w := codeStep(srv, mfa, totp.Code(secret, time.Now().Add(totp.Period)))
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/library" {
+		t.Fatalf("valid code: status %d, location %q", w.Code, w.Header().Get("Location"))
+	}
+	if !hasCookie(w, sessionCookieName) {
+		t.Fatalf("expected session cookie after second factor")
+	}
+	// 挑战只能使用一次
+	if w := codeStep(srv, mfa, totp.Code(secret, time.Now().Add(totp.Period))); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused challenge: status %d, want 401", w.Code)
+	}
+
+	// 恢复码可登录且只能用一次
+	mfa = passwordStep(t, srv)
+	if w := codeStep(srv, mfa, recovery[0]); w.Code != http.StatusFound {
+		t.Fatalf("recovery code: status %d, want 302", w.Code)
+	}
+	mfa = passwordStep(t, srv)
+	if w := codeStep(srv, mfa, recovery[0]); w.Code != http.StatusUnauthorized {
+		t.Fatalf("reused recovery code: status %d, want 401", w.Code)
+	}
+}
+
+func TestTOTPChallengeRequired(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	secret, _ := enrollTOTP(t, srv)
+	if w := codeStep(srv, nil, totp.Code(secret, time.Now())); w.Code != http.StatusUnauthorized || hasCookie(w, sessionCookieName) {
+		t.Fatalf("code without password step: status %d", w.Code)
+	}
+}
+
+func TestTOTPChallengeAttemptLimit(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	secret, _ := enrollTOTP(t, srv)
+	mfa := passwordStep(t, srv)
+	for i := 0; i < mfaMaxAttempts; i++ {
+		codeStep(srv, mfa, "000000")
+	}
+	if w := codeStep(srv, mfa, totp.Code(secret, time.Now().Add(totp.Period))); w.Code == http.StatusFound {
+		t.Fatalf("challenge must be discarded after %d failures", mfaMaxAttempts)
+	}
+}
+
+func TestTOTPStateEncryptedAtRest(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	secret, recovery := enrollTOTP(t, srv)
+	raw, err := os.ReadFile(authStatePath(srv.store.Dir(), "totp.json"))
+	if err != nil {
+		t.Fatalf("read state: %v", err)
+	}
+	for _, plain := range []string{totp.EncodeSecret(secret), recovery[0]} {
+		if bytes.Contains(raw, []byte(plain)) {
+			t.Fatalf("state file leaks %q", plain)
+		}
+	}
+	// 内容库不会把认证状态当作条目
+	if entries, err := srv.store.List(); err != nil || len(entries) != 1 {
+		t.Fatalf("list = %d entries, %v", len(entries), err)
+	}
+}
+
+// reopenServer 以新的配置在同一内容目录上重新创建 Server，模拟修改环境变量后重启。
+func reopenServer(t *testing.T, srv *Server, cfg config.Config) *Server {
+	t.Helper()
+	next, err := New(cfg, srv.store, "../../templates")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	return next
+}
+
+// TestTOTPKeyIndependentOfPassword 验证未配置 SECRET_KEY 时密钥随机生成并持久化，
+// 更换启动时的管理员密码不影响已启用的二次验证。
+func TestTOTPKeyIndependentOfPassword(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	enrollTOTP(t, srv)
+	info, err := os.Stat(authStatePath(srv.store.Dir(), totpKeyFile))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file: %v, %v", info, err)
+	}
+
+	next := reopenServer(t, srv, config.Config{AdminPassword: "another-password"})
+	if _, err := next.totp.Load(); err != nil {
+		t.Fatalf("state after password change: %v", err)
+	}
+
+	// 按 README 的建议改用 ADMIN_PASSWORD_HASH 同样不影响。
+	hash, err := password.Hash("testpass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	next = reopenServer(t, srv, config.Config{AdminPasswordHash: hash})
+	if _, err := next.totp.Load(); err != nil {
+		t.Fatalf("state after switching to a password hash: %v", err)
+	}
+}
+
+// TestTOTPUndecryptableState 验证状态无法解密时登录给出明确说明，totp-reset 后恢复密码登录。
+func TestTOTPUndecryptableState(t *testing.T) {
+	srv, _ := newCSRFTestServer(t)
+	enrollTOTP(t, srv)
+	// 启用后才配置 SECRET_KEY，已有状态无法用新密钥解密。
+	srv = reopenServer(t, srv, config.Config{AdminPassword: "testpass", SecretKey: "a-different-secret-key"})
+
+	req := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("password=testpass"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "totp-reset") {
+		t.Fatalf("login with undecryptable state: status %d", w.Code)
+	}
+
+	if removed, err := ResetTwoFactor(srv.store.Dir()); err != nil || !removed {
+		t.Fatalf("reset: %v, %v", removed, err)
+	}
+	if got := auditEvents(t, srv, audit.Filter{Action: audit.TOTPDisable}); len(got) != 1 || got[0].Actor != systemActor {
+		t.Fatalf("expected a system totp disable event, got %+v", got)
+	}
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest(http.MethodPost, "/login", strings.NewReader("password=testpass"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	srv.ServeHTTP(w, req)
+	if w.Code != http.StatusFound || !hasCookie(w, sessionCookieName) {
+		t.Fatalf("login after reset: status %d", w.Code)
+	}
+}
+
+func hasCookie(w *httptest.ResponseRecorder, name string) bool {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name && c.Value != "" {
+			return true
+		}
+	}
+	return false
+}
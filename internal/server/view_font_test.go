@@ -39,14 +39,14 @@ func TestViewPageRendering(t *testing.T) {
 	body := w.Body.String()
 
 	for _, want := range []string{
-		`id="font-down"`,            // 字号减
-		`id="font-up"`,              // 字号加
-		`data-theme-toggle`,         // 主题切换按钮（theme.js 接管）
-		`href="/-/static/base.css"`, // 公共 CSS
-		`src="/-/static/theme.js"`,  // 公共 JS
-		`minisnap.theme`,            // FOUC 主题脚本
-		`minisnap.font`,             // FOUC 字号脚本
-		`data-font="`,               // data-font 默认值
+		`id="font-down"`,                      // 字号减
+		`id="font-up"`,                        // 字号加
+		`data-theme-toggle`,                   // 主题切换按钮（theme.js 接管）
+		`href="` + assetURL("base.css") + `"`, // 公共 CSS（带内容哈希）
+		`src="` + assetURL("theme.js") + `"`,  // 公共 JS（带内容哈希）
+		`minisnap.theme`,                      // FOUC 主题脚本
+		`minisnap.font`,                       // FOUC 字号脚本
+		`data-font="`,                         // data-font 默认值
 	} {
 		if !strings.Contains(body, want) {
 			t.Errorf("expected %q in view page output", want)
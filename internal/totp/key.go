@@ -0,0 +1,61 @@
+package totp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// keySize 为随机生成的加密密钥字节数。
+const keySize = 32
+
+// LoadOrCreateKey 读取 path 中的十六进制密钥；文件不存在时生成随机密钥并以 0600 权限写入。
+// 密钥与任何登录凭据无关，修改或改用哈希配置管理员密码都不会影响已加密的状态。
+func LoadOrCreateKey(path string) ([]byte, error) {
+	key, err := readKey(path)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+
+	key = make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create key dir: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		// 并发启动时另一个进程已写入，以对方的密钥为准。
+		if errors.Is(err, os.ErrExist) {
+			return readKey(path)
+		}
+		return nil, fmt.Errorf("create key: %w", err)
+	}
+	_, werr := f.WriteString(hex.EncodeToString(key) + "\n")
+	if cerr := f.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("write key: %w", werr)
+	}
+	return key, nil
+}
+
+// readKey 读取 LoadOrCreateKey 写入的密钥文件。
+func readKey(path string) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := hex.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("invalid key file %s", path)
+	}
+	return key, nil
+}
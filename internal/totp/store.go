@@ -0,0 +1,214 @@
+package totp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecoveryCodeCount 为每次生成的恢复码数量。
+const RecoveryCodeCount = 10
+
+// ErrNotEnrolled 表示尚未启用二次验证。
+var ErrNotEnrolled = errors.New("totp: not enrolled")
+
+// ErrUndecryptable 表示状态文件存在，但无法用当前密钥解密（密钥被更换或文件损坏）。
+var ErrUndecryptable = errors.New("totp: state cannot be decrypted with the configured key")
+
+// State 是持久化的二次验证状态。恢复码只保存 SHA-256 摘要。
+type State struct {
+	Secret        []byte    `json:"secret"`
+	RecoveryCodes []string  `json:"recovery_codes"`
+	LastStep      int64     `json:"last_step"`
+	EnabledAt     time.Time `json:"enabled_at"`
+}
+
+// Verify 校验验证码并记录时间步；同一时间步内的验证码只能使用一次。
+func (st *State) Verify(code string, now time.Time) bool {
+	step, ok := Validate(st.Secret, code, now)
+	if !ok || step <= st.LastStep {
+		return false
+	}
+	st.LastStep = step
+	return true
+}
+
+// UseRecoveryCode 校验并消耗一个恢复码。
+func (st *State) UseRecoveryCode(code string) bool {
+	sum := hashRecoveryCode(code)
+	for i, h := range st.RecoveryCodes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(sum)) == 1 {
+			st.RecoveryCodes = append(st.RecoveryCodes[:i], st.RecoveryCodes[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// NewRecoveryCodes 生成一组恢复码，返回展示给用户的明文与用于保存的摘要。
+func NewRecoveryCodes() (plain, hashes []string, err error) {
+	for i := 0; i < RecoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("generate recovery code: %w", err)
+		}
+		code := strings.ToLower(b32.EncodeToString(buf))[:10]
+		code = code[:5] + "-" + code[5:]
+		plain = append(plain, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+	return plain, hashes, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(normalize(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// Store 以 AES-256-GCM 加密保存 State，密钥由 key 派生。
+type Store struct {
+	mu   sync.Mutex
+	path string
+	aead cipher.AEAD
+}
+
+type envelope struct {
+	Version int    `json:"version"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+const (
+	envelopeVersion = 1
+	additionalData  = "minisnap-totp-v1"
+)
+
+// NewStore 创建保存在 path 的加密状态存储。key 不能为空。
+func NewStore(path string, key []byte) (*Store, error) {
+	if len(key) == 0 {
+		return nil, errors.New("totp: encryption key is required")
+	}
+	derived := sha256.Sum256(append([]byte(additionalData+"\x00"), key...))
+	block, err := aes.NewCipher(derived[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Store{path: path, aead: aead}, nil
+}
+
+// Enabled 报告是否已启用二次验证（状态文件存在）。
+func (s *Store) Enabled() bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+// Load 读取并解密状态；未启用时返回 ErrNotEnrolled。
+func (s *Store) Load() (*State, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Save 加密并原子写入状态。
+func (s *Store) Save(st *State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save(st)
+}
+
+// Update 在锁内读取、修改并写回状态，保证验证码防重放与恢复码消耗不会并发丢失。
+func (s *Store) Update(fn func(*State) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, err := s.load()
+	if err != nil {
+		return err
+	}
+	if err := fn(st); err != nil {
+		return err
+	}
+	return s.save(st)
+}
+
+// Remove 删除状态，关闭二次验证。
+func (s *Store) Remove() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("remove totp state: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) load() (*State, error) {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read totp state: %w", err)
+	}
+	var env envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, fmt.Errorf("decode totp state: %w", err)
+	}
+	if env.Version != envelopeVersion || len(env.Nonce) != s.aead.NonceSize() {
+		return nil, fmt.Errorf("unsupported totp state version %d", env.Version)
+	}
+	plain, err := s.aead.Open(nil, env.Nonce, env.Data, []byte(additionalData))
+	if err != nil {
+		return nil, fmt.Errorf("decrypt totp state: %w", ErrUndecryptable)
+	}
+	var st State
+	if err := json.Unmarshal(plain, &st); err != nil {
+		return nil, fmt.Errorf("decode totp state: %w", err)
+	}
+	return &st, nil
+}
+
+func (s *Store) save(st *State) error {
+	plain, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	raw, err := json.Marshal(envelope{
+		Version: envelopeVersion,
+		Nonce:   nonce,
+		Data:    s.aead.Seal(nil, nonce, plain, []byte(additionalData)),
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("create totp state dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("write totp state: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write totp state: %w", err)
+	}
+	return nil
+}
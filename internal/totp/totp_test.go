@@ -0,0 +1,142 @@
+package totp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// RFC 4226 附录 D 的测试向量。
+func TestHOTPVectors(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	want := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for i, w := range want {
+		if got := hotp(secret, uint64(i), 6); got != w {
+			t.Errorf("hotp(%d) = %s, want %s", i, got, w)
+		}
+	}
+}
+
+// RFC 6238 附录 B 的 SHA-1 测试向量（8 位）。
+func TestTOTPVectors(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	cases := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, w := range cases {
+		if got := hotp(secret, uint64(Step(time.Unix(unix, 0))), 8); got != w {
+			t.Errorf("totp(%d) = %s, want %s", unix, got, w)
+		}
+	}
+}
+
+func TestValidateSkewAndReplay(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	st := &State{Secret: secret}
+
+	if !st.Verify(Code(secret, now.Add(-Period)), now) {
+		t.Fatalf("expected previous step to be accepted")
+	}
+	if st.Verify(Code(secret, now.Add(-Period)), now) {
+		t.Fatalf("replayed code must be rejected")
+	}
+	if !st.Verify(Code(secret, now), now) {
+		t.Fatalf("expected current code to be accepted")
+	}
+	if _, ok := Validate(secret, Code(secret, now.Add(-3*Period)), now); ok {
+		t.Fatalf("code outside skew must be rejected")
+	}
+	if _, ok := Validate(secret, "12 34", now); ok {
+		t.Fatalf("short code must be rejected")
+	}
+}
+
+func TestRecoveryCodesSingleUse(t *testing.T) {
+	plain, hashes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("got %d codes", len(plain))
+	}
+	st := &State{RecoveryCodes: hashes}
+	if !st.UseRecoveryCode(" " + strings.ToUpper(plain[3]) + " ") {
+		t.Fatalf("expected recovery code to be accepted case-insensitively")
+	}
+	if st.UseRecoveryCode(plain[3]) {
+		t.Fatalf("recovery code must be single use")
+	}
+	if len(st.RecoveryCodes) != RecoveryCodeCount-1 {
+		t.Fatalf("remaining = %d", len(st.RecoveryCodes))
+	}
+}
+
+func TestStoreEncryptsState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth", "totp.json")
+	store, err := NewStore(path, []byte("key-one"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(); !errors.Is(err, ErrNotEnrolled) {
+		t.Fatalf("Load before enroll = %v, want ErrNotEnrolled", err)
+	}
+
+	secret := []byte("super-secret-value!!")
+	if err := store.Save(&State{Secret: secret}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if !store.Enabled() {
+		t.Fatalf("expected enabled after save")
+	}
+	st, err := store.Load()
+	if err != nil || string(st.Secret) != string(secret) {
+		t.Fatalf("load = %v, %v", st, err)
+	}
+
+	other, _ := NewStore(path, []byte("key-two"))
+	if _, err := other.Load(); err == nil {
+		t.Fatalf("expected decrypt failure with wrong key")
+	}
+
+	if err := store.Remove(); err != nil || store.Enabled() {
+		t.Fatalf("remove: %v", err)
+	}
+}
+
+func TestLoadOrCreateKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "auth", "secret.key")
+	key, err := LoadOrCreateKey(keyPath)
+	if err != nil || len(key) != keySize {
+		t.Fatalf("create key: %d bytes, %v", len(key), err)
+	}
+	again, err := LoadOrCreateKey(keyPath)
+	if err != nil || string(again) != string(key) {
+		t.Fatalf("key must be persisted, got %v", err)
+	}
+	info, err := os.Stat(keyPath)
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file mode: %v, %v", info, err)
+	}
+}
+
+func TestQRCodeSVG(t *testing.T) {
+	svg, err := QRCodeSVG(URI("minisnap", "admin", []byte("12345678901234567890")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, "<path") {
+		t.Fatalf("unexpected svg %q", svg[:60])
+	}
+}
@@ -0,0 +1,98 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE 算法标识（RFC 9053）。
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// supportedAlgs 按偏好顺序列出注册时声明支持的算法。
+var supportedAlgs = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// parsePublicKey 解析 COSE_Key，返回算法与公钥。
+func parsePublicKey(coseKey []byte) (int, crypto.PublicKey, error) {
+	v, rest, err := decodeCBOR(coseKey)
+	if err != nil {
+		return 0, nil, fmt.Errorf("decode public key: %w", err)
+	}
+	if len(rest) != 0 {
+		return 0, nil, errors.New("trailing data after public key")
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return 0, nil, errors.New("public key is not a map")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == AlgES256:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, errors.New("invalid P-256 public key")
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return 0, nil, errors.New("public key point is not on curve")
+		}
+		return AlgES256, pub, nil
+	case kty == 1 && alg == AlgEdDSA:
+		crv, _ := m[int64(-1)].(int64)
+		x, _ := m[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, errors.New("invalid Ed25519 public key")
+		}
+		return AlgEdDSA, ed25519.PublicKey(x), nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return 0, nil, errors.New("invalid RSA public key")
+		}
+		exp := 0
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return AlgRS256, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, nil
+	}
+	return 0, nil, fmt.Errorf("unsupported key type %d / algorithm %d", kty, alg)
+}
+
+// verifySignature 按凭据算法校验 signed 上的签名。
+func verifySignature(coseKey, signed, sig []byte) error {
+	alg, pub, err := parsePublicKey(coseKey)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(signed)
+	switch alg {
+	case AlgES256:
+		if ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], sig) {
+			return nil
+		}
+	case AlgEdDSA:
+		if ed25519.Verify(pub.(ed25519.PublicKey), signed, sig) {
+			return nil
+		}
+	case AlgRS256:
+		if rsa.VerifyPKCS1v15(pub.(*rsa.PublicKey), crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	}
+	return errors.New("signature verification failed")
+}
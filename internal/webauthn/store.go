@@ -0,0 +1,162 @@
+package webauthn
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrNotFound 表示凭据不存在。
+var ErrNotFound = errors.New("webauthn: credential not found")
+
+// Credential 是一个已注册的通行密钥。公钥以 COSE_Key 原样保存。
+type Credential struct {
+	ID         []byte    `json:"id"`
+	Name       string    `json:"name"`
+	PublicKey  []byte    `json:"public_key"`
+	Algorithm  int       `json:"alg"`
+	SignCount  uint32    `json:"sign_count"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+}
+
+// EncodedID 返回 base64url 形式的凭据 ID，用于 URL 与前端交互。
+func (c Credential) EncodedID() string {
+	return b64.EncodeToString(c.ID)
+}
+
+// DecodeID 解析 base64url 形式的凭据 ID。
+func DecodeID(s string) ([]byte, error) {
+	return b64.DecodeString(s)
+}
+
+// Store 把凭据列表保存为单个 JSON 文件。公钥不是机密，无需加密。
+type Store struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewStore 创建保存在 path 的凭据存储。
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+// List 返回全部凭据，按注册时间排序。
+func (s *Store) List() ([]Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Get 按 ID 查找凭据。
+func (s *Store) Get(id []byte) (Credential, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, err := s.load()
+	if err != nil {
+		return Credential{}, err
+	}
+	for _, c := range creds {
+		if bytes.Equal(c.ID, id) {
+			return c, nil
+		}
+	}
+	return Credential{}, ErrNotFound
+}
+
+// Add 保存新凭据，ID 重复时报错。
+func (s *Store) Add(cred Credential) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, err := s.load()
+	if err != nil {
+		return err
+	}
+	for _, c := range creds {
+		if bytes.Equal(c.ID, cred.ID) {
+			return errors.New("webauthn: credential already registered")
+		}
+	}
+	return s.save(append(creds, cred))
+}
+
+// Touch 在登录成功后更新签名计数与最近使用时间。
+func (s *Store) Touch(id []byte, signCount uint32, at time.Time) error {
+	return s.update(id, func(c *Credential) {
+		c.SignCount = signCount
+		c.LastUsedAt = at.UTC()
+	})
+}
+
+// Remove 删除凭据。
+func (s *Store) Remove(id []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i, c := range creds {
+		if bytes.Equal(c.ID, id) {
+			return s.save(append(creds[:i], creds[i+1:]...))
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *Store) update(id []byte, fn func(*Credential)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	creds, err := s.load()
+	if err != nil {
+		return err
+	}
+	for i := range creds {
+		if bytes.Equal(creds[i].ID, id) {
+			fn(&creds[i])
+			return s.save(creds)
+		}
+	}
+	return ErrNotFound
+}
+
+func (s *Store) load() ([]Credential, error) {
+	raw, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read credentials: %w", err)
+	}
+	var creds []Credential
+	if err := json.Unmarshal(raw, &creds); err != nil {
+		return nil, fmt.Errorf("decode credentials: %w", err)
+	}
+	sort.SliceStable(creds, func(i, j int) bool { return creds[i].CreatedAt.Before(creds[j].CreatedAt) })
+	return creds, nil
+}
+
+func (s *Store) save(creds []Credential) error {
+	raw, err := json.MarshalIndent(creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("create credentials dir: %w", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, raw, 0o600); err != nil {
+		return fmt.Errorf("write credentials: %w", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("write credentials: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,227 @@
+// Package webauthn 实现单用户后台所需的 WebAuthn（通行密钥）注册与断言校验。
+//
+// 只做依赖方（Relying Party）一侧的最小校验：clientData 的类型、挑战与来源，
+// authenticatorData 的 RP ID 摘要与用户在场/验证标志，以及签名与签名计数。
+// 不校验证明（attestation）——注册时请求 "none"，不依赖设备型号的可信度。
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ChallengeTimeout 为一次注册或登录仪式的有效期。
+const ChallengeTimeout = 5 * time.Minute
+
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+)
+
+var b64 = base64.RawURLEncoding
+
+// RelyingParty 描述依赖方：RP ID 为域名，Origin 为浏览器报告的完整来源。
+type RelyingParty struct {
+	ID     string
+	Name   string
+	Origin string
+}
+
+// NewChallenge 生成随机挑战。
+func NewChallenge() ([]byte, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, fmt.Errorf("generate challenge: %w", err)
+	}
+	return buf, nil
+}
+
+// CreationOptions 返回 navigator.credentials.create 的 publicKey 参数，
+// 二进制字段以 base64url 编码，由前端脚本还原为 ArrayBuffer。
+func (rp RelyingParty) CreationOptions(challenge, userID []byte, userName string, exclude []Credential) map[string]any {
+	params := make([]map[string]any, 0, len(supportedAlgs))
+	for _, alg := range supportedAlgs {
+		params = append(params, map[string]any{"type": "public-key", "alg": alg})
+	}
+	return map[string]any{
+		"challenge": b64.EncodeToString(challenge),
+		"rp":        map[string]any{"id": rp.ID, "name": rp.Name},
+		"user": map[string]any{
+			"id":          b64.EncodeToString(userID),
+			"name":        userName,
+			"displayName": userName,
+		},
+		"pubKeyCredParams":   params,
+		"timeout":            ChallengeTimeout.Milliseconds(),
+		"attestation":        "none",
+		"excludeCredentials": descriptors(exclude),
+		"authenticatorSelection": map[string]any{
+			"residentKey":      "preferred",
+			"userVerification": "required",
+		},
+	}
+}
+
+// RequestOptions 返回 navigator.credentials.get 的 publicKey 参数。
+func (rp RelyingParty) RequestOptions(challenge []byte, allow []Credential) map[string]any {
+	return map[string]any{
+		"challenge":        b64.EncodeToString(challenge),
+		"rpId":             rp.ID,
+		"timeout":          ChallengeTimeout.Milliseconds(),
+		"allowCredentials": descriptors(allow),
+		"userVerification": "required",
+	}
+}
+
+func descriptors(creds []Credential) []map[string]any {
+	out := make([]map[string]any, 0, len(creds))
+	for _, c := range creds {
+		out = append(out, map[string]any{"type": "public-key", "id": b64.EncodeToString(c.ID)})
+	}
+	return out
+}
+
+// VerifyRegistration 校验注册响应，返回新凭据（未持久化）。
+func (rp RelyingParty) VerifyRegistration(challenge, clientDataJSON, attestationObject []byte) (*Credential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	v, rest, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("decode attestation object: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, errors.New("trailing data after attestation object")
+	}
+	att, ok := v.(map[any]any)
+	if !ok {
+		return nil, errors.New("attestation object is not a map")
+	}
+	authData, ok := att["authData"].([]byte)
+	if !ok {
+		return nil, errors.New("attestation object missing authData")
+	}
+
+	ad, err := rp.parseAuthData(authData)
+	if err != nil {
+		return nil, err
+	}
+	if ad.flags&flagAttestedData == 0 {
+		return nil, errors.New("authenticator data has no attested credential")
+	}
+	return &Credential{
+		ID:        ad.credentialID,
+		PublicKey: ad.publicKey,
+		Algorithm: ad.algorithm,
+		SignCount: ad.signCount,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// VerifyAssertion 校验登录断言，返回认证器报告的新签名计数。
+// 计数非零却未递增时视为凭据被克隆，拒绝登录。
+func (rp RelyingParty) VerifyAssertion(challenge []byte, cred Credential, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	ad, err := rp.parseAuthData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientHash[:]...)
+	if err := verifySignature(cred.PublicKey, signed, signature); err != nil {
+		return 0, err
+	}
+
+	if (ad.signCount != 0 || cred.SignCount != 0) && ad.signCount <= cred.SignCount {
+		return 0, errors.New("signature counter did not increase; credential may be cloned")
+	}
+	return ad.signCount, nil
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+func (rp RelyingParty) verifyClientData(raw []byte, wantType string, challenge []byte) error {
+	var cd clientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("decode client data: %w", err)
+	}
+	if cd.Type != wantType {
+		return fmt.Errorf("unexpected client data type %q", cd.Type)
+	}
+	got, err := b64.DecodeString(cd.Challenge)
+	if err != nil || len(challenge) == 0 || subtle.ConstantTimeCompare(got, challenge) != 1 {
+		return errors.New("challenge mismatch")
+	}
+	if cd.Origin != rp.Origin {
+		return fmt.Errorf("unexpected origin %q", cd.Origin)
+	}
+	return nil
+}
+
+type authData struct {
+	flags        byte
+	signCount    uint32
+	credentialID []byte
+	publicKey    []byte
+	algorithm    int
+}
+
+func (rp RelyingParty) parseAuthData(data []byte) (*authData, error) {
+	if len(data) < 37 {
+		return nil, errors.New("authenticator data too short")
+	}
+	rpHash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(data[:32], rpHash[:]) {
+		return nil, errors.New("rp id hash mismatch")
+	}
+	ad := &authData{flags: data[32], signCount: binary.BigEndian.Uint32(data[33:37])}
+	if ad.flags&flagUserPresent == 0 {
+		return nil, errors.New("user presence flag not set")
+	}
+	if ad.flags&flagUserVerified == 0 {
+		return nil, errors.New("user verification flag not set")
+	}
+	if ad.flags&flagAttestedData == 0 {
+		return ad, nil
+	}
+
+	rest := data[37:]
+	if len(rest) < 18 {
+		return nil, errors.New("attested credential data too short")
+	}
+	idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+	rest = rest[18:]
+	if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+		return nil, errors.New("invalid credential id length")
+	}
+	ad.credentialID = append([]byte(nil), rest[:idLen]...)
+	rest = rest[idLen:]
+
+	// 公钥是一个 CBOR 项，之后可能跟着扩展数据
+	_, after, err := decodeCBOR(rest)
+	if err != nil {
+		return nil, fmt.Errorf("decode credential public key: %w", err)
+	}
+	ad.publicKey = append([]byte(nil), rest[:len(rest)-len(after)]...)
+	if ad.algorithm, _, err = parsePublicKey(ad.publicKey); err != nil {
+		return nil, err
+	}
+	return ad, nil
+}
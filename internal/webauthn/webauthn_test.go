@@ -0,0 +1,153 @@
+package webauthn
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"minisnap/internal/webauthn/webauthntest"
+)
+
+var testRP = RelyingParty{ID: "snap.example.com", Name: "minisnap", Origin: "https://snap.example.com"}
+
+func register(t *testing.T, auth *webauthntest.Authenticator) *Credential {
+	t.Helper()
+	challenge, _ := NewChallenge()
+	cd, att := auth.Register(challenge)
+	cred, err := testRP.VerifyRegistration(challenge, cd, att)
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	return cred
+}
+
+func TestRegistrationAndAssertion(t *testing.T) {
+	auth := webauthntest.New(testRP.ID, testRP.Origin)
+	auth.SignCount = 1
+	cred := register(t, auth)
+	if string(cred.ID) != string(auth.CredentialID) || cred.Algorithm != AlgES256 {
+		t.Fatalf("unexpected credential %+v", cred)
+	}
+
+	challenge, _ := NewChallenge()
+	cd, ad, sig := auth.Assert(challenge)
+	count, err := testRP.VerifyAssertion(challenge, *cred, cd, ad, sig)
+	if err != nil {
+		t.Fatalf("assert: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("sign count = %d, want 2", count)
+	}
+
+	// 计数未递增视为克隆
+	cred.SignCount = count
+	auth.SignCount = 1
+	challenge, _ = NewChallenge()
+	cd, ad, sig = auth.Assert(challenge)
+	if _, err := testRP.VerifyAssertion(challenge, *cred, cd, ad, sig); err == nil || !strings.Contains(err.Error(), "counter") {
+		t.Fatalf("expected counter error, got %v", err)
+	}
+}
+
+func TestAssertionRejectsTampering(t *testing.T) {
+	auth := webauthntest.New(testRP.ID, testRP.Origin)
+	cred := register(t, auth)
+	challenge, _ := NewChallenge()
+
+	cases := map[string]func() error{
+		"wrong challenge": func() error {
+			other, _ := NewChallenge()
+			cd, ad, sig := auth.Assert(other)
+			_, err := testRP.VerifyAssertion(challenge, *cred, cd, ad, sig)
+			return err
+		},
+		"wrong origin": func() error {
+			auth.Origin = "https://evil.example"
+			defer func() { auth.Origin = testRP.Origin }()
+			cd, ad, sig := auth.Assert(challenge)
+			_, err := testRP.VerifyAssertion(challenge, *cred, cd, ad, sig)
+			return err
+		},
+		"wrong rp id": func() error {
+			other := RelyingParty{ID: "other.example", Origin: testRP.Origin}
+			cd, ad, sig := auth.Assert(challenge)
+			_, err := other.VerifyAssertion(challenge, *cred, cd, ad, sig)
+			return err
+		},
+		"bad signature": func() error {
+			cd, ad, sig := auth.Assert(challenge)
+			sig[len(sig)-1] ^= 0xff
+			_, err := testRP.VerifyAssertion(challenge, *cred, cd, ad, sig)
+			return err
+		},
+		"other key": func() error {
+			other := webauthntest.New(testRP.ID, testRP.Origin)
+			cd, ad, sig := other.Assert(challenge)
+			_, err := testRP.VerifyAssertion(challenge, *cred, cd, ad, sig)
+			return err
+		},
+		"no user verification": func() error {
+			auth.Flags = 0x01
+			defer func() { auth.Flags = 0 }()
+			cd, ad, sig := auth.Assert(challenge)
+			_, err := testRP.VerifyAssertion(challenge, *cred, cd, ad, sig)
+			return err
+		},
+	}
+	for name, fn := range cases {
+		if err := fn(); err == nil {
+			t.Errorf("%s: expected verification failure", name)
+		}
+	}
+}
+
+func TestRegistrationRejectsWrongType(t *testing.T) {
+	auth := webauthntest.New(testRP.ID, testRP.Origin)
+	challenge, _ := NewChallenge()
+	cd, _, _ := auth.Assert(challenge)
+	_, att := auth.Register(challenge)
+	if _, err := testRP.VerifyRegistration(challenge, cd, att); err == nil {
+		t.Fatalf("expected error for webauthn.get client data")
+	}
+}
+
+func TestDecodeCBORRejectsMalformed(t *testing.T) {
+	for _, in := range [][]byte{
+		{},
+		{0x5f},       // 不定长字节串
+		{0x59, 0xff}, // 截断的长度
+		{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, // 巨大的数组长度
+	} {
+		if _, _, err := decodeCBOR(in); err == nil {
+			t.Errorf("decodeCBOR(%x): expected error", in)
+		}
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "auth", "passkeys.json"))
+	if creds, err := store.List(); err != nil || len(creds) != 0 {
+		t.Fatalf("empty list = %v, %v", creds, err)
+	}
+	cred := Credential{ID: []byte{1, 2, 3}, Name: "laptop", CreatedAt: time.Now()}
+	if err := store.Add(cred); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if err := store.Add(cred); err == nil {
+		t.Fatalf("expected duplicate error")
+	}
+	if err := store.Touch(cred.ID, 7, time.Now()); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	got, err := store.Get(cred.ID)
+	if err != nil || got.SignCount != 7 || got.LastUsedAt.IsZero() {
+		t.Fatalf("get = %+v, %v", got, err)
+	}
+	if err := store.Remove(cred.ID); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := store.Get(cred.ID); err != ErrNotFound {
+		t.Fatalf("get after remove = %v", err)
+	}
+}
@@ -0,0 +1,146 @@
+// Package webauthntest 提供一个软件实现的 WebAuthn 认证器，供测试在没有硬件时
+// 生成注册与断言响应。
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+)
+
+// Authenticator 持有一把 P-256 密钥的软件认证器。
+type Authenticator struct {
+	RPID   string
+	Origin string
+	// CredentialID 为注册后的凭据 ID。
+	CredentialID []byte
+	// SignCount 为下一次断言前的签名计数；设为 0 可模拟不支持计数的认证器。
+	SignCount uint32
+	// Flags 覆盖 authenticatorData 标志位，零值表示 UP|UV。
+	Flags byte
+
+	key *ecdsa.PrivateKey
+}
+
+// New 创建认证器。
+func New(rpID, origin string) *Authenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return &Authenticator{RPID: rpID, Origin: origin, CredentialID: id, key: key}
+}
+
+// Register 对 challenge 生成 navigator.credentials.create 的响应。
+func (a *Authenticator) Register(challenge []byte) (clientDataJSON, attestationObject []byte) {
+	clientDataJSON = a.clientData("webauthn.create", challenge)
+
+	x := a.key.PublicKey.X.FillBytes(make([]byte, 32))
+	y := a.key.PublicKey.Y.FillBytes(make([]byte, 32))
+	coseKey := encode(map[any]any{int64(1): int64(2), int64(3): int64(-7), int64(-1): int64(1), int64(-2): x, int64(-3): y})
+
+	authData := a.authData(a.flags() | 0x40)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.CredentialID)))
+	authData = append(authData, a.CredentialID...)
+	authData = append(authData, coseKey...)
+
+	attestationObject = encode(map[any]any{
+		"fmt":      "none",
+		"attStmt":  map[any]any{},
+		"authData": authData,
+	})
+	return clientDataJSON, attestationObject
+}
+
+// Assert 对 challenge 生成 navigator.credentials.get 的响应，并递增签名计数。
+func (a *Authenticator) Assert(challenge []byte) (clientDataJSON, authenticatorData, signature []byte) {
+	if a.SignCount != 0 {
+		a.SignCount++
+	}
+	clientDataJSON = a.clientData("webauthn.get", challenge)
+	authenticatorData = a.authData(a.flags())
+
+	clientHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authenticatorData...), clientHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return clientDataJSON, authenticatorData, signature
+}
+
+func (a *Authenticator) flags() byte {
+	if a.Flags != 0 {
+		return a.Flags
+	}
+	return 0x01 | 0x04
+}
+
+func (a *Authenticator) authData(flags byte) []byte {
+	rpHash := sha256.Sum256([]byte(a.RPID))
+	data := append(rpHash[:], flags)
+	return binary.BigEndian.AppendUint32(data, a.SignCount)
+}
+
+func (a *Authenticator) clientData(typ string, challenge []byte) []byte {
+	raw, _ := json.Marshal(map[string]any{
+		"type":        typ,
+		"challenge":   base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+	return raw
+}
+
+// encode 是仅覆盖上面用到类型的 CBOR 编码。
+func encode(v any) []byte {
+	switch v := v.(type) {
+	case int64:
+		if v < 0 {
+			return head(1, uint64(-1-v))
+		}
+		return head(0, uint64(v))
+	case []byte:
+		return append(head(2, uint64(len(v))), v...)
+	case string:
+		return append(head(3, uint64(len(v))), v...)
+	case map[any]any:
+		keys := make([][]byte, 0, len(v))
+		enc := make(map[string][]byte, len(v))
+		for k, val := range v {
+			kb := encode(k)
+			keys = append(keys, kb)
+			enc[string(kb)] = encode(val)
+		}
+		// 按编码后字节排序，得到确定性的输出
+		sort.Slice(keys, func(i, j int) bool { return string(keys[i]) < string(keys[j]) })
+		out := head(5, uint64(len(v)))
+		for _, kb := range keys {
+			out = append(out, kb...)
+			out = append(out, enc[string(kb)]...)
+		}
+		return out
+	}
+	panic("webauthntest: unsupported cbor type")
+}
+
+func head(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n < 1<<8:
+		return []byte{major<<5 | 24, byte(n)}
+	case n < 1<<16:
+		return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+	default:
+		return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+	}
+}